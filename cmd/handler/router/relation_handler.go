@@ -1,6 +1,9 @@
 package router
 
 import (
+	"context"
+	"errors"
+	"friend-management-v1/internal/auth"
 	"friend-management-v1/internal/service"
 	"friend-management-v1/internal/utils"
 	"friend-management-v1/model"
@@ -23,16 +26,23 @@ func (h *RelationHandler) GetFriendsEmail(w http.ResponseWriter, r *http.Request
 			respondWithError(w, http.StatusBadRequest, response)
 			return
 		}
-		email, err := h.service.GetFriendsEmail(request)
-		if err != nil {
+		if err := utils.ValidateLimit(request.Limit); err != nil {
 			response := model.NewErrorResponse(err.Error())
 			respondWithError(w, http.StatusBadRequest, response)
 			return
 		}
+		page, err := h.service.GetFriendsEmail(r.Context(), request)
+		if err != nil {
+			response := model.NewErrorResponse(err.Error())
+			respondWithError(w, errorStatus(err), response)
+			return
+		}
 		response := model.AddAndGetResponse{
-			Success: true,
-			Friends: email,
-			Count:   len(email),
+			Success:    true,
+			Friends:    page.Emails,
+			Count:      len(page.Emails),
+			Total:      page.Total,
+			NextCursor: page.NextCursor,
 		}
 		respondwithJSON(w, http.StatusOK, response)
 	} else {
@@ -51,10 +61,10 @@ func (h *RelationHandler) AddFriend(w http.ResponseWriter, r *http.Request) {
 			respondWithError(w, http.StatusBadRequest, response)
 			return
 		}
-		_, err := h.service.Addfriend(request)
+		_, err := h.service.Addfriend(r.Context(), request)
 		if err != nil {
 			response := model.NewErrorResponse(err.Error())
-			respondWithError(w, http.StatusBadRequest, response)
+			respondWithError(w, errorStatus(err), response)
 			return
 		}
 		respondwithJSON(w, http.StatusOK, model.SuccessRespone{Success: true})
@@ -66,7 +76,7 @@ func (h *RelationHandler) AddFriend(w http.ResponseWriter, r *http.Request) {
 
 }
 
-func (h *RelationHandler) GetCommonFriends(w http.ResponseWriter, r *http.Request) {
+func (h *RelationHandler) Unfriend(w http.ResponseWriter, r *http.Request) {
 	var request model.AddAndGetCommonRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err == nil {
@@ -75,16 +85,46 @@ func (h *RelationHandler) GetCommonFriends(w http.ResponseWriter, r *http.Reques
 			respondWithError(w, http.StatusBadRequest, response)
 			return
 		}
-		friends, err := h.service.GetCommonFriends(request)
+		_, err := h.service.Unfriend(r.Context(), request)
 		if err != nil {
+			response := model.NewErrorResponse(err.Error())
+			respondWithError(w, errorStatus(err), response)
+			return
+		}
+		respondwithJSON(w, http.StatusOK, model.SuccessRespone{Success: true})
+	} else {
+		response := model.NewErrorResponse(err.Error())
+		respondWithError(w, http.StatusInternalServerError, response)
+		return
+	}
+}
+
+func (h *RelationHandler) GetCommonFriends(w http.ResponseWriter, r *http.Request) {
+	var request model.AddAndGetCommonRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err == nil {
+		if err := utils.ValidateCommonRequest(request); err != nil {
 			response := model.NewErrorResponse(err.Error())
 			respondWithError(w, http.StatusBadRequest, response)
 			return
 		}
+		if err := utils.ValidateLimit(request.Limit); err != nil {
+			response := model.NewErrorResponse(err.Error())
+			respondWithError(w, http.StatusBadRequest, response)
+			return
+		}
+		page, err := h.service.GetCommonFriends(r.Context(), request)
+		if err != nil {
+			response := model.NewErrorResponse(err.Error())
+			respondWithError(w, errorStatus(err), response)
+			return
+		}
 		response := model.AddAndGetResponse{
-			Success: true,
-			Friends: friends,
-			Count:   len(friends),
+			Success:    true,
+			Friends:    page.Emails,
+			Count:      len(page.Emails),
+			Total:      page.Total,
+			NextCursor: page.NextCursor,
 		}
 		respondwithJSON(w, http.StatusOK, response)
 	} else {
@@ -103,12 +143,35 @@ func (h *RelationHandler) SubcribeToEmail(w http.ResponseWriter, r *http.Request
 			respondWithError(w, http.StatusBadRequest, response)
 			return
 		}
-		_, err := h.service.SubcribeToEmail(request)
+		_, err := h.service.SubcribeToEmail(r.Context(), request)
 		if err != nil {
+			response := model.NewErrorResponse(err.Error())
+			respondWithError(w, errorStatus(err), response)
+			return
+		}
+		respondwithJSON(w, http.StatusOK, model.SuccessRespone{Success: true})
+	} else {
+		response := model.NewErrorResponse(err.Error())
+		respondWithError(w, http.StatusInternalServerError, response)
+		return
+	}
+}
+
+func (h *RelationHandler) UnsubcribeFromEmail(w http.ResponseWriter, r *http.Request) {
+	var request model.SubcribeAndBlockRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err == nil {
+		if err := utils.ValidateSubcribeAndBlockRequest(request); err != nil {
 			response := model.NewErrorResponse(err.Error())
 			respondWithError(w, http.StatusBadRequest, response)
 			return
 		}
+		_, err := h.service.UnsubcribeFromEmail(r.Context(), request)
+		if err != nil {
+			response := model.NewErrorResponse(err.Error())
+			respondWithError(w, errorStatus(err), response)
+			return
+		}
 		respondwithJSON(w, http.StatusOK, model.SuccessRespone{Success: true})
 	} else {
 		response := model.NewErrorResponse(err.Error())
@@ -126,10 +189,10 @@ func (h *RelationHandler) BlockEmail(w http.ResponseWriter, r *http.Request) {
 			respondWithError(w, http.StatusBadRequest, response)
 			return
 		}
-		_, err := h.service.BlockEmail(request)
+		_, err := h.service.BlockEmail(r.Context(), request)
 		if err != nil {
 			response := model.NewErrorResponse(err.Error())
-			respondWithError(w, http.StatusBadRequest, response)
+			respondWithError(w, errorStatus(err), response)
 			return
 		}
 		respondwithJSON(w, http.StatusOK, model.SuccessRespone{Success: true})
@@ -140,6 +203,33 @@ func (h *RelationHandler) BlockEmail(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (h *RelationHandler) UnblockEmail(w http.ResponseWriter, r *http.Request) {
+	var request model.SubcribeAndBlockRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err == nil {
+		if err := utils.ValidateSubcribeAndBlockRequest(request); err != nil {
+			response := model.NewErrorResponse(err.Error())
+			respondWithError(w, http.StatusBadRequest, response)
+			return
+		}
+		restored, err := h.service.UnblockEmail(r.Context(), request)
+		if err != nil {
+			response := model.NewErrorResponse(err.Error())
+			respondWithError(w, errorStatus(err), response)
+			return
+		}
+		response := model.UnblockResponse{
+			Success:  true,
+			Restored: restored,
+		}
+		respondwithJSON(w, http.StatusOK, response)
+	} else {
+		response := model.NewErrorResponse(err.Error())
+		respondWithError(w, http.StatusInternalServerError, response)
+		return
+	}
+}
+
 func (h *RelationHandler) GetRetrivableEmails(w http.ResponseWriter, r *http.Request) {
 	var request model.RetrieveRequest
 
@@ -149,15 +239,249 @@ func (h *RelationHandler) GetRetrivableEmails(w http.ResponseWriter, r *http.Req
 			respondWithError(w, http.StatusBadRequest, response)
 			return
 		}
-		recipients, err := h.service.RetrieveContactEmail(request)
-		if err != nil {
+		if err := utils.ValidateLimit(request.Limit); err != nil {
 			response := model.NewErrorResponse(err.Error())
 			respondWithError(w, http.StatusBadRequest, response)
 			return
 		}
+		page, err := h.service.RetrieveContactEmail(r.Context(), request)
+		if err != nil {
+			response := model.NewErrorResponse(err.Error())
+			respondWithError(w, errorStatus(err), response)
+			return
+		}
 		response := model.RetrieveResponse{
+			Success:            true,
+			Recipients:         page.Emails,
+			UnresolvedMentions: page.UnresolvedMentions,
+			Total:              page.Total,
+			NextCursor:         page.NextCursor,
+			UpdateId:           page.UpdateId,
+		}
+		respondwithJSON(w, http.StatusOK, response)
+	} else {
+		response := model.NewErrorResponse(err.Error())
+		respondWithError(w, http.StatusInternalServerError, response)
+		return
+	}
+}
+
+func (h *RelationHandler) GetSuggestions(w http.ResponseWriter, r *http.Request) {
+	var request model.SuggestionsRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err == nil {
+		if err := utils.ValidateSuggestionsRequest(request); err != nil {
+			response := model.NewErrorResponse(err.Error())
+			respondWithError(w, http.StatusBadRequest, response)
+			return
+		}
+		suggestions, err := h.service.GetSuggestions(r.Context(), request)
+		if err != nil {
+			response := model.NewErrorResponse(err.Error())
+			respondWithError(w, errorStatus(err), response)
+			return
+		}
+		response := model.SuggestionsResponse{
+			Success:     true,
+			Suggestions: suggestions,
+			Count:       len(suggestions),
+		}
+		respondwithJSON(w, http.StatusOK, response)
+	} else {
+		response := model.NewErrorResponse(err.Error())
+		respondWithError(w, http.StatusInternalServerError, response)
+		return
+	}
+}
+
+func (h *RelationHandler) GetPath(w http.ResponseWriter, r *http.Request) {
+	var request model.PathRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err == nil {
+		if err := utils.ValidatePathRequest(request); err != nil {
+			response := model.NewErrorResponse(err.Error())
+			respondWithError(w, http.StatusBadRequest, response)
+			return
+		}
+		path, err := h.service.GetPath(r.Context(), request)
+		if err != nil {
+			response := model.NewErrorResponse(err.Error())
+			respondWithError(w, errorStatus(err), response)
+			return
+		}
+		response := model.PathResponse{
+			Success: true,
+			Found:   path != nil,
+			Path:    []string{},
+		}
+		if path != nil {
+			response.Path = path
+			response.Degrees = len(path) - 1
+		}
+		respondwithJSON(w, http.StatusOK, response)
+	} else {
+		response := model.NewErrorResponse(err.Error())
+		respondWithError(w, http.StatusInternalServerError, response)
+		return
+	}
+}
+
+func (h *RelationHandler) GetInbox(w http.ResponseWriter, r *http.Request) {
+	var request model.InboxRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err == nil {
+		if err := utils.ValidateInboxRequest(request); err != nil {
+			response := model.NewErrorResponse(err.Error())
+			respondWithError(w, http.StatusBadRequest, response)
+			return
+		}
+		page, err := h.service.GetInbox(r.Context(), request)
+		if err != nil {
+			response := model.NewErrorResponse(err.Error())
+			respondWithError(w, errorStatus(err), response)
+			return
+		}
+		response := model.InboxResponse{
+			Success:    true,
+			Items:      page.Items,
+			Count:      len(page.Items),
+			Total:      page.Total,
+			Unread:     page.Unread,
+			NextCursor: page.NextCursor,
+		}
+		respondwithJSON(w, http.StatusOK, response)
+	} else {
+		response := model.NewErrorResponse(err.Error())
+		respondWithError(w, http.StatusInternalServerError, response)
+		return
+	}
+}
+
+func (h *RelationHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	var request model.MarkReadRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err == nil {
+		if err := utils.ValidateMarkReadRequest(request); err != nil {
+			response := model.NewErrorResponse(err.Error())
+			respondWithError(w, http.StatusBadRequest, response)
+			return
+		}
+		marked, err := h.service.MarkRead(r.Context(), request)
+		if err != nil {
+			response := model.NewErrorResponse(err.Error())
+			respondWithError(w, errorStatus(err), response)
+			return
+		}
+		response := model.MarkReadResponse{
+			Success: true,
+			Marked:  marked,
+		}
+		respondwithJSON(w, http.StatusOK, response)
+	} else {
+		response := model.NewErrorResponse(err.Error())
+		respondWithError(w, http.StatusInternalServerError, response)
+		return
+	}
+}
+
+func (h *RelationHandler) RegisterWebhook(w http.ResponseWriter, r *http.Request) {
+	var request model.WebhookRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err == nil {
+		if err := utils.ValidateWebhookRequest(request); err != nil {
+			response := model.NewErrorResponse(err.Error())
+			respondWithError(w, http.StatusBadRequest, response)
+			return
+		}
+		if err := h.service.RegisterWebhook(r.Context(), request); err != nil {
+			response := model.NewErrorResponse(err.Error())
+			respondWithError(w, errorStatus(err), response)
+			return
+		}
+		respondwithJSON(w, http.StatusOK, model.SuccessRespone{Success: true})
+	} else {
+		response := model.NewErrorResponse(err.Error())
+		respondWithError(w, http.StatusInternalServerError, response)
+		return
+	}
+}
+
+func (h *RelationHandler) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	var request model.DeliveriesRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err == nil {
+		if err := utils.ValidateDeliveriesRequest(request); err != nil {
+			response := model.NewErrorResponse(err.Error())
+			respondWithError(w, http.StatusBadRequest, response)
+			return
+		}
+		page, err := h.service.GetDeliveries(r.Context(), request)
+		if err != nil {
+			response := model.NewErrorResponse(err.Error())
+			respondWithError(w, errorStatus(err), response)
+			return
+		}
+		response := model.DeliveriesResponse{
+			Success:    true,
+			Deliveries: page.Deliveries,
+			Count:      len(page.Deliveries),
+			NextCursor: page.NextCursor,
+		}
+		respondwithJSON(w, http.StatusOK, response)
+	} else {
+		response := model.NewErrorResponse(err.Error())
+		respondWithError(w, http.StatusInternalServerError, response)
+		return
+	}
+}
+
+func (h *RelationHandler) GetAuditRecords(w http.ResponseWriter, r *http.Request) {
+	var request model.AuditRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err == nil {
+		if err := utils.ValidateAuditRequest(request); err != nil {
+			response := model.NewErrorResponse(err.Error())
+			respondWithError(w, http.StatusBadRequest, response)
+			return
+		}
+		page, err := h.service.GetAuditRecords(r.Context(), request)
+		if err != nil {
+			response := model.NewErrorResponse(err.Error())
+			respondWithError(w, errorStatus(err), response)
+			return
+		}
+		response := model.AuditResponse{
 			Success:    true,
-			Recipients: recipients,
+			Records:    page.Records,
+			Count:      len(page.Records),
+			NextCursor: page.NextCursor,
+		}
+		respondwithJSON(w, http.StatusOK, response)
+	} else {
+		response := model.NewErrorResponse(err.Error())
+		respondWithError(w, http.StatusInternalServerError, response)
+		return
+	}
+}
+
+func (h *RelationHandler) RegisterEmail(w http.ResponseWriter, r *http.Request) {
+	var request model.UserRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err == nil {
+		if err := utils.ValidateUserRequest(request); err != nil {
+			response := model.NewErrorResponse(err.Error())
+			respondWithError(w, http.StatusBadRequest, response)
+			return
+		}
+		user, err := h.service.RegisterEmail(r.Context(), request)
+		if err != nil {
+			response := model.NewErrorResponse(err.Error())
+			respondWithError(w, errorStatus(err), response)
+			return
+		}
+		response := model.UserResponse{
+			Success: true,
+			User:    user,
 		}
 		respondwithJSON(w, http.StatusOK, response)
 	} else {
@@ -167,6 +491,48 @@ func (h *RelationHandler) GetRetrivableEmails(w http.ResponseWriter, r *http.Req
 	}
 }
 
+func (h *RelationHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	var request model.UserRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err == nil {
+		if err := utils.ValidateUserRequest(request); err != nil {
+			response := model.NewErrorResponse(err.Error())
+			respondWithError(w, http.StatusBadRequest, response)
+			return
+		}
+		user, err := h.service.GetUser(r.Context(), request)
+		if err != nil {
+			response := model.NewErrorResponse(err.Error())
+			respondWithError(w, errorStatus(err), response)
+			return
+		}
+		response := model.UserResponse{
+			Success: true,
+			User:    user,
+		}
+		respondwithJSON(w, http.StatusOK, response)
+	} else {
+		response := model.NewErrorResponse(err.Error())
+		respondWithError(w, http.StatusInternalServerError, response)
+		return
+	}
+}
+
+func (h *RelationHandler) GetUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := h.service.GetUsers(r.Context())
+	if err != nil {
+		response := model.NewErrorResponse(err.Error())
+		respondWithError(w, errorStatus(err), response)
+		return
+	}
+	response := model.UsersResponse{
+		Success: true,
+		Users:   users,
+		Count:   len(users),
+	}
+	respondwithJSON(w, http.StatusOK, response)
+}
+
 func respondwithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	response, _ := json.Marshal(payload)
 
@@ -179,3 +545,19 @@ func respondwithJSON(w http.ResponseWriter, code int, payload interface{}) {
 func respondWithError(w http.ResponseWriter, code int, payload interface{}) {
 	respondwithJSON(w, code, payload)
 }
+
+// errorStatus returns the status code for an error of the service: 504 when
+// the operation ran out of time, 503 when it was cancelled and 400 otherwise.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, context.Canceled):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, auth.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrUpdatesNotStored), errors.Is(err, service.ErrWebhooksDisabled):
+		return http.StatusNotImplemented
+	}
+	return http.StatusBadRequest
+}
@@ -2,8 +2,15 @@ package router
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"friend-management-v1/internal/auth"
+	"friend-management-v1/internal/config"
+	"friend-management-v1/internal/repos"
+	"friend-management-v1/internal/service"
+	"friend-management-v1/model"
 	"friend-management-v1/model/mocks"
 	"net/http"
 	"net/http/httptest"
@@ -11,6 +18,7 @@ import (
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -23,7 +31,7 @@ func TestGetFriendBlock(t *testing.T) {
 	testCases := []struct {
 		name          string
 		statusCode    int
-		mockResponse  []string
+		mockResponse  model.EmailPage
 		requestBody   *bytes.Buffer
 		err           error
 		jsonResponse  string
@@ -32,9 +40,9 @@ func TestGetFriendBlock(t *testing.T) {
 		{
 			name:       "Get friend email succeed",
 			statusCode: http.StatusOK,
-			mockResponse: []string{
-				"quan@gmail.com",
-				"hau@gmail.com",
+			mockResponse: model.EmailPage{
+				Emails: []string{"quan@gmail.com", "hau@gmail.com"},
+				Total:  2,
 			},
 			requestBody: bytes.NewBuffer(jsonStr),
 			jsonResponse: string(`{
@@ -43,15 +51,15 @@ func TestGetFriendBlock(t *testing.T) {
 									"quan@gmail.com",
 									"hau@gmail.com"
 								],
-								"count": 2
+								"count": 2,
+								"total": 2
 							}`),
 			err: nil,
 		},
 		{
-			name:         "Get friend email not exist",
-			statusCode:   http.StatusBadRequest,
-			mockResponse: nil,
-			requestBody:  bytes.NewBuffer(jsonStr),
+			name:        "Get friend email not exist",
+			statusCode:  http.StatusBadRequest,
+			requestBody: bytes.NewBuffer(jsonStr),
 			jsonResponse: fmt.Sprintf(`{
 									"success": false,
 									"text": "email: quan@gmail.com is not exist in database",
@@ -59,6 +67,17 @@ func TestGetFriendBlock(t *testing.T) {
 								}`, current),
 			err: errors.New("email: quan@gmail.com is not exist in database"),
 		},
+		{
+			name:        "Get friend email timed out",
+			statusCode:  http.StatusGatewayTimeout,
+			requestBody: bytes.NewBuffer(jsonStr),
+			jsonResponse: fmt.Sprintf(`{
+									"success": false,
+									"text": "context deadline exceeded",
+									"timestamp": "%s"
+								}`, current),
+			err: context.DeadlineExceeded,
+		},
 		{
 			name:        "Get friends invalid email",
 			statusCode:  http.StatusBadRequest,
@@ -81,6 +100,37 @@ func TestGetFriendBlock(t *testing.T) {
 								}`, current),
 			err: nil,
 		},
+		{
+			name:       "Get friend email next page",
+			statusCode: http.StatusOK,
+			mockResponse: model.EmailPage{
+				Emails:     []string{"quan@gmail.com"},
+				NextCursor: "Mg",
+				Total:      2,
+			},
+			requestBody: bytes.NewBufferString(`{"email": "quan@gmail.com", "limit": 1}`),
+			jsonResponse: string(`{
+								"success": true,
+								"friends": [
+									"quan@gmail.com"
+								],
+								"count": 1,
+								"total": 2,
+								"next_cursor": "Mg"
+							}`),
+			err: nil,
+		},
+		{
+			name:        "Get friends invalid limit",
+			statusCode:  http.StatusBadRequest,
+			requestBody: bytes.NewBufferString(`{"email": "quan@gmail.com", "limit": 1001}`),
+			jsonResponse: fmt.Sprintf(`{
+									"success": false,
+									"text": "limit must be between 0 and 1000",
+									"timestamp": "%s"
+								}`, current),
+			err: nil,
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -88,7 +138,7 @@ func TestGetFriendBlock(t *testing.T) {
 			handler := RelationHandler{
 				service: mockService,
 			}
-			mockService.On("GetFriendsEmail", mock.Anything).Return(tc.mockResponse, tc.err)
+			mockService.On("GetFriendsEmail", mock.Anything, mock.Anything).Return(tc.mockResponse, tc.err)
 			request, er := http.NewRequest("POST", "/api/friends", tc.requestBody)
 			checkError(er, t)
 
@@ -193,7 +243,7 @@ func TestAddFriendBlock(t *testing.T) {
 			handler := RelationHandler{
 				service: mockService,
 			}
-			mockService.On("Addfriend", mock.Anything).Return(tc.mockResponse, tc.err)
+			mockService.On("Addfriend", mock.Anything, mock.Anything).Return(tc.mockResponse, tc.err)
 			request, er := http.NewRequest("POST", "/api/add", tc.requestBody)
 			checkError(er, t)
 
@@ -209,6 +259,94 @@ func TestAddFriendBlock(t *testing.T) {
 	}
 }
 
+func TestUnfriendBlock(t *testing.T) {
+	jsonStr := []byte(`{
+		"friends" : [
+			"quan@gmail.com",
+			"quang@gmail.com"
+		]
+	}`)
+	jsonInvalidEmail := []byte(`{
+		"friends" : [
+			"quan12ytgmail.com",
+			"quang@gmail.com"
+		]
+	}`)
+	current := time.Now().Format("2006-01-02 15:04:05")
+
+	testCases := []struct {
+		name         string
+		statusCode   int
+		mockResponse bool
+		requestBody  *bytes.Buffer
+		err          error
+		jsonResponse string
+	}{
+		{
+			name:         "Unfriend succeed",
+			statusCode:   http.StatusOK,
+			mockResponse: true,
+			requestBody:  bytes.NewBuffer(jsonStr),
+			jsonResponse: string(`{ "success": true }`),
+			err:          nil,
+		},
+		{
+			name:         "Unfriend not friends",
+			statusCode:   http.StatusBadRequest,
+			mockResponse: false,
+			requestBody:  bytes.NewBuffer(jsonStr),
+			jsonResponse: fmt.Sprintf(`{
+									"success": false,
+									"text": "2 emails are not friends",
+									"timestamp": "%s"
+								}`, current),
+			err: errors.New("2 emails are not friends"),
+		},
+		{
+			name:        "Unfriend invalid email",
+			statusCode:  http.StatusBadRequest,
+			requestBody: bytes.NewBuffer(jsonInvalidEmail),
+			jsonResponse: fmt.Sprintf(`{
+									"success": false,
+									"text": "invalid email format",
+									"timestamp": "%s"
+								}`, current),
+			err: nil,
+		},
+		{
+			name:        "Unfriend invalid request",
+			statusCode:  http.StatusInternalServerError,
+			requestBody: bytes.NewBuffer(nil),
+			jsonResponse: fmt.Sprintf(`{
+									"success": false,
+									"text": "EOF",
+									"timestamp": "%s"
+								}`, current),
+			err: nil,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := new(mocks.RelationService)
+			handler := RelationHandler{
+				service: mockService,
+			}
+			mockService.On("Unfriend", mock.Anything, mock.Anything).Return(tc.mockResponse, tc.err)
+			request, er := http.NewRequest("POST", "/api/unfriend", tc.requestBody)
+			checkError(er, t)
+
+			chi := chi.NewRouter()
+			rr := httptest.NewRecorder()
+			chi.Post("/api/unfriend", func(w http.ResponseWriter, r *http.Request) {
+				handler.Unfriend(w, r)
+			})
+			chi.ServeHTTP(rr, request)
+			assert.Equal(t, tc.statusCode, rr.Code)
+			assert.JSONEq(t, tc.jsonResponse, rr.Body.String())
+		})
+	}
+}
+
 func TestGetCommonFriendBlock(t *testing.T) {
 	jsonStr := []byte(`{
 		"friends" : [
@@ -232,7 +370,7 @@ func TestGetCommonFriendBlock(t *testing.T) {
 	testCases := []struct {
 		name          string
 		statusCode    int
-		mockResponse  []string
+		mockResponse  model.EmailPage
 		requestBody   *bytes.Buffer
 		err           error
 		jsonResponse  string
@@ -241,9 +379,9 @@ func TestGetCommonFriendBlock(t *testing.T) {
 		{
 			name:       "Get common friends succeed",
 			statusCode: http.StatusOK,
-			mockResponse: []string{
-				"quan@gmail.com",
-				"hau@gmail.com",
+			mockResponse: model.EmailPage{
+				Emails: []string{"quan@gmail.com", "hau@gmail.com"},
+				Total:  2,
 			},
 			requestBody: bytes.NewBuffer(jsonStr),
 			jsonResponse: string(`{
@@ -252,15 +390,15 @@ func TestGetCommonFriendBlock(t *testing.T) {
 									"quan@gmail.com",
 									"hau@gmail.com"
 								],
-								"count": 2
+								"count": 2,
+								"total": 2
 							}`),
 			err: nil,
 		},
 		{
-			name:         "Get common friends not exist",
-			statusCode:   http.StatusBadRequest,
-			mockResponse: nil,
-			requestBody:  bytes.NewBuffer(jsonStr),
+			name:        "Get common friends not exist",
+			statusCode:  http.StatusBadRequest,
+			requestBody: bytes.NewBuffer(jsonStr),
 			jsonResponse: fmt.Sprintf(`{
 									"success": false,
 									"text": "email: quan@gmail.com is not exist in database",
@@ -285,7 +423,7 @@ func TestGetCommonFriendBlock(t *testing.T) {
 			requestBody: bytes.NewBuffer(jsonLackEmail),
 			jsonResponse: fmt.Sprintf(`{
 									"success": false,
-									"text": "must contain 2 emails",
+									"text": "must contain at least 2 emails",
 									"timestamp": "%s"
 								}`, current),
 			err: nil,
@@ -301,6 +439,35 @@ func TestGetCommonFriendBlock(t *testing.T) {
 								}`, current),
 			err: nil,
 		},
+		{
+			name:       "Get common friends of three emails",
+			statusCode: http.StatusOK,
+			mockResponse: model.EmailPage{
+				Emails: []string{"hau@gmail.com"},
+				Total:  1,
+			},
+			requestBody: bytes.NewBufferString(`{"friends": ["quan@gmail.com", "quang@gmail.com", "len@gmail.com"]}`),
+			jsonResponse: string(`{
+								"success": true,
+								"friends": [
+									"hau@gmail.com"
+								],
+								"count": 1,
+								"total": 1
+							}`),
+			err: nil,
+		},
+		{
+			name:        "Get common friends invalid limit",
+			statusCode:  http.StatusBadRequest,
+			requestBody: bytes.NewBufferString(`{"friends": ["quan@gmail.com", "quang@gmail.com"], "limit": -1}`),
+			jsonResponse: fmt.Sprintf(`{
+									"success": false,
+									"text": "limit must be between 0 and 1000",
+									"timestamp": "%s"
+								}`, current),
+			err: nil,
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -308,7 +475,7 @@ func TestGetCommonFriendBlock(t *testing.T) {
 			handler := RelationHandler{
 				service: mockService,
 			}
-			mockService.On("GetCommonFriends", mock.Anything).Return(tc.mockResponse, tc.err)
+			mockService.On("GetCommonFriends", mock.Anything, mock.Anything).Return(tc.mockResponse, tc.err)
 			request, er := http.NewRequest("POST", "/api/common", tc.requestBody)
 			checkError(er, t)
 
@@ -408,7 +575,7 @@ func TestSubcribeToEmailBlock(t *testing.T) {
 			handler := RelationHandler{
 				service: mockService,
 			}
-			mockService.On("SubcribeToEmail", mock.Anything).Return(tc.mockResponse, tc.err)
+			mockService.On("SubcribeToEmail", mock.Anything, mock.Anything).Return(tc.mockResponse, tc.err)
 			request, er := http.NewRequest("POST", "/api/subcribe", tc.requestBody)
 			checkError(er, t)
 
@@ -424,6 +591,90 @@ func TestSubcribeToEmailBlock(t *testing.T) {
 	}
 }
 
+func TestUnsubcribeFromEmailBlock(t *testing.T) {
+	jsonStr := []byte(`{
+						"requestor": "quan@gmail.com",
+						"target": "quang@gmail.com"
+				}`)
+	jsonEmptyRequest := []byte(`{
+					"requestor": "",
+					"target": "quang@gmail.com"
+			}`)
+	current := time.Now().Format("2006-01-02 15:04:05")
+
+	testCases := []struct {
+		name         string
+		statusCode   int
+		mockResponse bool
+		requestBody  *bytes.Buffer
+		err          error
+		jsonResponse string
+	}{
+		{
+			name:         "Unsubcribe succeed",
+			statusCode:   http.StatusOK,
+			mockResponse: true,
+			requestBody:  bytes.NewBuffer(jsonStr),
+			jsonResponse: string(`{ "success": true }`),
+			err:          nil,
+		},
+		{
+			name:         "Unsubcribe not subcribed",
+			statusCode:   http.StatusBadRequest,
+			mockResponse: false,
+			requestBody:  bytes.NewBuffer(jsonStr),
+			jsonResponse: fmt.Sprintf(`{
+									"success": false,
+									"text": "not subcribe to the target email",
+									"timestamp": "%s"
+								}`, current),
+			err: errors.New("not subcribe to the target email"),
+		},
+		{
+			name:        "Unsubcribe empty request",
+			statusCode:  http.StatusBadRequest,
+			requestBody: bytes.NewBuffer(jsonEmptyRequest),
+			jsonResponse: fmt.Sprintf(`{
+									"success": false,
+									"text": "requestor and target must not be empty",
+									"timestamp": "%s"
+								}`, current),
+			err: nil,
+		},
+		{
+			name:        "Unsubcribe invalid request",
+			statusCode:  http.StatusInternalServerError,
+			requestBody: bytes.NewBuffer(nil),
+			jsonResponse: fmt.Sprintf(`{
+									"success": false,
+									"text": "EOF",
+									"timestamp": "%s"
+								}`, current),
+			err: nil,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := new(mocks.RelationService)
+			handler := RelationHandler{
+				service: mockService,
+			}
+			mockService.On("UnsubcribeFromEmail", mock.Anything, mock.Anything).Return(tc.mockResponse, tc.err)
+			request, er := http.NewRequest("POST", "/api/unsubcribe", tc.requestBody)
+			checkError(er, t)
+
+			chi := chi.NewRouter()
+			rr := httptest.NewRecorder()
+			chi.Post("/api/unsubcribe", func(w http.ResponseWriter, r *http.Request) {
+				handler.UnsubcribeFromEmail(w, r)
+			})
+			chi.ServeHTTP(rr, request)
+			assert.Equal(t, tc.statusCode, rr.Code)
+			assert.JSONEq(t, tc.jsonResponse, rr.Body.String())
+		})
+	}
+}
+
 func TestBlockEmailBlock(t *testing.T) {
 	jsonStr := []byte(`{
 						"requestor": "quan@gmail.com",
@@ -508,7 +759,7 @@ func TestBlockEmailBlock(t *testing.T) {
 			handler := RelationHandler{
 				service: mockService,
 			}
-			mockService.On("BlockEmail", mock.Anything).Return(tc.mockResponse, tc.err)
+			mockService.On("BlockEmail", mock.Anything, mock.Anything).Return(tc.mockResponse, tc.err)
 			request, er := http.NewRequest("POST", "/api/block", tc.requestBody)
 			checkError(er, t)
 
@@ -524,63 +775,49 @@ func TestBlockEmailBlock(t *testing.T) {
 	}
 }
 
-func TestRetrieveFriendBlock(t *testing.T) {
+func TestUnblockEmailBlock(t *testing.T) {
 	jsonStr := []byte(`{
-		"sender": "quan12yt@gmail.com",
-		"text" : "ahdad la@gmail.com ahdad la@gmail.com ahdad la@gmail.com"
-	}`)
-	jsonInvalidEmail := []byte(`{
-		"sender": "quan12ytgmail.com",
-		"text" : "ahdad la@gmail.com ahdad la@gmail.com ahdad la@gmail.com"
-	}}`)
-	jsonEmptyRequest := []byte(`{
-		"sender": "",
-		"text" : "ahdad la@gmail.com ahdad la@gmail.com ahdad la@gmail.com"
-	}}`)
+						"requestor": "quan@gmail.com",
+						"target": "quang@gmail.com"
+				}`)
+	jsonStrInvalidEmail := []byte(`{
+						"requestor": "quangmail.com",
+						"target": "quang@gmail.com"
+				}`)
 	current := time.Now().Format("2006-01-02 15:04:05")
 
 	testCases := []struct {
-		name          string
-		statusCode    int
-		mockResponse  []string
-		requestBody   *bytes.Buffer
-		err           error
-		jsonResponse  string
-		errorResponse string
+		name         string
+		statusCode   int
+		mockResponse []model.RelationStatus
+		requestBody  *bytes.Buffer
+		err          error
+		jsonResponse string
 	}{
 		{
-			name:       "Retrieve contact succeed",
-			statusCode: http.StatusOK,
-			mockResponse: []string{
-				"quan@gmail.com",
-				"hau@gmail.com",
-			},
-			requestBody: bytes.NewBuffer(jsonStr),
-			jsonResponse: string(`{
-								"success": true,
-								"recipients": [
-									"quan@gmail.com",
-									"hau@gmail.com"
-								]
-							}`),
-			err: nil,
+			name:         "Unblock succeed",
+			statusCode:   http.StatusOK,
+			mockResponse: []model.RelationStatus{model.StatusFriend},
+			requestBody:  bytes.NewBuffer(jsonStr),
+			jsonResponse: string(`{ "success": true, "restored": ["FRIEND"] }`),
+			err:          nil,
 		},
 		{
-			name:         "Retrieve email not exist",
+			name:         "Unblock not blocked",
 			statusCode:   http.StatusBadRequest,
 			mockResponse: nil,
 			requestBody:  bytes.NewBuffer(jsonStr),
 			jsonResponse: fmt.Sprintf(`{
 									"success": false,
-									"text": "email: quan@gmail.com is not exist in database",
+									"text": "target email has not been blocked",
 									"timestamp": "%s"
 								}`, current),
-			err: errors.New("email: quan@gmail.com is not exist in database"),
+			err: errors.New("target email has not been blocked"),
 		},
 		{
-			name:        "Retrieve invalid email",
+			name:        "Unblock invalid email",
 			statusCode:  http.StatusBadRequest,
-			requestBody: bytes.NewBuffer(jsonInvalidEmail),
+			requestBody: bytes.NewBuffer(jsonStrInvalidEmail),
 			jsonResponse: fmt.Sprintf(`{
 									"success": false,
 									"text": "invalid email format",
@@ -589,7 +826,7 @@ func TestRetrieveFriendBlock(t *testing.T) {
 			err: nil,
 		},
 		{
-			name:        "Retrieve invalid request",
+			name:        "Unblock invalid request",
 			statusCode:  http.StatusInternalServerError,
 			requestBody: bytes.NewBuffer(nil),
 			jsonResponse: fmt.Sprintf(`{
@@ -599,17 +836,6 @@ func TestRetrieveFriendBlock(t *testing.T) {
 								}`, current),
 			err: nil,
 		},
-		{
-			name:        "Retrieve empty request",
-			statusCode:  http.StatusBadRequest,
-			requestBody: bytes.NewBuffer(jsonEmptyRequest),
-			jsonResponse: fmt.Sprintf(`{
-									"success": false,
-									"text": "sender must not empty",
-									"timestamp": "%s"
-								}`, current),
-			err: nil,
-		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -617,14 +843,14 @@ func TestRetrieveFriendBlock(t *testing.T) {
 			handler := RelationHandler{
 				service: mockService,
 			}
-			mockService.On("RetrieveContactEmail", mock.Anything).Return(tc.mockResponse, tc.err)
-			request, er := http.NewRequest("POST", "/api/retrieve", tc.requestBody)
+			mockService.On("UnblockEmail", mock.Anything, mock.Anything).Return(tc.mockResponse, tc.err)
+			request, er := http.NewRequest("POST", "/api/unblock", tc.requestBody)
 			checkError(er, t)
 
 			chi := chi.NewRouter()
 			rr := httptest.NewRecorder()
-			chi.Post("/api/retrieve", func(w http.ResponseWriter, r *http.Request) {
-				handler.GetRetrivableEmails(w, r)
+			chi.Post("/api/unblock", func(w http.ResponseWriter, r *http.Request) {
+				handler.UnblockEmail(w, r)
 			})
 			chi.ServeHTTP(rr, request)
 			assert.Equal(t, tc.statusCode, rr.Code)
@@ -633,6 +859,1068 @@ func TestRetrieveFriendBlock(t *testing.T) {
 	}
 }
 
+func TestRetrieveFriendBlock(t *testing.T) {
+	jsonStr := []byte(`{
+		"sender": "quan12yt@gmail.com",
+		"text" : "ahdad la@gmail.com ahdad la@gmail.com ahdad la@gmail.com"
+	}`)
+	jsonInvalidEmail := []byte(`{
+		"sender": "quan12ytgmail.com",
+		"text" : "ahdad la@gmail.com ahdad la@gmail.com ahdad la@gmail.com"
+	}}`)
+	jsonEmptyRequest := []byte(`{
+		"sender": "",
+		"text" : "ahdad la@gmail.com ahdad la@gmail.com ahdad la@gmail.com"
+	}}`)
+	current := time.Now().Format("2006-01-02 15:04:05")
+
+	testCases := []struct {
+		name          string
+		statusCode    int
+		mockResponse  model.RecipientsPage
+		requestBody   *bytes.Buffer
+		err           error
+		jsonResponse  string
+		errorResponse string
+	}{
+		{
+			name:       "Retrieve contact succeed",
+			statusCode: http.StatusOK,
+			mockResponse: model.RecipientsPage{
+				EmailPage: model.EmailPage{
+					Emails: []string{"quan@gmail.com", "hau@gmail.com"},
+					Total:  2,
+				},
+				UnresolvedMentions: []string{"la@gmail.com"},
+			},
+			requestBody: bytes.NewBuffer(jsonStr),
+			jsonResponse: string(`{
+								"success": true,
+								"recipients": [
+									"quan@gmail.com",
+									"hau@gmail.com"
+								],
+								"unresolved_mentions": [
+									"la@gmail.com"
+								],
+								"total": 2
+							}`),
+			err: nil,
+		},
+		{
+			name:        "Retrieve email not exist",
+			statusCode:  http.StatusBadRequest,
+			requestBody: bytes.NewBuffer(jsonStr),
+			jsonResponse: fmt.Sprintf(`{
+									"success": false,
+									"text": "email: quan@gmail.com is not exist in database",
+									"timestamp": "%s"
+								}`, current),
+			err: errors.New("email: quan@gmail.com is not exist in database"),
+		},
+		{
+			name:        "Retrieve canceled",
+			statusCode:  http.StatusServiceUnavailable,
+			requestBody: bytes.NewBuffer(jsonStr),
+			jsonResponse: fmt.Sprintf(`{
+									"success": false,
+									"text": "context canceled",
+									"timestamp": "%s"
+								}`, current),
+			err: context.Canceled,
+		},
+		{
+			name:        "Retrieve invalid email",
+			statusCode:  http.StatusBadRequest,
+			requestBody: bytes.NewBuffer(jsonInvalidEmail),
+			jsonResponse: fmt.Sprintf(`{
+									"success": false,
+									"text": "invalid email format",
+									"timestamp": "%s"
+								}`, current),
+			err: nil,
+		},
+		{
+			name:        "Retrieve invalid request",
+			statusCode:  http.StatusInternalServerError,
+			requestBody: bytes.NewBuffer(nil),
+			jsonResponse: fmt.Sprintf(`{
+									"success": false,
+									"text": "EOF",
+									"timestamp": "%s"
+								}`, current),
+			err: nil,
+		},
+		{
+			name:        "Retrieve empty request",
+			statusCode:  http.StatusBadRequest,
+			requestBody: bytes.NewBuffer(jsonEmptyRequest),
+			jsonResponse: fmt.Sprintf(`{
+									"success": false,
+									"text": "sender must not empty",
+									"timestamp": "%s"
+								}`, current),
+			err: nil,
+		},
+		{
+			name:       "Retrieve and post succeed",
+			statusCode: http.StatusOK,
+			mockResponse: model.RecipientsPage{
+				EmailPage: model.EmailPage{
+					Emails: []string{"quan@gmail.com"},
+					Total:  1,
+				},
+				UnresolvedMentions: []string{},
+				UpdateId:           "7",
+			},
+			requestBody: bytes.NewBufferString(`{"sender": "quan12yt@gmail.com", "text": "hi", "post": true}`),
+			jsonResponse: string(`{
+								"success": true,
+								"recipients": [
+									"quan@gmail.com"
+								],
+								"unresolved_mentions": [],
+								"total": 1,
+								"update_id": "7"
+							}`),
+			err: nil,
+		},
+		{
+			name:        "Retrieve invalid limit",
+			statusCode:  http.StatusBadRequest,
+			requestBody: bytes.NewBufferString(`{"sender": "quan12yt@gmail.com", "text": "hi", "limit": 5000}`),
+			jsonResponse: fmt.Sprintf(`{
+									"success": false,
+									"text": "limit must be between 0 and 1000",
+									"timestamp": "%s"
+								}`, current),
+			err: nil,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := new(mocks.RelationService)
+			handler := RelationHandler{
+				service: mockService,
+			}
+			mockService.On("RetrieveContactEmail", mock.Anything, mock.Anything).Return(tc.mockResponse, tc.err)
+			request, er := http.NewRequest("POST", "/api/retrieve", tc.requestBody)
+			checkError(er, t)
+
+			chi := chi.NewRouter()
+			rr := httptest.NewRecorder()
+			chi.Post("/api/retrieve", func(w http.ResponseWriter, r *http.Request) {
+				handler.GetRetrivableEmails(w, r)
+			})
+			chi.ServeHTTP(rr, request)
+			assert.Equal(t, tc.statusCode, rr.Code)
+			assert.JSONEq(t, tc.jsonResponse, rr.Body.String())
+		})
+	}
+}
+
+func TestGetSuggestionsBlock(t *testing.T) {
+	current := time.Now().Format("2006-01-02 15:04:05")
+
+	testCases := []struct {
+		name         string
+		statusCode   int
+		mockResponse []model.Suggestion
+		requestBody  *bytes.Buffer
+		err          error
+		jsonResponse string
+	}{
+		{
+			name:       "Get suggestions succeed",
+			statusCode: http.StatusOK,
+			mockResponse: []model.Suggestion{
+				{Email: "toan@gmail.com", MutualCount: 2, MutualFriends: []string{"quang@gmail.com", "hau@gmail.com"}},
+			},
+			requestBody: bytes.NewBufferString(`{"email": "quan@gmail.com", "limit": 5}`),
+			jsonResponse: string(`{
+								"success": true,
+								"suggestions": [
+									{
+										"email": "toan@gmail.com",
+										"mutual_count": 2,
+										"mutual_friends": ["quang@gmail.com", "hau@gmail.com"]
+									}
+								],
+								"count": 1
+							}`),
+			err: nil,
+		},
+		{
+			name:        "Get suggestions email not exist",
+			statusCode:  http.StatusBadRequest,
+			requestBody: bytes.NewBufferString(`{"email": "quan@gmail.com"}`),
+			jsonResponse: fmt.Sprintf(`{
+									"success": false,
+									"text": "email: quan@gmail.com is not exist in database",
+									"timestamp": "%s"
+								}`, current),
+			err: errors.New("email: quan@gmail.com is not exist in database"),
+		},
+		{
+			name:        "Get suggestions invalid email",
+			statusCode:  http.StatusBadRequest,
+			requestBody: bytes.NewBufferString(`{"email": "quangmail.com"}`),
+			jsonResponse: fmt.Sprintf(`{
+									"success": false,
+									"text": "invalid email format",
+									"timestamp": "%s"
+								}`, current),
+			err: nil,
+		},
+		{
+			name:        "Get suggestions invalid limit",
+			statusCode:  http.StatusBadRequest,
+			requestBody: bytes.NewBufferString(`{"email": "quan@gmail.com", "limit": -2}`),
+			jsonResponse: fmt.Sprintf(`{
+									"success": false,
+									"text": "limit must be between 0 and 1000",
+									"timestamp": "%s"
+								}`, current),
+			err: nil,
+		},
+		{
+			name:        "Get suggestions invalid request",
+			statusCode:  http.StatusInternalServerError,
+			requestBody: bytes.NewBuffer(nil),
+			jsonResponse: fmt.Sprintf(`{
+									"success": false,
+									"text": "EOF",
+									"timestamp": "%s"
+								}`, current),
+			err: nil,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := new(mocks.RelationService)
+			handler := RelationHandler{
+				service: mockService,
+			}
+			mockService.On("GetSuggestions", mock.Anything, mock.Anything).Return(tc.mockResponse, tc.err)
+			request, er := http.NewRequest("POST", "/api/suggestions", tc.requestBody)
+			checkError(er, t)
+
+			chi := chi.NewRouter()
+			rr := httptest.NewRecorder()
+			chi.Post("/api/suggestions", func(w http.ResponseWriter, r *http.Request) {
+				handler.GetSuggestions(w, r)
+			})
+			chi.ServeHTTP(rr, request)
+			assert.Equal(t, tc.statusCode, rr.Code)
+			assert.JSONEq(t, tc.jsonResponse, rr.Body.String())
+		})
+	}
+}
+
+func TestGetPathBlock(t *testing.T) {
+	current := time.Now().Format("2006-01-02 15:04:05")
+
+	testCases := []struct {
+		name         string
+		statusCode   int
+		mockResponse []string
+		requestBody  *bytes.Buffer
+		err          error
+		jsonResponse string
+	}{
+		{
+			name:         "Get path succeed",
+			statusCode:   http.StatusOK,
+			mockResponse: []string{"quan@gmail.com", "hau@gmail.com", "len@gmail.com"},
+			requestBody:  bytes.NewBufferString(`{"from": "quan@gmail.com", "to": "len@gmail.com"}`),
+			jsonResponse: string(`{
+								"success": true,
+								"found": true,
+								"path": ["quan@gmail.com", "hau@gmail.com", "len@gmail.com"],
+								"degrees": 2
+							}`),
+			err: nil,
+		},
+		{
+			name:         "Get path not found",
+			statusCode:   http.StatusOK,
+			mockResponse: nil,
+			requestBody:  bytes.NewBufferString(`{"from": "quan@gmail.com", "to": "len@gmail.com"}`),
+			jsonResponse: string(`{
+								"success": true,
+								"found": false,
+								"path": [],
+								"degrees": 0
+							}`),
+			err: nil,
+		},
+		{
+			name:        "Get path email not exist",
+			statusCode:  http.StatusBadRequest,
+			requestBody: bytes.NewBufferString(`{"from": "quan@gmail.com", "to": "len@gmail.com"}`),
+			jsonResponse: fmt.Sprintf(`{
+									"success": false,
+									"text": "email: len@gmail.com is not exist in database",
+									"timestamp": "%s"
+								}`, current),
+			err: errors.New("email: len@gmail.com is not exist in database"),
+		},
+		{
+			name:        "Get path empty email",
+			statusCode:  http.StatusBadRequest,
+			requestBody: bytes.NewBufferString(`{"from": "quan@gmail.com"}`),
+			jsonResponse: fmt.Sprintf(`{
+									"success": false,
+									"text": "from and to must not be empty",
+									"timestamp": "%s"
+								}`, current),
+			err: nil,
+		},
+		{
+			name:        "Get path invalid request",
+			statusCode:  http.StatusInternalServerError,
+			requestBody: bytes.NewBuffer(nil),
+			jsonResponse: fmt.Sprintf(`{
+									"success": false,
+									"text": "EOF",
+									"timestamp": "%s"
+								}`, current),
+			err: nil,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := new(mocks.RelationService)
+			handler := RelationHandler{
+				service: mockService,
+			}
+			mockService.On("GetPath", mock.Anything, mock.Anything).Return(tc.mockResponse, tc.err)
+			request, er := http.NewRequest("POST", "/api/path", tc.requestBody)
+			checkError(er, t)
+
+			chi := chi.NewRouter()
+			rr := httptest.NewRecorder()
+			chi.Post("/api/path", func(w http.ResponseWriter, r *http.Request) {
+				handler.GetPath(w, r)
+			})
+			chi.ServeHTTP(rr, request)
+			assert.Equal(t, tc.statusCode, rr.Code)
+			assert.JSONEq(t, tc.jsonResponse, rr.Body.String())
+		})
+	}
+}
+
+func TestGetInboxBlock(t *testing.T) {
+	current := time.Now().Format("2006-01-02 15:04:05")
+	created := time.Date(2021, 5, 6, 14, 20, 59, 0, time.UTC)
+
+	testCases := []struct {
+		name         string
+		statusCode   int
+		mockResponse model.InboxPage
+		requestBody  *bytes.Buffer
+		err          error
+		jsonResponse string
+	}{
+		{
+			name:       "Get inbox succeed",
+			statusCode: http.StatusOK,
+			mockResponse: model.InboxPage{
+				Items: []model.InboxItem{
+					{Id: "3", Update: model.Update{Id: "1", Sender: "quang@gmail.com", Text: "hello", CreatedAt: created}},
+				},
+				NextCursor: "Mw",
+				Total:      2,
+				Unread:     1,
+			},
+			requestBody: bytes.NewBufferString(`{"email": "quan@gmail.com", "limit": 1}`),
+			jsonResponse: string(`{
+								"success": true,
+								"items": [
+									{
+										"id": "3",
+										"update": {
+											"id": "1",
+											"sender": "quang@gmail.com",
+											"text": "hello",
+											"created_at": "2021-05-06T14:20:59Z"
+										},
+										"read": false
+									}
+								],
+								"count": 1,
+								"total": 2,
+								"unread": 1,
+								"next_cursor": "Mw"
+							}`),
+			err: nil,
+		},
+		{
+			name:        "Get inbox not stored",
+			statusCode:  http.StatusNotImplemented,
+			requestBody: bytes.NewBufferString(`{"email": "quan@gmail.com"}`),
+			jsonResponse: fmt.Sprintf(`{
+									"success": false,
+									"text": "updates are not stored by this server",
+									"timestamp": "%s"
+								}`, current),
+			err: service.ErrUpdatesNotStored,
+		},
+		{
+			name:        "Get inbox invalid limit",
+			statusCode:  http.StatusBadRequest,
+			requestBody: bytes.NewBufferString(`{"email": "quan@gmail.com", "limit": 1001}`),
+			jsonResponse: fmt.Sprintf(`{
+									"success": false,
+									"text": "limit must be between 0 and 1000",
+									"timestamp": "%s"
+								}`, current),
+			err: nil,
+		},
+		{
+			name:        "Get inbox invalid request",
+			statusCode:  http.StatusInternalServerError,
+			requestBody: bytes.NewBuffer(nil),
+			jsonResponse: fmt.Sprintf(`{
+									"success": false,
+									"text": "EOF",
+									"timestamp": "%s"
+								}`, current),
+			err: nil,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := new(mocks.RelationService)
+			handler := RelationHandler{
+				service: mockService,
+			}
+			mockService.On("GetInbox", mock.Anything, mock.Anything).Return(tc.mockResponse, tc.err)
+			request, er := http.NewRequest("POST", "/api/inbox", tc.requestBody)
+			checkError(er, t)
+
+			chi := chi.NewRouter()
+			rr := httptest.NewRecorder()
+			chi.Post("/api/inbox", func(w http.ResponseWriter, r *http.Request) {
+				handler.GetInbox(w, r)
+			})
+			chi.ServeHTTP(rr, request)
+			assert.Equal(t, tc.statusCode, rr.Code)
+			assert.JSONEq(t, tc.jsonResponse, rr.Body.String())
+		})
+	}
+}
+
+func TestMarkReadBlock(t *testing.T) {
+	current := time.Now().Format("2006-01-02 15:04:05")
+
+	testCases := []struct {
+		name         string
+		statusCode   int
+		mockResponse int
+		requestBody  *bytes.Buffer
+		err          error
+		jsonResponse string
+	}{
+		{
+			name:         "Mark read succeed",
+			statusCode:   http.StatusOK,
+			mockResponse: 2,
+			requestBody:  bytes.NewBufferString(`{"email": "quan@gmail.com", "ids": ["3", "4"]}`),
+			jsonResponse: string(`{
+								"success": true,
+								"marked": 2
+							}`),
+			err: nil,
+		},
+		{
+			name:        "Mark read email not exist",
+			statusCode:  http.StatusBadRequest,
+			requestBody: bytes.NewBufferString(`{"email": "quan@gmail.com", "ids": ["3"]}`),
+			jsonResponse: fmt.Sprintf(`{
+									"success": false,
+									"text": "email: quan@gmail.com is not exist in database",
+									"timestamp": "%s"
+								}`, current),
+			err: errors.New("email: quan@gmail.com is not exist in database"),
+		},
+		{
+			name:        "Mark read empty ids",
+			statusCode:  http.StatusBadRequest,
+			requestBody: bytes.NewBufferString(`{"email": "quan@gmail.com"}`),
+			jsonResponse: fmt.Sprintf(`{
+									"success": false,
+									"text": "ids must not be empty",
+									"timestamp": "%s"
+								}`, current),
+			err: nil,
+		},
+		{
+			name:        "Mark read invalid id",
+			statusCode:  http.StatusBadRequest,
+			requestBody: bytes.NewBufferString(`{"email": "quan@gmail.com", "ids": ["x"]}`),
+			jsonResponse: fmt.Sprintf(`{
+									"success": false,
+									"text": "invalid inbox item id: x",
+									"timestamp": "%s"
+								}`, current),
+			err: nil,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := new(mocks.RelationService)
+			handler := RelationHandler{
+				service: mockService,
+			}
+			mockService.On("MarkRead", mock.Anything, mock.Anything).Return(tc.mockResponse, tc.err)
+			request, er := http.NewRequest("POST", "/api/inbox/read", tc.requestBody)
+			checkError(er, t)
+
+			chi := chi.NewRouter()
+			rr := httptest.NewRecorder()
+			chi.Post("/api/inbox/read", func(w http.ResponseWriter, r *http.Request) {
+				handler.MarkRead(w, r)
+			})
+			chi.ServeHTTP(rr, request)
+			assert.Equal(t, tc.statusCode, rr.Code)
+			assert.JSONEq(t, tc.jsonResponse, rr.Body.String())
+		})
+	}
+}
+
+func TestRegisterWebhookBlock(t *testing.T) {
+	current := time.Now().Format("2006-01-02 15:04:05")
+
+	testCases := []struct {
+		name         string
+		statusCode   int
+		requestBody  *bytes.Buffer
+		err          error
+		jsonResponse string
+	}{
+		{
+			name:         "Register webhook succeed",
+			statusCode:   http.StatusOK,
+			requestBody:  bytes.NewBufferString(`{"email": "quan@gmail.com", "url": "https://example.com/hook"}`),
+			jsonResponse: `{"success": true}`,
+			err:          nil,
+		},
+		{
+			name:        "Register webhook invalid url",
+			statusCode:  http.StatusBadRequest,
+			requestBody: bytes.NewBufferString(`{"email": "quan@gmail.com", "url": "example.com/hook"}`),
+			jsonResponse: fmt.Sprintf(`{
+									"success": false,
+									"text": "url must be an http or https URL",
+									"timestamp": "%s"
+								}`, current),
+			err: nil,
+		},
+		{
+			name:        "Register webhook disabled",
+			statusCode:  http.StatusNotImplemented,
+			requestBody: bytes.NewBufferString(`{"email": "quan@gmail.com", "url": ""}`),
+			jsonResponse: fmt.Sprintf(`{
+									"success": false,
+									"text": "webhooks are not enabled on this server",
+									"timestamp": "%s"
+								}`, current),
+			err: service.ErrWebhooksDisabled,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := new(mocks.RelationService)
+			handler := RelationHandler{
+				service: mockService,
+			}
+			mockService.On("RegisterWebhook", mock.Anything, mock.Anything).Return(tc.err)
+			request, er := http.NewRequest("POST", "/api/webhook", tc.requestBody)
+			checkError(er, t)
+
+			chi := chi.NewRouter()
+			rr := httptest.NewRecorder()
+			chi.Post("/api/webhook", func(w http.ResponseWriter, r *http.Request) {
+				handler.RegisterWebhook(w, r)
+			})
+			chi.ServeHTTP(rr, request)
+			assert.Equal(t, tc.statusCode, rr.Code)
+			assert.JSONEq(t, tc.jsonResponse, rr.Body.String())
+		})
+	}
+}
+
+func TestGetDeliveriesBlock(t *testing.T) {
+	current := time.Now().Format("2006-01-02 15:04:05")
+	at := time.Date(2021, 5, 6, 14, 20, 59, 0, time.UTC)
+
+	testCases := []struct {
+		name         string
+		statusCode   int
+		mockResponse model.DeliveryPage
+		requestBody  *bytes.Buffer
+		err          error
+		jsonResponse string
+	}{
+		{
+			name:       "Get dead letters succeed",
+			statusCode: http.StatusOK,
+			mockResponse: model.DeliveryPage{
+				Deliveries: []model.Delivery{{
+					Id:        "4",
+					UpdateId:  "2",
+					Recipient: "quang@gmail.com",
+					Url:       "https://example.com/hook",
+					Status:    model.DeliveryDead,
+					Attempts:  []model.DeliveryAttempt{{Attempt: 1, StatusCode: 500, Error: "unexpected status 500 Internal Server Error", AttemptedAt: at}},
+				}},
+				NextCursor: "NA",
+			},
+			requestBody: bytes.NewBufferString(`{"limit": 1}`),
+			jsonResponse: `{
+								"success": true,
+								"deliveries": [{
+									"id": "4",
+									"update_id": "2",
+									"recipient": "quang@gmail.com",
+									"url": "https://example.com/hook",
+									"status": "DEAD",
+									"attempts": [{
+										"attempt": 1,
+										"status_code": 500,
+										"error": "unexpected status 500 Internal Server Error",
+										"attempted_at": "2021-05-06T14:20:59Z"
+									}]
+								}],
+								"count": 1,
+								"next_cursor": "NA"
+							}`,
+			err: nil,
+		},
+		{
+			name:        "Get deliveries invalid status",
+			statusCode:  http.StatusBadRequest,
+			requestBody: bytes.NewBufferString(`{"status": "LOST"}`),
+			jsonResponse: fmt.Sprintf(`{
+									"success": false,
+									"text": "invalid delivery status: LOST",
+									"timestamp": "%s"
+								}`, current),
+			err: nil,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := new(mocks.RelationService)
+			handler := RelationHandler{
+				service: mockService,
+			}
+			mockService.On("GetDeliveries", mock.Anything, mock.Anything).Return(tc.mockResponse, tc.err)
+			request, er := http.NewRequest("POST", "/api/webhook/deliveries", tc.requestBody)
+			checkError(er, t)
+
+			chi := chi.NewRouter()
+			rr := httptest.NewRecorder()
+			chi.Post("/api/webhook/deliveries", func(w http.ResponseWriter, r *http.Request) {
+				handler.GetDeliveries(w, r)
+			})
+			chi.ServeHTTP(rr, request)
+			assert.Equal(t, tc.statusCode, rr.Code)
+			assert.JSONEq(t, tc.jsonResponse, rr.Body.String())
+		})
+	}
+}
+
+func TestRegisterEmailBlock(t *testing.T) {
+	jsonStr := []byte(`{"email" : "quan@gmail.com"}`)
+	jsonStr2 := []byte(`{"email" : "quangmail.com"}`)
+	current := time.Now().Format("2006-01-02 15:04:05")
+
+	testCases := []struct {
+		name         string
+		statusCode   int
+		mockResponse model.User
+		requestBody  *bytes.Buffer
+		err          error
+		jsonResponse string
+	}{
+		{
+			name:         "Register email succeed",
+			statusCode:   http.StatusOK,
+			mockResponse: model.User{Id: "6", Email: "quan@gmail.com"},
+			requestBody:  bytes.NewBuffer(jsonStr),
+			jsonResponse: string(`{
+								"success": true,
+								"user": {
+									"id": "6",
+									"email": "quan@gmail.com"
+								}
+							}`),
+			err: nil,
+		},
+		{
+			name:        "Register email already registered",
+			statusCode:  http.StatusBadRequest,
+			requestBody: bytes.NewBuffer(jsonStr),
+			jsonResponse: fmt.Sprintf(`{
+									"success": false,
+									"text": "email: quan@gmail.com is already registered",
+									"timestamp": "%s"
+								}`, current),
+			err: errors.New("email: quan@gmail.com is already registered"),
+		},
+		{
+			name:        "Register invalid email",
+			statusCode:  http.StatusBadRequest,
+			requestBody: bytes.NewBuffer(jsonStr2),
+			jsonResponse: fmt.Sprintf(`{
+									"success": false,
+									"text": "invalid email format",
+									"timestamp": "%s"
+								}`, current),
+			err: nil,
+		},
+		{
+			name:        "Register invalid request",
+			statusCode:  http.StatusInternalServerError,
+			requestBody: bytes.NewBuffer(nil),
+			jsonResponse: fmt.Sprintf(`{
+									"success": false,
+									"text": "EOF",
+									"timestamp": "%s"
+								}`, current),
+			err: nil,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := new(mocks.RelationService)
+			handler := RelationHandler{
+				service: mockService,
+			}
+			mockService.On("RegisterEmail", mock.Anything, mock.Anything).Return(tc.mockResponse, tc.err)
+			request, er := http.NewRequest("POST", "/api/register", tc.requestBody)
+			checkError(er, t)
+
+			chi := chi.NewRouter()
+			rr := httptest.NewRecorder()
+			chi.Post("/api/register", func(w http.ResponseWriter, r *http.Request) {
+				handler.RegisterEmail(w, r)
+			})
+			chi.ServeHTTP(rr, request)
+			assert.Equal(t, tc.statusCode, rr.Code)
+			assert.JSONEq(t, tc.jsonResponse, rr.Body.String())
+		})
+	}
+}
+
+func TestUsersInMemory(t *testing.T) {
+	handler := RelationHandler{
+		service: service.NewRelationService(repos.NewRelationRepoMemory()),
+	}
+
+	chi := chi.NewRouter()
+	chi.Post("/api/register", func(w http.ResponseWriter, r *http.Request) {
+		handler.RegisterEmail(w, r)
+	})
+	chi.Post("/api/user", func(w http.ResponseWriter, r *http.Request) {
+		handler.GetUser(w, r)
+	})
+	chi.Get("/api/users", func(w http.ResponseWriter, r *http.Request) {
+		handler.GetUsers(w, r)
+	})
+
+	for _, code := range []int{http.StatusOK, http.StatusBadRequest} {
+		request, er := http.NewRequest("POST", "/api/register", bytes.NewBufferString(`{"email": "quan@gmail.com"}`))
+		checkError(er, t)
+		rr := httptest.NewRecorder()
+		chi.ServeHTTP(rr, request)
+		assert.Equal(t, code, rr.Code)
+	}
+
+	request, er := http.NewRequest("POST", "/api/user", bytes.NewBufferString(`{"email": "quan@gmail.com"}`))
+	checkError(er, t)
+	rr := httptest.NewRecorder()
+	chi.ServeHTTP(rr, request)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"success": true, "user": {"id": "1", "email": "quan@gmail.com"}}`, rr.Body.String())
+
+	request, er = http.NewRequest("GET", "/api/users", nil)
+	checkError(er, t)
+	rr = httptest.NewRecorder()
+	chi.ServeHTTP(rr, request)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{
+						"success": true,
+						"users": [
+							{"id": "1", "email": "quan@gmail.com"}
+						],
+						"count": 1
+					}`, rr.Body.String())
+}
+
+func TestInMemoryBackend(t *testing.T) {
+	repo := repos.NewRelationRepoMemory()
+	repo.AddEmail("quan@gmail.com")
+	repo.AddEmail("quang@gmail.com")
+	repo.AddEmail("hau@gmail.com")
+	handler := RelationHandler{
+		service: service.NewRelationService(repo),
+	}
+
+	chi := chi.NewRouter()
+	chi.Post("/api/add", func(w http.ResponseWriter, r *http.Request) {
+		handler.AddFriend(w, r)
+	})
+	chi.Post("/api/friends", func(w http.ResponseWriter, r *http.Request) {
+		handler.GetFriendsEmail(w, r)
+	})
+
+	for _, friend := range []string{"quang@gmail.com", "hau@gmail.com"} {
+		body := bytes.NewBufferString(fmt.Sprintf(`{"friends": ["quan@gmail.com", "%s"]}`, friend))
+		request, er := http.NewRequest("POST", "/api/add", body)
+		checkError(er, t)
+		rr := httptest.NewRecorder()
+		chi.ServeHTTP(rr, request)
+		assert.Equal(t, http.StatusOK, rr.Code)
+	}
+
+	request, er := http.NewRequest("POST", "/api/friends", bytes.NewBufferString(`{"email": "quan@gmail.com"}`))
+	checkError(er, t)
+	rr := httptest.NewRecorder()
+	chi.ServeHTTP(rr, request)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{
+						"success": true,
+						"friends": [
+							"quang@gmail.com",
+							"hau@gmail.com"
+						],
+						"count": 2,
+						"total": 2
+					}`, rr.Body.String())
+}
+
+func TestGetAuditRecordsBlock(t *testing.T) {
+	current := time.Now().Format("2006-01-02 15:04:05")
+	at := time.Date(2021, 5, 6, 14, 20, 59, 0, time.UTC)
+
+	testCases := []struct {
+		name         string
+		statusCode   int
+		mockResponse model.AuditPage
+		requestBody  *bytes.Buffer
+		err          error
+		jsonResponse string
+	}{
+		{
+			name:       "Get audit records succeed",
+			statusCode: http.StatusOK,
+			mockResponse: model.AuditPage{
+				Records: []model.AuditRecord{{
+					Id:        "3",
+					Actor:     "quan@gmail.com",
+					Target:    "quang@gmail.com",
+					Action:    model.AuditAdd,
+					NewStatus: model.StatusBlock,
+					RequestId: "host/abc-000001",
+					CreatedAt: at,
+				}},
+			},
+			requestBody: bytes.NewBufferString(`{"email": "quan@gmail.com", "from": "2021-05-01T00:00:00Z"}`),
+			jsonResponse: `{
+								"success": true,
+								"records": [{
+									"id": "3",
+									"actor": "quan@gmail.com",
+									"target": "quang@gmail.com",
+									"action": "ADD",
+									"old_status": "",
+									"new_status": "BLOCK",
+									"request_id": "host/abc-000001",
+									"created_at": "2021-05-06T14:20:59Z"
+								}],
+								"count": 1
+							}`,
+			err: nil,
+		},
+		{
+			name:        "Get audit records empty range",
+			statusCode:  http.StatusBadRequest,
+			requestBody: bytes.NewBufferString(`{"email": "quan@gmail.com", "from": "2021-05-02T00:00:00Z", "to": "2021-05-01T00:00:00Z"}`),
+			jsonResponse: fmt.Sprintf(`{
+									"success": false,
+									"text": "from must be before to",
+									"timestamp": "%s"
+								}`, current),
+			err: nil,
+		},
+		{
+			name:        "Get audit records email not exist",
+			statusCode:  http.StatusBadRequest,
+			requestBody: bytes.NewBufferString(`{"email": "quan@gmail.com"}`),
+			jsonResponse: fmt.Sprintf(`{
+									"success": false,
+									"text": "email: quan@gmail.com is not exist in database",
+									"timestamp": "%s"
+								}`, current),
+			err: errors.New("email: quan@gmail.com is not exist in database"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := new(mocks.RelationService)
+			handler := RelationHandler{
+				service: mockService,
+			}
+			mockService.On("GetAuditRecords", mock.Anything, mock.Anything).Return(tc.mockResponse, tc.err)
+			request, er := http.NewRequest("POST", "/api/audit", tc.requestBody)
+			checkError(er, t)
+
+			chi := chi.NewRouter()
+			rr := httptest.NewRecorder()
+			chi.Post("/api/audit", func(w http.ResponseWriter, r *http.Request) {
+				handler.GetAuditRecords(w, r)
+			})
+			chi.ServeHTTP(rr, request)
+			assert.Equal(t, tc.statusCode, rr.Code)
+			assert.JSONEq(t, tc.jsonResponse, rr.Body.String())
+		})
+	}
+}
+
+func TestAuditInMemory(t *testing.T) {
+	repo := repos.NewRelationRepoMemory()
+	repo.AddEmail("quan@gmail.com")
+	repo.AddEmail("quang@gmail.com")
+	handler := RelationHandler{
+		service: service.NewRelationService(repo),
+	}
+
+	chi := chi.NewRouter()
+	chi.Use(middleware.RequestID)
+	chi.Post("/api/block", func(w http.ResponseWriter, r *http.Request) {
+		handler.BlockEmail(w, r)
+	})
+	chi.Post("/api/audit", func(w http.ResponseWriter, r *http.Request) {
+		handler.GetAuditRecords(w, r)
+	})
+
+	request, er := http.NewRequest("POST", "/api/block", bytes.NewBufferString(`{"requestor": "quan@gmail.com", "target": "quang@gmail.com"}`))
+	checkError(er, t)
+	request.Header.Set(middleware.RequestIDHeader, "support-1234")
+	rr := httptest.NewRecorder()
+	chi.ServeHTTP(rr, request)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	request, er = http.NewRequest("POST", "/api/audit", bytes.NewBufferString(`{"email": "quang@gmail.com"}`))
+	checkError(er, t)
+	rr = httptest.NewRecorder()
+	chi.ServeHTTP(rr, request)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var response model.AuditResponse
+	checkError(json.Unmarshal(rr.Body.Bytes(), &response), t)
+	assert.Equal(t, 1, response.Count)
+	assert.Equal(t, "quan@gmail.com", response.Records[0].Actor)
+	assert.Equal(t, model.StatusBlock, response.Records[0].NewStatus)
+	assert.Equal(t, "support-1234", response.Records[0].RequestId)
+}
+
+func TestAuthentication(t *testing.T) {
+	cfg := config.Default()
+	cfg.Memory = true
+	cfg.AutoProvision = true
+	cfg.Auth.APIKeys = []config.APIKey{
+		{Name: "mobile", Hash: auth.HashKey("key-1"), Email: "quan@gmail.com"},
+		{Name: "support", Hash: auth.HashKey("key-2"), Email: "support@gmail.com", Admin: true},
+	}
+	r, closer, err := SetUpRouter(cfg)
+	checkError(err, t)
+	defer closer()
+
+	testCases := []struct {
+		name           string
+		apiKey         string
+		body           string
+		expectedStatus int
+	}{
+		{
+			name:           "No API key",
+			body:           `{"requestor": "quan@gmail.com", "target": "quang@gmail.com"}`,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Unknown API key",
+			apiKey:         "key-3",
+			body:           `{"requestor": "quan@gmail.com", "target": "quang@gmail.com"}`,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Requestor of the API key",
+			apiKey:         "key-1",
+			body:           `{"requestor": "quan@gmail.com", "target": "quang@gmail.com"}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Requestor other than the API key",
+			apiKey:         "key-1",
+			body:           `{"requestor": "quang@gmail.com", "target": "hau@gmail.com"}`,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "Admin API key",
+			apiKey:         "key-2",
+			body:           `{"requestor": "quang@gmail.com", "target": "hau@gmail.com"}`,
+			expectedStatus: http.StatusOK,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			request, er := http.NewRequest("POST", "/api/subcribe", bytes.NewBufferString(testCase.body))
+			checkError(er, t)
+			if testCase.apiKey != "" {
+				request.Header.Set(auth.APIKeyHeader, testCase.apiKey)
+			}
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, request)
+			assert.Equal(t, testCase.expectedStatus, rr.Code)
+			if testCase.expectedStatus != http.StatusOK {
+				var response model.ErrorResponse
+				checkError(json.Unmarshal(rr.Body.Bytes(), &response), t)
+				assert.False(t, response.Success)
+			}
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	cfg := config.Default()
+	cfg.Memory = true
+	cfg.AutoProvision = true
+	cfg.RateLimit.Endpoints = map[string]config.Rate{"/api/add": {Burst: 2, Per: time.Minute}}
+	r, closer, err := SetUpRouter(cfg)
+	checkError(err, t)
+	defer closer()
+
+	for i, code := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		body := bytes.NewBufferString(fmt.Sprintf(`{"friends": ["quan%d@gmail.com", "quang@gmail.com"]}`, i))
+		request, er := http.NewRequest("POST", "/api/add", body)
+		checkError(er, t)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, request)
+		assert.Equal(t, code, rr.Code)
+		assert.Equal(t, "2", rr.Header().Get("X-RateLimit-Limit"))
+		if code == http.StatusTooManyRequests {
+			assert.Equal(t, "30", rr.Header().Get("Retry-After"))
+			var response model.ErrorResponse
+			checkError(json.Unmarshal(rr.Body.Bytes(), &response), t)
+			assert.False(t, response.Success)
+			assert.Equal(t, "too many requests to /api/add", response.Error)
+		}
+	}
+}
+
 func checkError(err error, t *testing.T) {
 	if err != nil {
 		t.Errorf("An error occurred. %v", err)
@@ -1,23 +1,76 @@
 package router
 
 import (
+	"friend-management-v1/db/migration"
+	"friend-management-v1/internal/auth"
+	"friend-management-v1/internal/config"
+	"friend-management-v1/internal/event"
+	"friend-management-v1/internal/mail"
+	"friend-management-v1/internal/migrate"
+	"friend-management-v1/internal/ratelimit"
 	"friend-management-v1/internal/repos"
 	"friend-management-v1/internal/service"
 	"friend-management-v1/internal/utils"
-
+	"friend-management-v1/internal/webhook"
+	"net"
 	"net/http"
+	"os"
+	"strconv"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 )
 
-func SetUpRouter() *chi.Mux {
-	db := utils.DBConnection()
-	relation_repo := repos.NewRelationRepo(db)
-	relation_service := service.NewRelationService(relation_repo)
+// SetUpRouter builds the API on top of the storage selected by cfg. The
+// returned function stops the webhook deliveries and the mails, then releases
+// the storage, such as the database pool.
+func SetUpRouter(cfg config.Config) (*chi.Mux, func() error, error) {
+	var templates *mail.Templates
+	if cfg.SMTP.Host != "" {
+		var err error
+		if templates, err = mail.LoadTemplates(cfg.SMTP.TemplateDir); err != nil {
+			return nil, nil, err
+		}
+	}
+	storage, err := newStorage(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	guard := webhook.NewGuard(cfg.Webhook.AllowedNetworks)
+	dispatcher := webhook.NewDispatcher(storage.webhooks,
+		webhook.WithWorkers(cfg.Webhook.Workers),
+		webhook.WithQueueSize(cfg.Webhook.QueueSize),
+		webhook.WithRetries(cfg.Webhook.MaxAttempts, cfg.Webhook.Backoff, cfg.Webhook.MaxBackoff),
+		webhook.WithClient(guard.Client(cfg.Webhook.Timeout)),
+		webhook.WithResume(storage.updates))
+	bus := event.NewBus()
+	bus.Subscribe(dispatcher.Handle, event.UpdatePostedName)
+	// the bus is closed first, its subscribers may still queue deliveries
+	closers := []func() error{bus.Close, dispatcher.Close}
+	if templates != nil {
+		mailer := newMailer(cfg.SMTP, templates)
+		bus.Subscribe(mailer.Handle, event.FriendAddedName, event.SubscribedName, event.UpdatePostedName)
+		closers = append(closers, mailer.Close)
+	}
+	closer := func() error {
+		for _, stop := range closers {
+			stop()
+		}
+		return storage.close()
+	}
+	relation_service := service.NewRelationService(storage.relations,
+		service.WithTimeouts(cfg.OperationTimeout, cfg.OperationTimeouts),
+		service.WithAutoProvision(cfg.AutoProvision),
+		service.WithMaxPathDepth(cfg.PathMaxDepth),
+		service.WithUpdateRepo(storage.updates),
+		service.WithWebhookRepo(storage.webhooks),
+		service.WithWebhookURLCheck(guard.CheckURL),
+		service.WithEventBus(bus))
 	relation_handler := RelationHandler{
 		service: relation_service,
 	}
+	authenticator := newAuthenticator(cfg.Auth)
+	limiter := newLimiter(cfg.RateLimit)
 
 	r := chi.NewRouter()
 
@@ -27,24 +80,161 @@ func SetUpRouter() *chi.Mux {
 	r.Use(middleware.URLFormat)
 
 	r.Route("/api", func(r chi.Router) {
+		if authenticator.Enabled() {
+			r.Use(authenticator.Middleware)
+		}
+		if limiter.Enabled() {
+			r.Use(limiter.Middleware)
+		}
 		r.Post("/friends", func(w http.ResponseWriter, r *http.Request) {
 			relation_handler.GetFriendsEmail(w, r)
 		})
 		r.Post("/add", func(w http.ResponseWriter, r *http.Request) {
 			relation_handler.AddFriend(w, r)
 		})
+		r.Post("/unfriend", func(w http.ResponseWriter, r *http.Request) {
+			relation_handler.Unfriend(w, r)
+		})
 		r.Post("/common", func(w http.ResponseWriter, r *http.Request) {
 			relation_handler.GetCommonFriends(w, r)
 		})
 		r.Post("/subcribe", func(w http.ResponseWriter, r *http.Request) {
 			relation_handler.SubcribeToEmail(w, r)
 		})
+		r.Post("/unsubcribe", func(w http.ResponseWriter, r *http.Request) {
+			relation_handler.UnsubcribeFromEmail(w, r)
+		})
 		r.Post("/block", func(w http.ResponseWriter, r *http.Request) {
 			relation_handler.BlockEmail(w, r)
 		})
+		r.Post("/unblock", func(w http.ResponseWriter, r *http.Request) {
+			relation_handler.UnblockEmail(w, r)
+		})
 		r.Post("/retrieve", func(w http.ResponseWriter, r *http.Request) {
 			relation_handler.GetRetrivableEmails(w, r)
 		})
+		r.Post("/suggestions", func(w http.ResponseWriter, r *http.Request) {
+			relation_handler.GetSuggestions(w, r)
+		})
+		r.Post("/path", func(w http.ResponseWriter, r *http.Request) {
+			relation_handler.GetPath(w, r)
+		})
+		r.Post("/inbox", func(w http.ResponseWriter, r *http.Request) {
+			relation_handler.GetInbox(w, r)
+		})
+		r.Post("/inbox/read", func(w http.ResponseWriter, r *http.Request) {
+			relation_handler.MarkRead(w, r)
+		})
+		r.Post("/webhook", func(w http.ResponseWriter, r *http.Request) {
+			relation_handler.RegisterWebhook(w, r)
+		})
+		r.Post("/webhook/deliveries", func(w http.ResponseWriter, r *http.Request) {
+			relation_handler.GetDeliveries(w, r)
+		})
+		r.Post("/audit", func(w http.ResponseWriter, r *http.Request) {
+			relation_handler.GetAuditRecords(w, r)
+		})
+		r.Post("/register", func(w http.ResponseWriter, r *http.Request) {
+			relation_handler.RegisterEmail(w, r)
+		})
+		r.Post("/user", func(w http.ResponseWriter, r *http.Request) {
+			relation_handler.GetUser(w, r)
+		})
+		r.Get("/users", func(w http.ResponseWriter, r *http.Request) {
+			relation_handler.GetUsers(w, r)
+		})
 	})
-	return r
+	return r, closer, nil
+}
+
+func newMailer(cfg config.SMTP, templates *mail.Templates) *mail.Notifier {
+	opts := []mail.Option{
+		mail.WithTemplates(templates),
+		mail.WithQueueSize(cfg.QueueSize),
+		mail.WithRetries(cfg.MaxAttempts, cfg.Backoff, cfg.MaxBackoff),
+	}
+	if cfg.Username != "" {
+		opts = append(opts, mail.WithAuth(cfg.Username, cfg.Password, cfg.Host))
+	}
+	return mail.NewNotifier(net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)), cfg.From, opts...)
+}
+
+func newAuthenticator(cfg config.Auth) *auth.Authenticator {
+	var opts []auth.Option
+	for _, key := range cfg.APIKeys {
+		opts = append(opts, auth.WithAPIKey(key.Name, key.Hash, key.Email, key.Admin))
+	}
+	if cfg.JWTSecret != "" {
+		opts = append(opts, auth.WithJWT([]byte(cfg.JWTSecret), cfg.JWTIssuer))
+	}
+	return auth.NewAuthenticator(opts...)
+}
+
+func newLimiter(cfg config.RateLimit) *ratelimit.Limiter {
+	endpoints := make(map[string]ratelimit.Rate, len(cfg.Endpoints))
+	for path, rate := range cfg.Endpoints {
+		endpoints[path] = ratelimit.Rate(rate)
+	}
+	return ratelimit.New(
+		ratelimit.WithClientRate(ratelimit.Rate(cfg.Client)),
+		ratelimit.WithRequestorRate(ratelimit.Rate(cfg.Requestor)),
+		ratelimit.WithEndpointRate(ratelimit.Rate(cfg.Endpoint), endpoints))
+}
+
+// storage holds the repositories of one backend and the function releasing it.
+type storage struct {
+	relations repos.RelationRepo
+	updates   repos.UpdateRepo
+	webhooks  repos.WebhookRepo
+	close     func() error
+}
+
+func newStorage(cfg config.Config) (storage, error) {
+	if !cfg.Memory {
+		if cfg.AutoMigrate {
+			if err := migrateUp(cfg.DB); err != nil {
+				return storage{}, err
+			}
+		}
+		db, err := utils.DBConnection(cfg.DB)
+		if err != nil {
+			return storage{}, err
+		}
+		return storage{
+			relations: repos.NewRelationRepo(db),
+			updates:   repos.NewUpdateRepo(db),
+			webhooks:  repos.NewWebhookRepo(db),
+			close:     db.Close,
+		}, nil
+	}
+	repo := repos.NewRelationRepoMemory()
+	if cfg.SeedFile != "" {
+		f, err := os.Open(cfg.SeedFile)
+		if err != nil {
+			return storage{}, err
+		}
+		defer f.Close()
+		if err := repo.Seed(f); err != nil {
+			return storage{}, err
+		}
+	}
+	return storage{
+		relations: repo,
+		updates:   repos.NewUpdateRepoMemory(repo),
+		webhooks:  repos.NewWebhookRepoMemory(repo),
+		close:     func() error { return nil },
+	}, nil
+}
+
+func migrateUp(cfg config.DB) error {
+	db, err := utils.DBConnection(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	m, err := migrate.New(db, migration.Files)
+	if err != nil {
+		return err
+	}
+	return m.Up()
 }
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS friend_relationship;
+DROP TABLE IF EXISTS email;
@@ -1,4 +1,4 @@
-CREATE TABLE IF NOT EXISTS =email (
+CREATE TABLE IF NOT EXISTS email (
 	email_id int8 NOT NULL GENERATED ALWAYS AS IDENTITY,
 	email varchar(255) NOT NULL,
 	CONSTRAINT email_pk PRIMARY KEY (email_id)
@@ -30,4 +30,4 @@ values (5, 2, 'FRIEND'),
 (1, 3, 'BLOCK'),
 (2, 4, 'SUBSCRIBE'),
 (3, 1, 'FRIEND'),
-(4, 1, 'FRIEND');
\ No newline at end of file
+(4, 1, 'FRIEND');
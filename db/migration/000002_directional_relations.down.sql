@@ -0,0 +1,8 @@
+-- The FRIEND rows added by the up migration are kept, they are valid either way.
+
+insert into friend_relationship (your_id, friend_id, status)
+select fr.friend_id, fr.your_id, fr.status
+from friend_relationship fr
+where fr.status != 'FRIEND' and not exists (
+	select 1 from friend_relationship r
+	where r.your_id = fr.friend_id and r.friend_id = fr.your_id and r.status = fr.status);
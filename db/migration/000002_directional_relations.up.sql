@@ -0,0 +1,17 @@
+-- FRIEND is stored in both directions, SUBCRIBE and BLOCK only from the
+-- requestor to the target.
+
+insert into friend_relationship (your_id, friend_id, status)
+select fr.friend_id, fr.your_id, fr.status
+from friend_relationship fr
+where fr.status = 'FRIEND' and not exists (
+	select 1 from friend_relationship r
+	where r.your_id = fr.friend_id and r.friend_id = fr.your_id and r.status = fr.status);
+
+-- AddRelation used to insert ($1,$2) before ($2,$1), so of two mirrored rows
+-- the one with the higher relation_id is the copy.
+delete from friend_relationship fr
+using friend_relationship r
+where fr.status != 'FRIEND' and r.status = fr.status
+and r.your_id = fr.friend_id and r.friend_id = fr.your_id
+and r.relation_id < fr.relation_id;
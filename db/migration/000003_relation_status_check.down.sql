@@ -0,0 +1 @@
+ALTER TABLE public.friend_relationship DROP CONSTRAINT IF EXISTS friend_relationship_status_check;
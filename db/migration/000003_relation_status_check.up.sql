@@ -0,0 +1,11 @@
+-- Fix the statuses no query matches, then only allow the values of
+-- model.RelationStatus.
+
+update friend_relationship set status = upper(trim(status))
+where status != upper(trim(status));
+
+update friend_relationship set status = 'SUBCRIBE'
+where status = 'SUBSCRIBE';
+
+ALTER TABLE public.friend_relationship ADD CONSTRAINT friend_relationship_status_check
+CHECK (status IN ('FRIEND', 'SUBCRIBE', 'BLOCK'));
@@ -0,0 +1,10 @@
+-- Two concurrent writes used to be able to store the same relation twice,
+-- keep the oldest row and let the constraint reject the next duplicate.
+
+delete from friend_relationship fr
+using friend_relationship r
+where r.your_id = fr.your_id and r.friend_id = fr.friend_id and r.status = fr.status
+and r.relation_id < fr.relation_id;
+
+ALTER TABLE public.friend_relationship ADD CONSTRAINT friend_relationship_unique
+UNIQUE (your_id, friend_id, status);
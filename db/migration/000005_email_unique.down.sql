@@ -0,0 +1 @@
+ALTER TABLE public.email DROP CONSTRAINT IF EXISTS email_unique;
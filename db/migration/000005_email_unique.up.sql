@@ -0,0 +1,4 @@
+-- An email can only be registered once. Merge any duplicated address by hand
+-- before applying, the constraint fails otherwise.
+
+ALTER TABLE public.email ADD CONSTRAINT email_unique UNIQUE (email);
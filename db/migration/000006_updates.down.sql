@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS inbox;
+DROP TABLE IF EXISTS email_update;
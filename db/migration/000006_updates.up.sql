@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS email_update (
+	update_id int8 NOT NULL GENERATED ALWAYS AS IDENTITY,
+	sender_id int8 NOT NULL,
+	text text NOT NULL,
+	created_at timestamptz NOT NULL DEFAULT now(),
+	CONSTRAINT email_update_pk PRIMARY KEY (update_id)
+);
+
+-- One row for each recipient of an update, read_at is null until the
+-- recipient marks it as read.
+CREATE TABLE IF NOT EXISTS inbox (
+	inbox_id int8 NOT NULL GENERATED ALWAYS AS IDENTITY,
+	recipient_id int8 NOT NULL,
+	update_id int8 NOT NULL,
+	read_at timestamptz NULL,
+	CONSTRAINT inbox_pk PRIMARY KEY (inbox_id),
+	CONSTRAINT inbox_unique UNIQUE (recipient_id, update_id)
+);
+
+CREATE INDEX IF NOT EXISTS inbox_recipient ON inbox (recipient_id, inbox_id);
+
+ALTER TABLE public.email_update ADD CONSTRAINT update_sender FOREIGN KEY (sender_id) REFERENCES email(email_id);
+ALTER TABLE public.inbox ADD CONSTRAINT inbox_recipient_email FOREIGN KEY (recipient_id) REFERENCES email(email_id);
+ALTER TABLE public.inbox ADD CONSTRAINT inbox_update FOREIGN KEY (update_id) REFERENCES email_update(update_id);
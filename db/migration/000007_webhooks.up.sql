@@ -0,0 +1,36 @@
+-- The delivery URL registered by an email, updates posted to the email are
+-- POSTed to it.
+CREATE TABLE IF NOT EXISTS webhook (
+	email_id int8 NOT NULL,
+	url varchar(2048) NOT NULL,
+	CONSTRAINT webhook_pk PRIMARY KEY (email_id)
+);
+
+CREATE TABLE IF NOT EXISTS webhook_delivery (
+	delivery_id int8 NOT NULL GENERATED ALWAYS AS IDENTITY,
+	update_id int8 NOT NULL,
+	recipient_id int8 NOT NULL,
+	url varchar(2048) NOT NULL,
+	status varchar(10) NOT NULL,
+	CONSTRAINT webhook_delivery_pk PRIMARY KEY (delivery_id),
+	CONSTRAINT webhook_delivery_status_check CHECK (status in ('PENDING', 'DELIVERED', 'DEAD'))
+);
+
+CREATE INDEX IF NOT EXISTS webhook_delivery_status ON webhook_delivery (status, delivery_id);
+
+-- One row for each attempt of a delivery, status_code is 0 when no response
+-- was received.
+CREATE TABLE IF NOT EXISTS webhook_attempt (
+	attempt_id int8 NOT NULL GENERATED ALWAYS AS IDENTITY,
+	delivery_id int8 NOT NULL,
+	attempt int4 NOT NULL,
+	status_code int4 NOT NULL,
+	error text NOT NULL,
+	attempted_at timestamptz NOT NULL,
+	CONSTRAINT webhook_attempt_pk PRIMARY KEY (attempt_id)
+);
+
+ALTER TABLE public.webhook ADD CONSTRAINT webhook_email FOREIGN KEY (email_id) REFERENCES email(email_id);
+ALTER TABLE public.webhook_delivery ADD CONSTRAINT webhook_delivery_update FOREIGN KEY (update_id) REFERENCES email_update(update_id);
+ALTER TABLE public.webhook_delivery ADD CONSTRAINT webhook_delivery_recipient FOREIGN KEY (recipient_id) REFERENCES email(email_id);
+ALTER TABLE public.webhook_attempt ADD CONSTRAINT webhook_attempt_delivery FOREIGN KEY (delivery_id) REFERENCES webhook_delivery(delivery_id);
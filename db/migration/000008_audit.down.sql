@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS relation_audit;
+DROP FUNCTION IF EXISTS relation_audit_append_only();
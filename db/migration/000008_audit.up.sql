@@ -0,0 +1,30 @@
+-- One row for each relation added or removed, old_status and new_status are
+-- empty when there was no relation before or after. Rows are never changed.
+CREATE TABLE IF NOT EXISTS relation_audit (
+	audit_id int8 NOT NULL GENERATED ALWAYS AS IDENTITY,
+	actor_id int8 NOT NULL,
+	target_id int8 NOT NULL,
+	action varchar(10) NOT NULL,
+	old_status varchar(10) NOT NULL,
+	new_status varchar(10) NOT NULL,
+	request_id varchar(200) NOT NULL,
+	created_at timestamptz NOT NULL DEFAULT now(),
+	CONSTRAINT relation_audit_pk PRIMARY KEY (audit_id),
+	CONSTRAINT relation_audit_action_check CHECK (action in ('ADD', 'REMOVE'))
+);
+
+CREATE INDEX IF NOT EXISTS relation_audit_actor ON relation_audit (actor_id, created_at);
+CREATE INDEX IF NOT EXISTS relation_audit_target ON relation_audit (target_id, created_at);
+
+ALTER TABLE public.relation_audit ADD CONSTRAINT relation_audit_actor_email FOREIGN KEY (actor_id) REFERENCES email(email_id);
+ALTER TABLE public.relation_audit ADD CONSTRAINT relation_audit_target_email FOREIGN KEY (target_id) REFERENCES email(email_id);
+
+CREATE OR REPLACE FUNCTION relation_audit_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'relation_audit is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER relation_audit_append_only
+	BEFORE UPDATE OR DELETE ON relation_audit
+	FOR EACH STATEMENT EXECUTE FUNCTION relation_audit_append_only();
@@ -0,0 +1,8 @@
+// Package migration embeds the numbered schema migrations so the binary can
+// apply them without the source tree.
+package migration
+
+import "embed"
+
+//go:embed *.sql
+var Files embed.FS
@@ -3,13 +3,13 @@ module friend-management-v1
 go 1.16
 
 require (
-	github.com/DATA-DOG/go-sqlmock v1.5.0 // indirect
+	github.com/DATA-DOG/go-sqlmock v1.5.0
 	github.com/gin-gonic/gin v1.7.1 // indirect
-	github.com/go-chi/chi/v5 v5.0.2 // indirect
-	github.com/lib/pq v1.10.2 // indirect
+	github.com/go-chi/chi/v5 v5.0.2
+	github.com/lib/pq v1.10.2
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
 	github.com/modern-go/reflect2 v1.0.1 // indirect
-	github.com/stretchr/testify v1.7.0 // indirect
+	github.com/stretchr/testify v1.7.0
 	github.com/vektra/mockery/v2 v2.7.4 // indirect
 	github.com/volatiletech/null/v8 v8.1.2 // indirect
 	github.com/volatiletech/sqlboiler/v4 v4.5.0 // indirect
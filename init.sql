@@ -1,7 +1,8 @@
 CREATE TABLE IF NOT EXISTS email (
 	email_id int8 NOT NULL GENERATED ALWAYS AS IDENTITY,
 	email varchar(255) NOT NULL,
-	CONSTRAINT email_pk PRIMARY KEY (email_id)
+	CONSTRAINT email_pk PRIMARY KEY (email_id),
+	CONSTRAINT email_unique UNIQUE (email)
 );
 
 CREATE TABLE IF NOT EXISTS friend_relationship (
@@ -9,7 +10,9 @@ CREATE TABLE IF NOT EXISTS friend_relationship (
 	your_id int8 NOT NULL,
 	friend_id int8 NOT NULL,
 	status varchar(10) NOT NULL,
-	CONSTRAINT friend_relationship_pk PRIMARY KEY (relation_id)
+	CONSTRAINT friend_relationship_pk PRIMARY KEY (relation_id),
+	CONSTRAINT friend_relationship_status_check CHECK (status IN ('FRIEND', 'SUBCRIBE', 'BLOCK')),
+	CONSTRAINT friend_relationship_unique UNIQUE (your_id, friend_id, status)
 );
 
 
@@ -27,7 +30,10 @@ values ('quan12yt@gmail.com'),
 
 insert into friend_relationship (your_id, friend_id, status)
 values (5, 2, 'FRIEND'),
+(2, 5, 'FRIEND'),
 (1, 3, 'BLOCK'),
-(2, 4, 'SUBSCRIBE'),
+(2, 4, 'SUBCRIBE'),
 (3, 1, 'FRIEND'),
-(4, 1, 'FRIEND');
\ No newline at end of file
+(1, 3, 'FRIEND'),
+(4, 1, 'FRIEND'),
+(1, 4, 'FRIEND');
\ No newline at end of file
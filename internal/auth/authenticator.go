@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"friend-management-v1/model"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// APIKeyHeader is the header carrying an API key.
+const APIKeyHeader = "X-API-Key"
+
+var (
+	ErrNoCredentials = errors.New("missing API key or bearer token")
+	ErrInvalidKey    = errors.New("invalid API key")
+	ErrInvalidToken  = errors.New("invalid bearer token")
+	ErrTokenExpired  = errors.New("bearer token has expired")
+)
+
+// Authenticator resolves the principal of a request from its X-API-Key
+// header or its Authorization: Bearer token.
+type Authenticator struct {
+	keys   map[string]Principal
+	secret []byte
+	issuer string
+	now    func() time.Time
+}
+
+// Option configures an Authenticator.
+type Option func(a *Authenticator)
+
+// WithAPIKey accepts the API key whose hex SHA-256 is hash, as returned by
+// HashKey, for the principal named name acting as email.
+func WithAPIKey(name, hash, email string, admin bool) Option {
+	return func(a *Authenticator) {
+		a.keys[strings.ToLower(hash)] = Principal{Id: name, Email: email, Admin: admin}
+	}
+}
+
+// WithJWT accepts the HS256 tokens signed with secret. The sub claim is the
+// email of the principal and the admin claim makes it an admin. The exp claim
+// is required, and the iss claim must be issuer unless issuer is empty.
+func WithJWT(secret []byte, issuer string) Option {
+	return func(a *Authenticator) {
+		a.secret = secret
+		a.issuer = issuer
+	}
+}
+
+// WithClock replaces time.Now when checking the expiry of the tokens.
+func WithClock(now func() time.Time) Option {
+	return func(a *Authenticator) {
+		a.now = now
+	}
+}
+
+func NewAuthenticator(opts ...Option) *Authenticator {
+	a := &Authenticator{
+		keys: make(map[string]Principal),
+		now:  time.Now,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// HashKey returns the hex SHA-256 of key, the form in which API keys are
+// configured.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Enabled tells whether any API key or a JWT secret is configured.
+func (a *Authenticator) Enabled() bool {
+	return len(a.keys) > 0 || len(a.secret) > 0
+}
+
+// Authenticate returns the principal of r. The API key is used when r has
+// both an API key and a bearer token.
+func (a *Authenticator) Authenticate(r *http.Request) (Principal, error) {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		p, ok := a.keys[HashKey(key)]
+		if !ok {
+			return Principal{}, ErrInvalidKey
+		}
+		return p, nil
+	}
+	header := r.Header.Get("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return a.parseToken(strings.TrimSpace(header[7:]))
+	}
+	return Principal{}, ErrNoCredentials
+}
+
+type tokenHeader struct {
+	Alg string `json:"alg"`
+}
+
+type tokenClaims struct {
+	Sub   string  `json:"sub"`
+	Iss   string  `json:"iss"`
+	Exp   float64 `json:"exp"`
+	Nbf   float64 `json:"nbf"`
+	Admin bool    `json:"admin"`
+}
+
+func (a *Authenticator) parseToken(token string) (Principal, error) {
+	if len(a.secret) == 0 {
+		return Principal{}, ErrInvalidToken
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Principal{}, ErrInvalidToken
+	}
+	var header tokenHeader
+	if err := decodeSegment(parts[0], &header); err != nil || header.Alg != "HS256" {
+		return Principal{}, ErrInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Principal{}, ErrInvalidToken
+	}
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return Principal{}, ErrInvalidToken
+	}
+	var claims tokenClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return Principal{}, ErrInvalidToken
+	}
+	if claims.Sub == "" || claims.Exp == 0 || (a.issuer != "" && claims.Iss != a.issuer) {
+		return Principal{}, ErrInvalidToken
+	}
+	now := float64(a.now().Unix())
+	if now >= claims.Exp {
+		return Principal{}, ErrTokenExpired
+	}
+	if now < claims.Nbf {
+		return Principal{}, ErrInvalidToken
+	}
+	return Principal{Id: claims.Sub, Email: claims.Sub, Admin: claims.Admin}, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Middleware answers 401 to the requests without a valid credential, and
+// passes the others on with their principal in the context.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := a.Authenticate(r)
+		if err != nil {
+			response, _ := json.Marshal(model.NewErrorResponse(err.Error()))
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write(response)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), p)))
+	})
+}
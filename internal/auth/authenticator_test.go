@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"friend-management-v1/model"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	secret = []byte("0123456789abcdef")
+	now    = time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)
+)
+
+func sign(t *testing.T, key []byte, alg string, claims map[string]interface{}) string {
+	header, err := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	assert.Nil(t, err)
+	payload, err := json.Marshal(claims)
+	assert.Nil(t, err)
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func newAuthenticator() *Authenticator {
+	return NewAuthenticator(
+		WithAPIKey("mobile", HashKey("key-1"), "quan@gmail.com", false),
+		WithAPIKey("support", HashKey("key-2"), "support@gmail.com", true),
+		WithJWT(secret, "friends"),
+		WithClock(func() time.Time { return now }))
+}
+
+func TestAuthenticate(t *testing.T) {
+	valid := map[string]interface{}{"sub": "quang@gmail.com", "iss": "friends", "exp": now.Add(time.Hour).Unix()}
+	testCases := []struct {
+		name        string
+		apiKey      string
+		bearer      string
+		expected    Principal
+		expectedErr error
+	}{
+		{
+			name:     "api key",
+			apiKey:   "key-1",
+			expected: Principal{Id: "mobile", Email: "quan@gmail.com"},
+		},
+		{
+			name:     "admin api key",
+			apiKey:   "key-2",
+			expected: Principal{Id: "support", Email: "support@gmail.com", Admin: true},
+		},
+		{
+			name:        "unknown api key",
+			apiKey:      "key-3",
+			expectedErr: ErrInvalidKey,
+		},
+		{
+			name:     "token",
+			bearer:   sign(t, secret, "HS256", valid),
+			expected: Principal{Id: "quang@gmail.com", Email: "quang@gmail.com"},
+		},
+		{
+			name: "admin token",
+			bearer: sign(t, secret, "HS256", map[string]interface{}{
+				"sub": "hau@gmail.com", "iss": "friends", "exp": now.Add(time.Hour).Unix(), "admin": true,
+			}),
+			expected: Principal{Id: "hau@gmail.com", Email: "hau@gmail.com", Admin: true},
+		},
+		{
+			name:        "token signed with another secret",
+			bearer:      sign(t, []byte("another secret"), "HS256", valid),
+			expectedErr: ErrInvalidToken,
+		},
+		{
+			name:        "token with another algorithm",
+			bearer:      sign(t, secret, "none", valid),
+			expectedErr: ErrInvalidToken,
+		},
+		{
+			name: "expired token",
+			bearer: sign(t, secret, "HS256", map[string]interface{}{
+				"sub": "quang@gmail.com", "iss": "friends", "exp": now.Unix(),
+			}),
+			expectedErr: ErrTokenExpired,
+		},
+		{
+			name: "token not valid yet",
+			bearer: sign(t, secret, "HS256", map[string]interface{}{
+				"sub": "quang@gmail.com", "iss": "friends", "exp": now.Add(2 * time.Hour).Unix(), "nbf": now.Add(time.Hour).Unix(),
+			}),
+			expectedErr: ErrInvalidToken,
+		},
+		{
+			name: "token without expiry",
+			bearer: sign(t, secret, "HS256", map[string]interface{}{
+				"sub": "quang@gmail.com", "iss": "friends",
+			}),
+			expectedErr: ErrInvalidToken,
+		},
+		{
+			name: "token of another issuer",
+			bearer: sign(t, secret, "HS256", map[string]interface{}{
+				"sub": "quang@gmail.com", "iss": "other", "exp": now.Add(time.Hour).Unix(),
+			}),
+			expectedErr: ErrInvalidToken,
+		},
+		{
+			name:        "malformed token",
+			bearer:      "not-a-token",
+			expectedErr: ErrInvalidToken,
+		},
+		{
+			name:        "no credentials",
+			expectedErr: ErrNoCredentials,
+		},
+	}
+	a := newAuthenticator()
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			request := httptest.NewRequest("POST", "/api/block", nil)
+			if testCase.apiKey != "" {
+				request.Header.Set(APIKeyHeader, testCase.apiKey)
+			}
+			if testCase.bearer != "" {
+				request.Header.Set("Authorization", "Bearer "+testCase.bearer)
+			}
+
+			actual, err := a.Authenticate(request)
+
+			assert.Equal(t, testCase.expectedErr, err)
+			assert.Equal(t, testCase.expected, actual)
+		})
+	}
+}
+
+func TestTokenWithoutSecret(t *testing.T) {
+	a := NewAuthenticator(WithAPIKey("mobile", HashKey("key-1"), "quan@gmail.com", false))
+	request := httptest.NewRequest("POST", "/api/block", nil)
+	request.Header.Set("Authorization", "Bearer "+sign(t, nil, "HS256", map[string]interface{}{
+		"sub": "quan@gmail.com", "exp": time.Now().Add(time.Hour).Unix(),
+	}))
+
+	_, err := a.Authenticate(request)
+
+	assert.Equal(t, ErrInvalidToken, err)
+	assert.True(t, a.Enabled())
+	assert.False(t, NewAuthenticator().Enabled())
+}
+
+func TestMiddleware(t *testing.T) {
+	var principal Principal
+	handler := newAuthenticator().Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ = FromContext(r.Context())
+	}))
+
+	request := httptest.NewRequest("POST", "/api/block", nil)
+	request.Header.Set(APIKeyHeader, "key-1")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, request)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, Principal{Id: "mobile", Email: "quan@gmail.com"}, principal)
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/api/block", nil))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.NotEmpty(t, rr.Header().Get("WWW-Authenticate"))
+	var response model.ErrorResponse
+	assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.False(t, response.Success)
+	assert.Equal(t, ErrNoCredentials.Error(), response.Error)
+}
+
+func TestAuthorize(t *testing.T) {
+	ctx := context.Background()
+	user := NewContext(ctx, Principal{Id: "mobile", Email: "quan@gmail.com"})
+	admin := NewContext(ctx, Principal{Id: "support", Email: "support@gmail.com", Admin: true})
+
+	assert.Nil(t, Authorize(ctx, "quang@gmail.com"))
+	assert.Nil(t, Authorize(user, "quan@gmail.com"))
+	assert.Nil(t, Authorize(admin, "quang@gmail.com"))
+	err := Authorize(user, "quang@gmail.com")
+	assert.True(t, errors.Is(err, ErrForbidden))
+	assert.Equal(t, "not allowed to act as quang@gmail.com", err.Error())
+
+	assert.Nil(t, AuthorizeAdmin(ctx))
+	assert.Nil(t, AuthorizeAdmin(admin))
+	err = AuthorizeAdmin(user)
+	assert.True(t, errors.Is(err, ErrForbidden))
+	assert.Equal(t, "not allowed to act as any email", err.Error())
+}
@@ -0,0 +1,60 @@
+// Package auth authenticates the callers of the API with hashed API keys or
+// HMAC-signed JWT bearer tokens, and carries the authenticated Principal in
+// the request context.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	// Id names the credential, the name of the API key or the subject of
+	// the token.
+	Id string
+	// Email is the email the principal acts as.
+	Email string
+	// Admin principals may act on behalf of any email.
+	Admin bool
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying p.
+func NewContext(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// FromContext returns the principal carried by ctx, if any.
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(contextKey{}).(Principal)
+	return p, ok
+}
+
+// ErrForbidden is returned by Authorize when the principal may not act on
+// behalf of an email.
+var ErrForbidden = errors.New("not allowed to act as")
+
+// Authorize tells whether the principal of ctx may act on behalf of email:
+// an admin may act as anyone, any other principal only as its own email. A
+// context without principal, as when authentication is disabled, may act as
+// anyone.
+func Authorize(ctx context.Context, email string) error {
+	p, ok := FromContext(ctx)
+	if !ok || p.Admin || p.Email == email {
+		return nil
+	}
+	return fmt.Errorf("%w %s", ErrForbidden, email)
+}
+
+// AuthorizeAdmin tells whether the principal of ctx may act on behalf of every
+// email at once, which only an admin may. A context without principal may.
+func AuthorizeAdmin(ctx context.Context) error {
+	p, ok := FromContext(ctx)
+	if !ok || p.Admin {
+		return nil
+	}
+	return fmt.Errorf("%w any email", ErrForbidden)
+}
@@ -0,0 +1,653 @@
+// Package config loads the server settings from defaults, then an optional
+// KEY=VALUE file, then environment variables, then command line flags.
+package config
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	// Port the HTTP server listens on.
+	Port int
+	// ReadTimeout, WriteTimeout and IdleTimeout are set on the http.Server.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	// ShutdownTimeout is how long in-flight requests may run after SIGTERM.
+	ShutdownTimeout time.Duration
+	// OperationTimeout bounds every storage call made for a request, unless
+	// OperationTimeouts has an entry for the service method, e.g. Addfriend.
+	OperationTimeout  time.Duration
+	OperationTimeouts map[string]time.Duration
+	// Memory keeps every email and relationship in memory instead of Postgres.
+	Memory bool
+	// SeedFile is a SQL script, such as init.sql, used to seed the in-memory storage.
+	SeedFile string
+	// AutoMigrate applies the pending migrations of db/migration before the
+	// connection pool is opened.
+	AutoMigrate bool
+	// AutoProvision registers the unknown emails of /api/add and
+	// /api/subcribe instead of rejecting the request.
+	AutoProvision bool
+	// PathMaxDepth is the longest chain of friends /api/path looks through.
+	PathMaxDepth int
+	Webhook      Webhook
+	SMTP         SMTP
+	Auth         Auth
+	RateLimit    RateLimit
+	DB           DB
+}
+
+// Webhook configures the background delivery of updates to webhooks.
+type Webhook struct {
+	// Workers is how many deliveries are attempted at the same time, and
+	// QueueSize how many may wait for a worker.
+	Workers   int
+	QueueSize int
+	// MaxAttempts is the number of tries of a delivery before it is dead.
+	// The wait after a failure starts at Backoff and doubles up to
+	// MaxBackoff.
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	// Timeout bounds each attempt.
+	Timeout time.Duration
+	// AllowedNetworks may be reached by the webhooks beside the public
+	// addresses, such as the network of a receiver in the same cluster.
+	AllowedNetworks []*net.IPNet
+}
+
+// SMTP configures the mails sent for new friends, subscriptions and posted
+// updates. No mail is sent without a Host.
+type SMTP struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	// TemplateDir holds the <kind>.subject.tmpl and <kind>.body.tmpl files
+	// replacing the built-in templates.
+	TemplateDir string
+	// QueueSize is how many mails may wait to be sent. A mail is tried
+	// MaxAttempts times, waiting Backoff after the first failure, then
+	// twice as long after each failure up to MaxBackoff.
+	QueueSize   int
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+}
+
+// Auth configures the authentication of the API. Without API keys nor a JWT
+// secret every request is served without authentication.
+type Auth struct {
+	APIKeys []APIKey
+	// JWTSecret signs the HS256 bearer tokens, whose iss claim must be
+	// JWTIssuer unless it is empty.
+	JWTSecret string
+	JWTIssuer string
+}
+
+// APIKey is an API key stored as the hex SHA-256 of the key, acting as Email.
+type APIKey struct {
+	Name  string
+	Hash  string
+	Email string
+	Admin bool
+}
+
+// RateLimit configures the token buckets of the API.
+type RateLimit struct {
+	// Client limits each API key or token, or each IP address without
+	// authentication.
+	Client Rate
+	// Requestor limits each email the requests are made for.
+	Requestor Rate
+	// Endpoint limits the requests to each endpoint, unless Endpoints has
+	// an entry for its path, such as /api/add.
+	Endpoint  Rate
+	Endpoints map[string]Rate
+}
+
+// Rate lets Burst requests through at once, then one more every Per / Burst.
+// The zero Rate sets no limit.
+type Rate struct {
+	Burst int
+	Per   time.Duration
+}
+
+// minJWTSecret is the shortest JWT secret accepted, the size of a SHA-256.
+const minJWTSecret = 32
+
+type DB struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+	Name     string
+	SSLMode  string
+}
+
+func Default() Config {
+	return Config{
+		Port:             8080,
+		ReadTimeout:      10 * time.Second,
+		WriteTimeout:     10 * time.Second,
+		IdleTimeout:      60 * time.Second,
+		ShutdownTimeout:  30 * time.Second,
+		OperationTimeout: 5 * time.Second,
+		PathMaxDepth:     6,
+		Webhook: Webhook{
+			Workers:     4,
+			QueueSize:   1000,
+			MaxAttempts: 5,
+			Backoff:     time.Second,
+			MaxBackoff:  time.Minute,
+			Timeout:     10 * time.Second,
+		},
+		SMTP: SMTP{
+			Port:        25,
+			From:        "noreply@localhost",
+			QueueSize:   1000,
+			MaxAttempts: 5,
+			Backoff:     time.Second,
+			MaxBackoff:  time.Minute,
+		},
+		RateLimit: RateLimit{
+			Client:    Rate{Burst: 600, Per: time.Minute},
+			Requestor: Rate{Burst: 60, Per: time.Minute},
+		},
+		DB: DB{
+			Host:    "localhost",
+			Port:    5432,
+			User:    "postgres",
+			Name:    "friend_management",
+			SSLMode: "disable",
+		},
+	}
+}
+
+// DSN returns the lib/pq connection string.
+func (db DB) DSN() string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		quote(db.Host), db.Port, quote(db.User), quote(db.Password), quote(db.Name), quote(db.SSLMode))
+}
+
+func quote(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+func (c Config) Validate() error {
+	if c.Port < 1 || c.Port > 65535 {
+		return fmt.Errorf("PORT: %d is not a valid port", c.Port)
+	}
+	timeouts := []struct {
+		key   string
+		value time.Duration
+	}{
+		{"READ_TIMEOUT", c.ReadTimeout},
+		{"WRITE_TIMEOUT", c.WriteTimeout},
+		{"IDLE_TIMEOUT", c.IdleTimeout},
+		{"SHUTDOWN_TIMEOUT", c.ShutdownTimeout},
+		{"OPERATION_TIMEOUT", c.OperationTimeout},
+		{"WEBHOOK_BACKOFF", c.Webhook.Backoff},
+		{"WEBHOOK_MAX_BACKOFF", c.Webhook.MaxBackoff},
+		{"WEBHOOK_TIMEOUT", c.Webhook.Timeout},
+	}
+	for _, t := range timeouts {
+		if t.value <= 0 {
+			return fmt.Errorf("%s: must be positive", t.key)
+		}
+	}
+	for name, d := range c.OperationTimeouts {
+		if d <= 0 {
+			return fmt.Errorf("OPERATION_TIMEOUTS: %s must be positive", name)
+		}
+	}
+	if c.PathMaxDepth < 1 {
+		return errors.New("PATH_MAX_DEPTH: must be positive")
+	}
+	counts := []struct {
+		key   string
+		value int
+	}{
+		{"WEBHOOK_WORKERS", c.Webhook.Workers},
+		{"WEBHOOK_QUEUE_SIZE", c.Webhook.QueueSize},
+		{"WEBHOOK_MAX_ATTEMPTS", c.Webhook.MaxAttempts},
+	}
+	for _, n := range counts {
+		if n.value < 1 {
+			return fmt.Errorf("%s: must be positive", n.key)
+		}
+	}
+	if c.Webhook.MaxBackoff < c.Webhook.Backoff {
+		return errors.New("WEBHOOK_MAX_BACKOFF: must not be less than WEBHOOK_BACKOFF")
+	}
+	if err := c.SMTP.validate(); err != nil {
+		return err
+	}
+	if c.Auth.JWTSecret != "" && len(c.Auth.JWTSecret) < minJWTSecret {
+		return fmt.Errorf("JWT_SECRET: must be at least %d bytes", minJWTSecret)
+	}
+	if c.Memory {
+		return nil
+	}
+	if c.DB.Port < 1 || c.DB.Port > 65535 {
+		return fmt.Errorf("DB_PORT: %d is not a valid port", c.DB.Port)
+	}
+	if c.DB.Host == "" || c.DB.User == "" || c.DB.Name == "" {
+		return errors.New("DB_HOST, DB_USER and DB_NAME must not be empty")
+	}
+	switch c.DB.SSLMode {
+	case "disable", "require", "verify-ca", "verify-full":
+	default:
+		return fmt.Errorf("DB_SSLMODE: %s is not a valid ssl mode", c.DB.SSLMode)
+	}
+	if c.SeedFile != "" {
+		return errors.New("SEED_FILE is only used with the in-memory storage")
+	}
+	return nil
+}
+
+func (smtp SMTP) validate() error {
+	if smtp.Host == "" {
+		return nil
+	}
+	if smtp.Port < 1 || smtp.Port > 65535 {
+		return fmt.Errorf("SMTP_PORT: %d is not a valid port", smtp.Port)
+	}
+	if smtp.From == "" {
+		return errors.New("SMTP_FROM: must not be empty")
+	}
+	if smtp.QueueSize < 1 {
+		return errors.New("SMTP_QUEUE_SIZE: must be positive")
+	}
+	if smtp.MaxAttempts < 1 {
+		return errors.New("SMTP_MAX_ATTEMPTS: must be positive")
+	}
+	if smtp.Backoff <= 0 {
+		return errors.New("SMTP_BACKOFF: must be positive")
+	}
+	if smtp.MaxBackoff < smtp.Backoff {
+		return errors.New("SMTP_MAX_BACKOFF: must not be less than SMTP_BACKOFF")
+	}
+	return nil
+}
+
+// setting is one key shared by the file, the environment and the flags.
+type setting struct {
+	key    string
+	flag   string
+	usage  string
+	isBool bool
+	set    func(c *Config, value string) error
+}
+
+var settings = []setting{
+	{"POSTGRES_USER", "", "", false, setString(func(c *Config) *string { return &c.DB.User })},
+	{"POSTGRES_PASSWORD", "", "", false, setString(func(c *Config) *string { return &c.DB.Password })},
+	{"POSTGRES_PASSWORD_FILE", "", "", false, setSecret(func(c *Config) *string { return &c.DB.Password })},
+	{"POSTGRES_DB", "", "", false, setString(func(c *Config) *string { return &c.DB.Name })},
+	{"PORT", "port", "port the HTTP server listens on", false, setInt(func(c *Config) *int { return &c.Port })},
+	{"READ_TIMEOUT", "read-timeout", "maximum duration for reading a request", false, setDuration(func(c *Config) *time.Duration { return &c.ReadTimeout })},
+	{"WRITE_TIMEOUT", "write-timeout", "maximum duration before timing out the response write", false, setDuration(func(c *Config) *time.Duration { return &c.WriteTimeout })},
+	{"IDLE_TIMEOUT", "idle-timeout", "maximum time to wait for the next request on a keep-alive connection", false, setDuration(func(c *Config) *time.Duration { return &c.IdleTimeout })},
+	{"SHUTDOWN_TIMEOUT", "shutdown-timeout", "maximum time to drain in-flight requests on shutdown", false, setDuration(func(c *Config) *time.Duration { return &c.ShutdownTimeout })},
+	{"OPERATION_TIMEOUT", "operation-timeout", "maximum duration of the storage calls made for a request", false, setDuration(func(c *Config) *time.Duration { return &c.OperationTimeout })},
+	{"OPERATION_TIMEOUTS", "operation-timeouts", "per operation timeouts such as Addfriend=2s,RetrieveContactEmail=1s", false, setDurations(func(c *Config) *map[string]time.Duration { return &c.OperationTimeouts })},
+	{"MEMORY", "memory", "keep data in memory instead of Postgres", true, setBool(func(c *Config) *bool { return &c.Memory })},
+	{"SEED_FILE", "seed", "SQL script used to seed the in-memory storage, e.g. init.sql", false, setString(func(c *Config) *string { return &c.SeedFile })},
+	{"AUTO_MIGRATE", "migrate", "apply pending migrations before serving", true, setBool(func(c *Config) *bool { return &c.AutoMigrate })},
+	{"AUTO_PROVISION", "auto-provision", "register unknown emails on add and subcribe", true, setBool(func(c *Config) *bool { return &c.AutoProvision })},
+	{"PATH_MAX_DEPTH", "path-max-depth", "longest chain of friends looked through by /api/path", false, setInt(func(c *Config) *int { return &c.PathMaxDepth })},
+	{"WEBHOOK_WORKERS", "webhook-workers", "number of webhook deliveries attempted at the same time", false, setInt(func(c *Config) *int { return &c.Webhook.Workers })},
+	{"WEBHOOK_QUEUE_SIZE", "webhook-queue-size", "number of webhook deliveries waiting for a worker", false, setInt(func(c *Config) *int { return &c.Webhook.QueueSize })},
+	{"WEBHOOK_MAX_ATTEMPTS", "webhook-max-attempts", "tries of a webhook delivery before it is dead", false, setInt(func(c *Config) *int { return &c.Webhook.MaxAttempts })},
+	{"WEBHOOK_BACKOFF", "webhook-backoff", "wait after the first failed webhook attempt, doubled after each failure", false, setDuration(func(c *Config) *time.Duration { return &c.Webhook.Backoff })},
+	{"WEBHOOK_MAX_BACKOFF", "webhook-max-backoff", "longest wait between webhook attempts", false, setDuration(func(c *Config) *time.Duration { return &c.Webhook.MaxBackoff })},
+	{"WEBHOOK_TIMEOUT", "webhook-timeout", "maximum duration of a webhook attempt", false, setDuration(func(c *Config) *time.Duration { return &c.Webhook.Timeout })},
+	{"WEBHOOK_ALLOWED_NETWORKS", "webhook-allowed-networks", "private networks the webhooks may reach, such as 10.1.0.0/16,127.0.0.1", false, setNetworks(func(c *Config) *[]*net.IPNet { return &c.Webhook.AllowedNetworks })},
+	{"SMTP_HOST", "smtp-host", "SMTP server host, mails are sent only when set", false, setString(func(c *Config) *string { return &c.SMTP.Host })},
+	{"SMTP_PORT", "smtp-port", "SMTP server port", false, setInt(func(c *Config) *int { return &c.SMTP.Port })},
+	{"SMTP_USERNAME", "smtp-username", "SMTP user, PLAIN authentication is used when set", false, setString(func(c *Config) *string { return &c.SMTP.Username })},
+	{"SMTP_PASSWORD", "smtp-password", "SMTP password", false, setString(func(c *Config) *string { return &c.SMTP.Password })},
+	{"SMTP_PASSWORD_FILE", "smtp-password-file", "file holding the SMTP password", false, setSecret(func(c *Config) *string { return &c.SMTP.Password })},
+	{"SMTP_FROM", "smtp-from", "sender address of the mails", false, setString(func(c *Config) *string { return &c.SMTP.From })},
+	{"SMTP_TEMPLATE_DIR", "smtp-template-dir", "directory of <kind>.subject.tmpl and <kind>.body.tmpl mail templates", false, setString(func(c *Config) *string { return &c.SMTP.TemplateDir })},
+	{"SMTP_QUEUE_SIZE", "smtp-queue-size", "number of mails waiting to be sent", false, setInt(func(c *Config) *int { return &c.SMTP.QueueSize })},
+	{"SMTP_MAX_ATTEMPTS", "smtp-max-attempts", "tries of a mail before it is dropped", false, setInt(func(c *Config) *int { return &c.SMTP.MaxAttempts })},
+	{"SMTP_BACKOFF", "smtp-backoff", "wait after the first failed send of a mail, doubled after each failure", false, setDuration(func(c *Config) *time.Duration { return &c.SMTP.Backoff })},
+	{"SMTP_MAX_BACKOFF", "smtp-max-backoff", "longest wait between sends of a mail", false, setDuration(func(c *Config) *time.Duration { return &c.SMTP.MaxBackoff })},
+	{"API_KEYS", "api-keys", "API keys as name:sha256:email[:admin], comma separated", false, setAPIKeys(func(c *Config) *[]APIKey { return &c.Auth.APIKeys })},
+	{"JWT_SECRET", "jwt-secret", "HMAC secret of the HS256 bearer tokens", false, setString(func(c *Config) *string { return &c.Auth.JWTSecret })},
+	{"JWT_SECRET_FILE", "jwt-secret-file", "file holding the JWT secret", false, setSecret(func(c *Config) *string { return &c.Auth.JWTSecret })},
+	{"JWT_ISSUER", "jwt-issuer", "required iss claim of the bearer tokens", false, setString(func(c *Config) *string { return &c.Auth.JWTIssuer })},
+	{"RATE_LIMIT_CLIENT", "rate-limit-client", "requests of each API client, such as 600/1m, 0 for no limit", false, setRate(func(c *Config) *Rate { return &c.RateLimit.Client })},
+	{"RATE_LIMIT_REQUESTOR", "rate-limit-requestor", "requests made for each email, such as 60/1m, 0 for no limit", false, setRate(func(c *Config) *Rate { return &c.RateLimit.Requestor })},
+	{"RATE_LIMIT_ENDPOINT", "rate-limit-endpoint", "requests to each endpoint, such as 1000/1s, 0 for no limit", false, setRate(func(c *Config) *Rate { return &c.RateLimit.Endpoint })},
+	{"RATE_LIMIT_ENDPOINTS", "rate-limit-endpoints", "per endpoint limits such as /api/add=100/1m,/api/retrieve=50/1s", false, setRates(func(c *Config) *map[string]Rate { return &c.RateLimit.Endpoints })},
+	{"DB_HOST", "db-host", "Postgres host", false, setString(func(c *Config) *string { return &c.DB.Host })},
+	{"DB_PORT", "db-port", "Postgres port", false, setInt(func(c *Config) *int { return &c.DB.Port })},
+	{"DB_USER", "db-user", "Postgres user", false, setString(func(c *Config) *string { return &c.DB.User })},
+	{"DB_PASSWORD", "db-password", "Postgres password", false, setString(func(c *Config) *string { return &c.DB.Password })},
+	{"DB_PASSWORD_FILE", "db-password-file", "file holding the Postgres password", false, setSecret(func(c *Config) *string { return &c.DB.Password })},
+	{"DB_NAME", "db-name", "Postgres database name", false, setString(func(c *Config) *string { return &c.DB.Name })},
+	{"DB_SSLMODE", "db-sslmode", "Postgres ssl mode", false, setString(func(c *Config) *string { return &c.DB.SSLMode })},
+}
+
+func setString(field func(c *Config) *string) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}
+}
+
+// setSecret reads the value from the file named by value, as with docker secrets.
+func setSecret(field func(c *Config) *string) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		content, err := ioutil.ReadFile(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = strings.TrimRight(string(content), "\r\n")
+		return nil
+	}
+}
+
+func setInt(field func(c *Config) *int) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New("must be a number")
+		}
+		*field(c) = i
+		return nil
+	}
+}
+
+func setDuration(field func(c *Config) *time.Duration) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return errors.New("must be a duration such as 10s")
+		}
+		*field(c) = d
+		return nil
+	}
+}
+
+// setDurations parses a comma separated list of name=duration pairs.
+func setDurations(field func(c *Config) *map[string]time.Duration) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		durations := make(map[string]time.Duration)
+		for _, pair := range strings.Split(value, ",") {
+			if strings.TrimSpace(pair) == "" {
+				continue
+			}
+			kv := strings.SplitN(pair, "=", 2)
+			if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+				return errors.New("must be a list such as Addfriend=2s,GetFriendsEmail=1s")
+			}
+			d, err := time.ParseDuration(strings.TrimSpace(kv[1]))
+			if err != nil {
+				return fmt.Errorf("%s: must be a duration such as 10s", strings.TrimSpace(kv[0]))
+			}
+			durations[strings.TrimSpace(kv[0])] = d
+		}
+		*field(c) = durations
+		return nil
+	}
+}
+
+// setAPIKeys parses a comma separated list of name:sha256:email entries, each
+// optionally followed by :admin.
+func setAPIKeys(field func(c *Config) *[]APIKey) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		var keys []APIKey
+		names := make(map[string]bool)
+		for _, entry := range strings.Split(value, ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			parts := strings.Split(entry, ":")
+			if len(parts) < 3 || len(parts) > 4 || parts[0] == "" || parts[2] == "" ||
+				(len(parts) == 4 && parts[3] != "admin") {
+				return errors.New("must be a list such as mobile:<sha256>:quan@gmail.com,support:<sha256>:support@gmail.com:admin")
+			}
+			if hash, err := hex.DecodeString(parts[1]); err != nil || len(hash) != sha256.Size {
+				return fmt.Errorf("%s: must be the hex SHA-256 of the key", parts[0])
+			}
+			if names[parts[0]] {
+				return fmt.Errorf("%s: is set twice", parts[0])
+			}
+			names[parts[0]] = true
+			keys = append(keys, APIKey{Name: parts[0], Hash: parts[1], Email: parts[2], Admin: len(parts) == 4})
+		}
+		*field(c) = keys
+		return nil
+	}
+}
+
+func setRate(field func(c *Config) *Rate) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		rate, err := parseRate(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = rate
+		return nil
+	}
+}
+
+// setRates parses a comma separated list of path=rate pairs.
+func setRates(field func(c *Config) *map[string]Rate) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		rates := make(map[string]Rate)
+		for _, pair := range strings.Split(value, ",") {
+			if strings.TrimSpace(pair) == "" {
+				continue
+			}
+			kv := strings.SplitN(pair, "=", 2)
+			if len(kv) != 2 || !strings.HasPrefix(strings.TrimSpace(kv[0]), "/") {
+				return errors.New("must be a list such as /api/add=100/1m,/api/retrieve=50/1s")
+			}
+			rate, err := parseRate(kv[1])
+			if err != nil {
+				return fmt.Errorf("%s: %w", strings.TrimSpace(kv[0]), err)
+			}
+			rates[strings.TrimSpace(kv[0])] = rate
+		}
+		*field(c) = rates
+		return nil
+	}
+}
+
+// parseRate parses a rate such as 60/1m or 10/s, or 0 for no limit.
+func parseRate(value string) (Rate, error) {
+	value = strings.TrimSpace(value)
+	if value == "0" || value == "" {
+		return Rate{}, nil
+	}
+	invalid := errors.New("must be a rate such as 60/1m, or 0 for no limit")
+	parts := strings.SplitN(value, "/", 2)
+	if len(parts) != 2 {
+		return Rate{}, invalid
+	}
+	burst, err := strconv.Atoi(parts[0])
+	if err != nil || burst < 1 {
+		return Rate{}, invalid
+	}
+	per := parts[1]
+	if per != "" && (per[0] < '0' || per[0] > '9') {
+		per = "1" + per
+	}
+	d, err := time.ParseDuration(per)
+	if err != nil || d <= 0 {
+		return Rate{}, invalid
+	}
+	return Rate{Burst: burst, Per: d}, nil
+}
+
+// setNetworks parses a comma separated list of CIDR networks or addresses.
+func setNetworks(field func(c *Config) *[]*net.IPNet) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		var networks []*net.IPNet
+		for _, cidr := range strings.Split(value, ",") {
+			cidr = strings.TrimSpace(cidr)
+			if cidr == "" {
+				continue
+			}
+			if ip := net.ParseIP(cidr); ip != nil {
+				bits := 8 * net.IPv6len
+				if ip.To4() != nil {
+					ip, bits = ip.To4(), 8*net.IPv4len
+				}
+				networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+				continue
+			}
+			_, network, err := net.ParseCIDR(cidr)
+			if err != nil {
+				return fmt.Errorf("%s: must be a network such as 10.1.0.0/16 or an address", cidr)
+			}
+			networks = append(networks, network)
+		}
+		*field(c) = networks
+		return nil
+	}
+}
+
+func setBool(field func(c *Config) *bool) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("must be true or false")
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+type assignment struct {
+	setting setting
+	value   string
+}
+
+// Loader registers the flags on a flag.FlagSet and builds the Config once
+// the flags are parsed.
+type Loader struct {
+	file  string
+	flags []assignment
+}
+
+func NewLoader(fs *flag.FlagSet) *Loader {
+	l := &Loader{}
+	fs.StringVar(&l.file, "config", "", "KEY=VALUE settings file, also read from CONFIG_FILE")
+	for _, s := range settings {
+		if s.flag == "" {
+			continue
+		}
+		s := s
+		value := flagValue(func(value string) error {
+			l.flags = append(l.flags, assignment{s, value})
+			return nil
+		})
+		if s.isBool {
+			fs.Var(boolFlag{value}, s.flag, s.usage)
+		} else {
+			fs.Var(value, s.flag, s.usage)
+		}
+	}
+	return l
+}
+
+// Load applies the defaults, the settings file, the environment and the
+// parsed flags in that order, then validates the result.
+func (l *Loader) Load(lookupEnv func(string) (string, bool)) (Config, error) {
+	cfg := Default()
+
+	file := l.file
+	if file == "" {
+		file, _ = lookupEnv("CONFIG_FILE")
+	}
+	if file != "" {
+		values, err := readFile(file)
+		if err != nil {
+			return cfg, err
+		}
+		for _, s := range settings {
+			if value, ok := values[s.key]; ok {
+				if err := s.set(&cfg, value); err != nil {
+					return cfg, fmt.Errorf("%s: %s: %w", file, s.key, err)
+				}
+			}
+		}
+	}
+	for _, s := range settings {
+		if value, ok := lookupEnv(s.key); ok {
+			if err := s.set(&cfg, value); err != nil {
+				return cfg, fmt.Errorf("%s: %w", s.key, err)
+			}
+		}
+	}
+	for _, a := range l.flags {
+		if err := a.setting.set(&cfg, a.value); err != nil {
+			return cfg, fmt.Errorf("-%s: %w", a.setting.flag, err)
+		}
+	}
+	return cfg, cfg.Validate()
+}
+
+// Load reads the configuration of a command started with args.
+func Load(args []string) (Config, error) {
+	fs := flag.NewFlagSet("friend-management-v1", flag.ContinueOnError)
+	l := NewLoader(fs)
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+	return l.Load(os.LookupEnv)
+}
+
+// readFile parses KEY=VALUE lines, such as the docker-compose .env file.
+// Blank lines and lines starting with # are skipped.
+func readFile(name string) (map[string]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.IndexByte(line, '=')
+		if i < 1 {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", name, n)
+		}
+		values[strings.TrimSpace(line[:i])] = strings.Trim(strings.TrimSpace(line[i+1:]), `"'`)
+	}
+	return values, scanner.Err()
+}
+
+type flagValue func(string) error
+
+func (f flagValue) String() string     { return "" }
+func (f flagValue) Set(s string) error { return f(s) }
+
+type boolFlag struct{ flagValue }
+
+func (boolFlag) IsBoolFlag() bool { return true }
@@ -0,0 +1,280 @@
+package config
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func env(values map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := values[key]
+		return value, ok
+	}
+}
+
+func load(t *testing.T, args []string, values map[string]string) (Config, error) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	l := NewLoader(fs)
+	assert.Nil(t, fs.Parse(args))
+	return l.Load(env(values))
+}
+
+func writeFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := load(t, nil, nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, Default(), cfg)
+	assert.Equal(t, 8080, cfg.Port)
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := writeFile(t, "app.env", `# settings
+POSTGRES_USER=compose
+POSTGRES_DB=compose_db
+PORT=9000
+DB_HOST=file-host
+DB_PORT=6543
+`)
+
+	cfg, err := load(t, []string{"-config", file, "-db-host", "flag-host"}, map[string]string{
+		"PORT":    "9100",
+		"DB_HOST": "env-host",
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 9100, cfg.Port)
+	assert.Equal(t, "flag-host", cfg.DB.Host)
+	assert.Equal(t, 6543, cfg.DB.Port)
+	assert.Equal(t, "compose", cfg.DB.User)
+	assert.Equal(t, "compose_db", cfg.DB.Name)
+}
+
+func TestLoadConfigFileFromEnv(t *testing.T) {
+	file := writeFile(t, "app.env", "MEMORY=true\nSEED_FILE=init.sql\n")
+
+	cfg, err := load(t, nil, map[string]string{"CONFIG_FILE": file})
+
+	assert.Nil(t, err)
+	assert.Equal(t, true, cfg.Memory)
+	assert.Equal(t, "init.sql", cfg.SeedFile)
+}
+
+func TestLoadBoolFlags(t *testing.T) {
+	cfg, err := load(t, []string{"-memory", "-migrate", "-auto-provision"}, nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, true, cfg.Memory)
+	assert.Equal(t, true, cfg.AutoMigrate)
+	assert.Equal(t, true, cfg.AutoProvision)
+}
+
+func TestLoadTimeouts(t *testing.T) {
+	cfg, err := load(t, []string{"-shutdown-timeout", "5s"}, map[string]string{"READ_TIMEOUT": "2s"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2*time.Second, cfg.ReadTimeout)
+	assert.Equal(t, 5*time.Second, cfg.ShutdownTimeout)
+
+	_, err = load(t, nil, map[string]string{"WRITE_TIMEOUT": "0s"})
+	assert.NotNil(t, err)
+	assert.Equal(t, "WRITE_TIMEOUT: must be positive", err.Error())
+}
+
+func TestLoadOperationTimeouts(t *testing.T) {
+	cfg, err := load(t, []string{"-operation-timeout", "3s"}, map[string]string{"OPERATION_TIMEOUTS": "Addfriend=1s, RetrieveContactEmail=500ms"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3*time.Second, cfg.OperationTimeout)
+	assert.Equal(t, map[string]time.Duration{
+		"Addfriend":            time.Second,
+		"RetrieveContactEmail": 500 * time.Millisecond,
+	}, cfg.OperationTimeouts)
+
+	_, err = load(t, nil, map[string]string{"OPERATION_TIMEOUTS": "Addfriend=soon"})
+	assert.NotNil(t, err)
+	assert.Equal(t, "OPERATION_TIMEOUTS: Addfriend: must be a duration such as 10s", err.Error())
+
+	_, err = load(t, nil, map[string]string{"OPERATION_TIMEOUTS": "Addfriend=-1s"})
+	assert.NotNil(t, err)
+	assert.Equal(t, "OPERATION_TIMEOUTS: Addfriend must be positive", err.Error())
+}
+
+func TestLoadAPIKeys(t *testing.T) {
+	hash := "6b86b273ff34fce19d6b804eff5a3f5747ada4eaa22f1d49c01e52ddb7875b4b"
+
+	cfg, err := load(t, []string{"-api-keys", "mobile:" + hash + ":quan@gmail.com, support:" + hash + ":support@gmail.com:admin"}, nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []APIKey{
+		{Name: "mobile", Hash: hash, Email: "quan@gmail.com"},
+		{Name: "support", Hash: hash, Email: "support@gmail.com", Admin: true},
+	}, cfg.Auth.APIKeys)
+
+	_, err = load(t, nil, map[string]string{"API_KEYS": "mobile:1234:quan@gmail.com"})
+	assert.NotNil(t, err)
+	assert.Equal(t, "API_KEYS: mobile: must be the hex SHA-256 of the key", err.Error())
+
+	_, err = load(t, nil, map[string]string{"API_KEYS": "mobile:" + hash + ":quan@gmail.com:root"})
+	assert.NotNil(t, err)
+
+	_, err = load(t, nil, map[string]string{"API_KEYS": "mobile:" + hash + ":quan@gmail.com,mobile:" + hash + ":quang@gmail.com"})
+	assert.NotNil(t, err)
+	assert.Equal(t, "API_KEYS: mobile: is set twice", err.Error())
+}
+
+func TestLoadRateLimits(t *testing.T) {
+	cfg, err := load(t, []string{"-rate-limit-client", "100/s"}, map[string]string{
+		"RATE_LIMIT_REQUESTOR": "0",
+		"RATE_LIMIT_ENDPOINT":  "1000/1m",
+		"RATE_LIMIT_ENDPOINTS": "/api/add=10/1m, /api/retrieve=0",
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, RateLimit{
+		Client:   Rate{Burst: 100, Per: time.Second},
+		Endpoint: Rate{Burst: 1000, Per: time.Minute},
+		Endpoints: map[string]Rate{
+			"/api/add":      {Burst: 10, Per: time.Minute},
+			"/api/retrieve": {},
+		},
+	}, cfg.RateLimit)
+
+	for _, value := range []string{"fast", "10", "0/1m", "10/-1s", "10/never"} {
+		_, err = load(t, nil, map[string]string{"RATE_LIMIT_CLIENT": value})
+		assert.NotNil(t, err, value)
+		assert.Equal(t, "RATE_LIMIT_CLIENT: must be a rate such as 60/1m, or 0 for no limit", err.Error())
+	}
+
+	_, err = load(t, nil, map[string]string{"RATE_LIMIT_ENDPOINTS": "/api/add=often"})
+	assert.NotNil(t, err)
+	assert.Equal(t, "RATE_LIMIT_ENDPOINTS: /api/add: must be a rate such as 60/1m, or 0 for no limit", err.Error())
+}
+
+func TestLoadAllowedNetworks(t *testing.T) {
+	cfg, err := load(t, []string{"-webhook-allowed-networks", "10.1.0.0/16, 127.0.0.1,fd00::/8"}, nil)
+
+	assert.Nil(t, err)
+	networks := []string{}
+	for _, network := range cfg.Webhook.AllowedNetworks {
+		networks = append(networks, network.String())
+	}
+	assert.Equal(t, []string{"10.1.0.0/16", "127.0.0.1/32", "fd00::/8"}, networks)
+
+	_, err = load(t, nil, map[string]string{"WEBHOOK_ALLOWED_NETWORKS": "10.1.0.0/33"})
+	assert.NotNil(t, err)
+	assert.Equal(t, "WEBHOOK_ALLOWED_NETWORKS: 10.1.0.0/33: must be a network such as 10.1.0.0/16 or an address", err.Error())
+}
+
+func TestLoadSecretFile(t *testing.T) {
+	secret := writeFile(t, "password", "s3cret\n")
+
+	cfg, err := load(t, nil, map[string]string{"DB_PASSWORD_FILE": secret})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "s3cret", cfg.DB.Password)
+}
+
+func TestLoadMissingSecretFile(t *testing.T) {
+	_, err := load(t, nil, map[string]string{"DB_PASSWORD_FILE": filepath.Join(os.TempDir(), "does-not-exist")})
+
+	assert.NotNil(t, err)
+}
+
+func TestLoadInvalidValue(t *testing.T) {
+	_, err := load(t, nil, map[string]string{"PORT": "http"})
+
+	assert.NotNil(t, err)
+	assert.Equal(t, "PORT: must be a number", err.Error())
+}
+
+func TestLoadInvalidFileLine(t *testing.T) {
+	file := writeFile(t, "app.env", "PORT\n")
+
+	_, err := load(t, []string{"-config", file}, nil)
+
+	assert.NotNil(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		name   string
+		change func(c *Config)
+		err    string
+	}{
+		{
+			name:   "Invalid port",
+			change: func(c *Config) { c.Port = 70000 },
+			err:    "PORT: 70000 is not a valid port",
+		},
+		{
+			name:   "Empty database host",
+			change: func(c *Config) { c.DB.Host = "" },
+			err:    "DB_HOST, DB_USER and DB_NAME must not be empty",
+		},
+		{
+			name:   "Invalid ssl mode",
+			change: func(c *Config) { c.DB.SSLMode = "maybe" },
+			err:    "DB_SSLMODE: maybe is not a valid ssl mode",
+		},
+		{
+			name:   "Path depth not positive",
+			change: func(c *Config) { c.PathMaxDepth = 0 },
+			err:    "PATH_MAX_DEPTH: must be positive",
+		},
+		{
+			name:   "Webhook attempts not positive",
+			change: func(c *Config) { c.Webhook.MaxAttempts = 0 },
+			err:    "WEBHOOK_MAX_ATTEMPTS: must be positive",
+		},
+		{
+			name:   "Webhook backoff above maximum",
+			change: func(c *Config) { c.Webhook.Backoff = 2 * time.Minute },
+			err:    "WEBHOOK_MAX_BACKOFF: must not be less than WEBHOOK_BACKOFF",
+		},
+		{
+			name:   "SMTP without sender",
+			change: func(c *Config) { c.SMTP.Host = "localhost"; c.SMTP.From = "" },
+			err:    "SMTP_FROM: must not be empty",
+		},
+		{
+			name:   "Short JWT secret",
+			change: func(c *Config) { c.Auth.JWTSecret = "s3cret" },
+			err:    "JWT_SECRET: must be at least 32 bytes",
+		},
+		{
+			name:   "Seed without memory",
+			change: func(c *Config) { c.SeedFile = "init.sql" },
+			err:    "SEED_FILE is only used with the in-memory storage",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := Default()
+			tc.change(&cfg)
+
+			err := cfg.Validate()
+
+			assert.NotNil(t, err)
+			assert.Equal(t, tc.err, err.Error())
+		})
+	}
+}
+
+func TestDSN(t *testing.T) {
+	db := Default().DB
+	db.Password = `it's`
+
+	assert.Equal(t, `host='localhost' port=5432 user='postgres' password='it\'s' dbname='friend_management' sslmode='disable'`, db.DSN())
+}
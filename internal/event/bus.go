@@ -0,0 +1,143 @@
+package event
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// Publisher is what the service publishes its events to.
+type Publisher interface {
+	// Publish hands e to the subscribers of its name. It returns once the
+	// synchronous subscribers are done, without waiting for the others.
+	Publish(ctx context.Context, e Event)
+}
+
+// Handler handles an event, the error is logged by the Bus.
+type Handler func(ctx context.Context, e Event) error
+
+// Bus is an in-process Publisher. Subscribers are registered with Subscribe
+// and SubscribeAsync before the first Publish, usually at startup.
+type Bus struct {
+	mu          sync.RWMutex
+	closed      bool
+	subscribers []*subscriber
+	wg          sync.WaitGroup
+}
+
+type subscriber struct {
+	names   map[string]bool
+	handler Handler
+	// queue is nil for a synchronous subscriber.
+	queue chan queued
+}
+
+type queued struct {
+	ctx context.Context
+	e   Event
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe runs handler in the goroutine of Publish for the events of names,
+// or for every event without names.
+func (b *Bus) Subscribe(handler Handler, names ...string) {
+	b.add(&subscriber{names: setOf(names), handler: handler})
+}
+
+// SubscribeAsync runs handler in a goroutine of its own for the events of
+// names, or every event without names. At most queueSize events wait for it,
+// the events published while its queue is full are dropped and logged. The
+// context handed to handler carries no deadline or cancellation of the
+// publisher.
+func (b *Bus) SubscribeAsync(handler Handler, queueSize int, names ...string) {
+	s := &subscriber{names: setOf(names), handler: handler, queue: make(chan queued, queueSize)}
+	b.add(s)
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		for q := range s.queue {
+			s.handle(q.ctx, q.e)
+		}
+	}()
+}
+
+func (b *Bus) add(s *subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers = append(b.subscribers, s)
+}
+
+func setOf(names []string) map[string]bool {
+	if len(names) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
+
+func (b *Bus) Publish(ctx context.Context, e Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		log.Printf("event: dropping %s, the bus is closed", e.Name())
+		return
+	}
+	for _, s := range b.subscribers {
+		if s.names != nil && !s.names[e.Name()] {
+			continue
+		}
+		if s.queue == nil {
+			s.handle(ctx, e)
+			continue
+		}
+		select {
+		case s.queue <- queued{ctx: detach(ctx), e: e}:
+		default:
+			log.Printf("event: dropping %s, a subscriber queue is full", e.Name())
+		}
+	}
+}
+
+func (s *subscriber) handle(ctx context.Context, e Event) {
+	if err := s.handler(ctx, e); err != nil {
+		log.Printf("event: %s: %v", e.Name(), err)
+	}
+}
+
+// Close stops accepting events and waits for the asynchronous subscribers to
+// handle the events already queued.
+func (b *Bus) Close() error {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		for _, s := range b.subscribers {
+			if s.queue != nil {
+				close(s.queue)
+			}
+		}
+	}
+	b.mu.Unlock()
+	b.wg.Wait()
+	return nil
+}
+
+// detached keeps the values of a context without its deadline and
+// cancellation, an asynchronous subscriber runs after the request is done.
+type detached struct {
+	context.Context
+	values context.Context
+}
+
+func detach(ctx context.Context) context.Context {
+	return detached{Context: context.Background(), values: ctx}
+}
+
+func (d detached) Value(key interface{}) interface{} {
+	return d.values.Value(key)
+}
@@ -0,0 +1,91 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"friend-management-v1/model"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recorder is a Handler keeping the names of the events it handled.
+type recorder struct {
+	mu     sync.Mutex
+	names  []string
+	err    error
+	before chan struct{}
+}
+
+func (r *recorder) handle(ctx context.Context, e Event) error {
+	if r.before != nil {
+		<-r.before
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.names = append(r.names, e.Name())
+	return r.err
+}
+
+func (r *recorder) handled() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.names...)
+}
+
+func TestSubscribe(t *testing.T) {
+	bus := NewBus()
+	all := &recorder{err: errors.New("failed")}
+	updates := &recorder{}
+	bus.Subscribe(all.handle)
+	bus.Subscribe(updates.handle, UpdatePostedName, BlockedName)
+
+	bus.Publish(context.Background(), FriendAdded{Actor: "quan12yt@gmail.com", Target: "quang@gmail.com"})
+	bus.Publish(context.Background(), Subscribed{Requestor: "hau@gmail.com", Target: "quang@gmail.com"})
+	bus.Publish(context.Background(), Blocked{Requestor: "hau@gmail.com", Target: "quang@gmail.com"})
+	bus.Publish(context.Background(), UpdatePosted{Update: model.Update{Id: "1"}, Recipients: []string{"quang@gmail.com"}})
+
+	assert.Equal(t, []string{FriendAddedName, SubscribedName, BlockedName, UpdatePostedName}, all.handled())
+	assert.Equal(t, []string{BlockedName, UpdatePostedName}, updates.handled())
+}
+
+func TestSubscribeAsync(t *testing.T) {
+	bus := NewBus()
+	slow := &recorder{before: make(chan struct{})}
+	bus.SubscribeAsync(slow.handle, 1)
+
+	// the first event is taken by the worker, the second waits in the queue
+	// and the third is dropped
+	bus.Publish(context.Background(), FriendAdded{})
+	assert.Eventually(t, func() bool { return len(bus.subscribers[0].queue) == 0 }, time.Second, time.Millisecond)
+	bus.Publish(context.Background(), Subscribed{})
+	bus.Publish(context.Background(), Blocked{})
+	close(slow.before)
+	bus.Close()
+
+	assert.Equal(t, []string{FriendAddedName, SubscribedName}, slow.handled())
+	bus.Publish(context.Background(), Blocked{})
+	assert.Equal(t, []string{FriendAddedName, SubscribedName}, slow.handled())
+}
+
+type key struct{}
+
+func TestSubscribeAsyncContext(t *testing.T) {
+	bus := NewBus()
+	done := make(chan context.Context, 1)
+	bus.SubscribeAsync(func(ctx context.Context, e Event) error {
+		done <- ctx
+		return nil
+	}, 10, UpdatePostedName)
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "request"))
+	cancel()
+
+	bus.Publish(ctx, UpdatePosted{})
+	handled := <-done
+	bus.Close()
+
+	assert.Nil(t, handled.Err())
+	assert.Equal(t, "request", handled.Value(key{}))
+}
@@ -0,0 +1,51 @@
+// Package event carries the domain events of the service to the subscribers
+// registered at startup, such as the webhook dispatcher and the mailer.
+package event
+
+import "friend-management-v1/model"
+
+// Names of the events, as returned by Event.Name.
+const (
+	FriendAddedName  = "FriendAdded"
+	SubscribedName   = "Subscribed"
+	BlockedName      = "Blocked"
+	UpdatePostedName = "UpdatePosted"
+)
+
+// Event is one of FriendAdded, Subscribed, Blocked and UpdatePosted.
+type Event interface {
+	Name() string
+}
+
+// FriendAdded is published when Actor added Target as a friend.
+type FriendAdded struct {
+	Actor  string
+	Target string
+}
+
+func (FriendAdded) Name() string { return FriendAddedName }
+
+// Subscribed is published when Requestor subscribed to the updates of Target.
+type Subscribed struct {
+	Requestor string
+	Target    string
+}
+
+func (Subscribed) Name() string { return SubscribedName }
+
+// Blocked is published when Requestor blocked Target.
+type Blocked struct {
+	Requestor string
+	Target    string
+}
+
+func (Blocked) Name() string { return BlockedName }
+
+// UpdatePosted is published when an update is stored, Recipients are every
+// email it was delivered to.
+type UpdatePosted struct {
+	Update     model.Update
+	Recipients []string
+}
+
+func (UpdatePosted) Name() string { return UpdatePostedName }
@@ -0,0 +1,268 @@
+// Package mail emails relationship events and posted updates over plain SMTP.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"friend-management-v1/internal/event"
+	"friend-management-v1/internal/utils"
+	"friend-management-v1/model"
+	"log"
+	"mime"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults of a Notifier built without options.
+const (
+	DefaultQueueSize   = 1000
+	DefaultMaxAttempts = 5
+	DefaultBackoff     = time.Second
+	DefaultMaxBackoff  = time.Minute
+)
+
+// ErrClosed is returned once the Notifier is closed.
+var ErrClosed = errors.New("mail notifier is closed")
+
+// ErrQueueFull is returned when no more mail may wait to be sent.
+var ErrQueueFull = errors.New("mail queue is full")
+
+// Notifier queues one mail per event and sends them in the background to the
+// SMTP server at addr. A mail that fails is queued again up to maxAttempts
+// times, after backoff, then twice as long after each failure up to
+// maxBackoff, and is logged and dropped after the last attempt. The mails
+// queued meanwhile are sent without waiting for it.
+type Notifier struct {
+	addr        string
+	from        string
+	auth        smtp.Auth
+	templates   *Templates
+	queueSize   int
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+
+	queue chan message
+	// ctx is canceled by Close, which stops the worker and the retries.
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type message struct {
+	to      string
+	subject string
+	body    string
+	// attempts is how many times the mail failed to be sent.
+	attempts int
+}
+
+// Option configures a Notifier.
+type Option func(n *Notifier)
+
+// WithAuth authenticates with PLAIN, which net/smtp only sends over TLS or to
+// localhost.
+func WithAuth(username string, password string, host string) Option {
+	return func(n *Notifier) {
+		n.auth = smtp.PlainAuth("", username, password, host)
+	}
+}
+
+// WithTemplates renders the mails with templates instead of the defaults.
+func WithTemplates(templates *Templates) Option {
+	return func(n *Notifier) {
+		n.templates = templates
+	}
+}
+
+// WithQueueSize sets how many mails may wait to be sent.
+func WithQueueSize(size int) Option {
+	return func(n *Notifier) {
+		n.queueSize = size
+	}
+}
+
+// WithRetries sets the number of attempts of a mail and the wait after the
+// first failed attempt, which doubles after each failure up to maxBackoff.
+func WithRetries(maxAttempts int, backoff time.Duration, maxBackoff time.Duration) Option {
+	return func(n *Notifier) {
+		n.maxAttempts = maxAttempts
+		n.backoff = backoff
+		n.maxBackoff = maxBackoff
+	}
+}
+
+// NewNotifier starts the worker of a Notifier sending from the address from
+// through the SMTP server at addr, a host:port. Close stops it.
+func NewNotifier(addr string, from string, opts ...Option) *Notifier {
+	n := &Notifier{
+		addr:        addr,
+		from:        from,
+		templates:   DefaultTemplates(),
+		queueSize:   DefaultQueueSize,
+		maxAttempts: DefaultMaxAttempts,
+		backoff:     DefaultBackoff,
+		maxBackoff:  DefaultMaxBackoff,
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+	n.queue = make(chan message, n.queueSize)
+	n.ctx, n.cancel = context.WithCancel(context.Background())
+	n.wg.Add(1)
+	go n.work()
+	return n
+}
+
+// NotifyRelation mails target that actor became its friend or subscribed to
+// it. Other statuses are not mailed.
+func (n *Notifier) NotifyRelation(ctx context.Context, actor string, target string, status model.RelationStatus) error {
+	switch status {
+	case model.StatusFriend:
+		return n.enqueue(KindFriend, Data{Actor: actor, Recipient: target})
+	case model.StatusSubcribe:
+		return n.enqueue(KindSubscribe, Data{Actor: actor, Recipient: target})
+	}
+	return nil
+}
+
+// Notify mails the text of update to each recipient, as a mention to the
+// recipients the text mentions.
+func (n *Notifier) Notify(ctx context.Context, update model.Update, recipients []string) error {
+	mentioned := make(map[string]bool)
+	for _, email := range utils.GetEmailsFromText(update.Text) {
+		mentioned[email] = true
+	}
+	for _, recipient := range recipients {
+		kind := KindUpdate
+		if mentioned[recipient] {
+			kind = KindMention
+		}
+		data := Data{Actor: update.Sender, Recipient: recipient, Text: update.Text, UpdateId: update.Id}
+		if err := n.enqueue(kind, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handle is the event.Handler of the Notifier, it mails the FriendAdded,
+// Subscribed and UpdatePosted events and ignores the other events.
+func (n *Notifier) Handle(ctx context.Context, e event.Event) error {
+	switch e := e.(type) {
+	case event.FriendAdded:
+		return n.NotifyRelation(ctx, e.Actor, e.Target, model.StatusFriend)
+	case event.Subscribed:
+		return n.NotifyRelation(ctx, e.Requestor, e.Target, model.StatusSubcribe)
+	case event.UpdatePosted:
+		return n.Notify(ctx, e.Update, e.Recipients)
+	}
+	return nil
+}
+
+// enqueue renders the mail of kind and queues it without waiting, a request
+// is never held up by a slow SMTP server.
+func (n *Notifier) enqueue(kind Kind, data Data) error {
+	if n.ctx.Err() != nil {
+		return ErrClosed
+	}
+	subject, body, err := n.templates.Render(kind, data)
+	if err != nil {
+		return err
+	}
+	select {
+	case n.queue <- message{to: data.Recipient, subject: subject, body: body}:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Close stops the worker and the retries and waits for them. The mails still
+// queued or waiting for a retry are dropped.
+func (n *Notifier) Close() error {
+	n.cancel()
+	n.wg.Wait()
+	return nil
+}
+
+func (n *Notifier) work() {
+	defer n.wg.Done()
+	for {
+		select {
+		case m := <-n.queue:
+			n.send(m)
+		case <-n.ctx.Done():
+			return
+		}
+	}
+}
+
+// send attempts m once, and has it retried when it fails with attempts left.
+func (n *Notifier) send(m message) {
+	err := smtp.SendMail(n.addr, n.auth, n.from, []string{m.to}, n.compose(m))
+	if err == nil {
+		return
+	}
+	m.attempts++
+	if m.attempts >= n.maxAttempts {
+		log.Printf("mail: dropping mail to %s after %d attempts: %v", m.to, m.attempts, err)
+		return
+	}
+	n.wg.Add(1)
+	go n.retry(m)
+}
+
+// retry queues m again once its backoff is over, the worker sends the other
+// mails meanwhile.
+func (n *Notifier) retry(m message) {
+	defer n.wg.Done()
+	timer := time.NewTimer(n.backoffOf(m.attempts))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-n.ctx.Done():
+		return
+	}
+	select {
+	case n.queue <- m:
+	default:
+		log.Printf("mail: dropping mail to %s after %d attempts: %v", m.to, m.attempts, ErrQueueFull)
+	}
+}
+
+// compose returns the RFC 5322 message of m.
+func (n *Notifier) compose(m message) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", n.from)
+	fmt.Fprintf(&b, "To: %s\r\n", m.to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", oneLine(m.subject)))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(m.body, "\r\n", "\n"), "\n", "\r\n"))
+	return b.Bytes()
+}
+
+// oneLine joins the lines of a rendered subject, a header must not hold a
+// line break.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// backoffOf returns the wait after the failure of attempt.
+func (n *Notifier) backoffOf(attempt int) time.Duration {
+	wait := n.backoff
+	for i := 1; i < attempt && wait < n.maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > n.maxBackoff {
+		wait = n.maxBackoff
+	}
+	return wait
+}
@@ -0,0 +1,274 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"friend-management-v1/internal/event"
+	"friend-management-v1/model"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeServer is an SMTP server accepting every mail, after answering the
+// first failures MAIL commands with 451. It always answers the RCPT commands
+// of the rejected recipients with 451.
+type fakeServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	failures int
+	attempts int
+	rejected map[string]int
+	mails    []fakeMail
+}
+
+type fakeMail struct {
+	from string
+	to   []string
+	data string
+}
+
+func newFakeServer(t *testing.T, failures int) *fakeServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	s := &fakeServer{listener: listener, failures: failures, rejected: make(map[string]int)}
+	go s.serve()
+	t.Cleanup(func() { listener.Close() })
+	return s
+}
+
+// reject makes the server refuse the mails to recipient.
+func (s *fakeServer) reject(recipient string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rejected["<"+recipient+">"] = 0
+}
+
+// rejections returns how many mails to recipient were refused.
+func (s *fakeServer) rejections(recipient string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rejected["<"+recipient+">"]
+}
+
+func (s *fakeServer) addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *fakeServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeServer) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+	reply("220 localhost fake")
+	var mail fakeMail
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch {
+		case command == "EHLO" || command == "HELO":
+			reply("250 localhost")
+		case strings.HasPrefix(strings.ToUpper(line), "MAIL FROM:"):
+			s.mu.Lock()
+			s.attempts++
+			failed := s.attempts <= s.failures
+			s.mu.Unlock()
+			if failed {
+				reply("451 try again later")
+				continue
+			}
+			mail = fakeMail{from: line[len("MAIL FROM:"):]}
+			reply("250 ok")
+		case strings.HasPrefix(strings.ToUpper(line), "RCPT TO:"):
+			to := line[len("RCPT TO:"):]
+			s.mu.Lock()
+			_, rejected := s.rejected[to]
+			if rejected {
+				s.rejected[to]++
+			}
+			s.mu.Unlock()
+			if rejected {
+				reply("451 mailbox unavailable")
+				continue
+			}
+			mail.to = append(mail.to, to)
+			reply("250 ok")
+		case command == "DATA":
+			reply("354 end with .")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			mail.data = data.String()
+			s.mu.Lock()
+			s.mails = append(s.mails, mail)
+			s.mu.Unlock()
+			reply("250 queued")
+		case command == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func (s *fakeServer) received() []fakeMail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]fakeMail(nil), s.mails...)
+}
+
+func TestNotifyRelation(t *testing.T) {
+	server := newFakeServer(t, 0)
+	n := NewNotifier(server.addr(), "noreply@friends.local")
+	defer n.Close()
+
+	assert.Nil(t, n.NotifyRelation(context.Background(), "quan12yt@gmail.com", "quang@gmail.com", model.StatusFriend))
+	assert.Nil(t, n.NotifyRelation(context.Background(), "hau@gmail.com", "quang@gmail.com", model.StatusBlock))
+	assert.Nil(t, n.NotifyRelation(context.Background(), "hau@gmail.com", "quang@gmail.com", model.StatusSubcribe))
+
+	assert.Eventually(t, func() bool { return len(server.received()) == 2 }, time.Second, time.Millisecond)
+	mails := server.received()
+	assert.Equal(t, "<noreply@friends.local>", mails[0].from)
+	assert.Equal(t, []string{"<quang@gmail.com>"}, mails[0].to)
+	assert.Contains(t, mails[0].data, "Subject: quan12yt@gmail.com added you as a friend\r\n")
+	assert.Contains(t, mails[0].data, "\r\n\r\nHello quang@gmail.com,\r\n\r\nquan12yt@gmail.com added you as a friend.\r\n")
+	assert.Contains(t, mails[1].data, "Subject: hau@gmail.com subscribed to your updates\r\n")
+}
+
+func TestNotifyUpdate(t *testing.T) {
+	server := newFakeServer(t, 0)
+	n := NewNotifier(server.addr(), "noreply@friends.local")
+	defer n.Close()
+	update := model.Update{Id: "1", Sender: "quan12yt@gmail.com", Text: "hello hau@gmail.com"}
+
+	assert.Nil(t, n.Notify(context.Background(), update, []string{"quang@gmail.com", "hau@gmail.com"}))
+
+	assert.Eventually(t, func() bool { return len(server.received()) == 2 }, time.Second, time.Millisecond)
+	mails := server.received()
+	assert.Contains(t, mails[0].data, "Subject: New update from quan12yt@gmail.com\r\n")
+	assert.Contains(t, mails[0].data, "hello hau@gmail.com\r\n")
+	assert.Equal(t, []string{"<hau@gmail.com>"}, mails[1].to)
+	assert.Contains(t, mails[1].data, "Subject: quan12yt@gmail.com mentioned you\r\n")
+}
+
+func TestHandle(t *testing.T) {
+	server := newFakeServer(t, 0)
+	n := NewNotifier(server.addr(), "noreply@friends.local")
+	defer n.Close()
+	bus := event.NewBus()
+	bus.Subscribe(n.Handle)
+
+	bus.Publish(context.Background(), event.Blocked{Requestor: "hau@gmail.com", Target: "quang@gmail.com"})
+	bus.Publish(context.Background(), event.Subscribed{Requestor: "hau@gmail.com", Target: "quang@gmail.com"})
+
+	assert.Eventually(t, func() bool { return len(server.received()) == 1 }, time.Second, time.Millisecond)
+	assert.Contains(t, server.received()[0].data, "Subject: hau@gmail.com subscribed to your updates\r\n")
+}
+
+func TestNotifierRetries(t *testing.T) {
+	server := newFakeServer(t, 2)
+	n := NewNotifier(server.addr(), "noreply@friends.local", WithRetries(3, time.Millisecond, 4*time.Millisecond))
+	defer n.Close()
+
+	assert.Nil(t, n.NotifyRelation(context.Background(), "quan12yt@gmail.com", "quang@gmail.com", model.StatusFriend))
+
+	assert.Eventually(t, func() bool { return len(server.received()) == 1 }, time.Second, time.Millisecond)
+	server.mu.Lock()
+	assert.Equal(t, 3, server.attempts)
+	server.mu.Unlock()
+}
+
+func TestNotifierGivesUp(t *testing.T) {
+	server := newFakeServer(t, 0)
+	server.reject("quang@gmail.com")
+	n := NewNotifier(server.addr(), "noreply@friends.local", WithRetries(2, time.Millisecond, time.Millisecond))
+	defer n.Close()
+
+	assert.Nil(t, n.NotifyRelation(context.Background(), "quan12yt@gmail.com", "quang@gmail.com", model.StatusFriend))
+	assert.Nil(t, n.NotifyRelation(context.Background(), "quan12yt@gmail.com", "hau@gmail.com", model.StatusFriend))
+
+	// the first mail is dropped after two failures, the second is sent
+	assert.Eventually(t, func() bool {
+		return len(server.received()) == 1 && server.rejections("quang@gmail.com") == 2
+	}, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, 2, server.rejections("quang@gmail.com"))
+	assert.Equal(t, []string{"<hau@gmail.com>"}, server.received()[0].to)
+}
+
+func TestNotifierRetryDoesNotHoldUpQueue(t *testing.T) {
+	server := newFakeServer(t, 0)
+	server.reject("quang@gmail.com")
+	n := NewNotifier(server.addr(), "noreply@friends.local", WithRetries(5, time.Minute, time.Minute))
+	defer n.Close()
+
+	assert.Nil(t, n.NotifyRelation(context.Background(), "quan12yt@gmail.com", "quang@gmail.com", model.StatusFriend))
+	assert.Nil(t, n.NotifyRelation(context.Background(), "quan12yt@gmail.com", "hau@gmail.com", model.StatusFriend))
+
+	// the second mail is sent while the first waits a minute for its retry
+	assert.Eventually(t, func() bool { return len(server.received()) == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"<hau@gmail.com>"}, server.received()[0].to)
+	assert.Equal(t, 1, server.rejections("quang@gmail.com"))
+}
+
+func TestNotifierQueue(t *testing.T) {
+	n := NewNotifier("127.0.0.1:1", "noreply@friends.local", WithQueueSize(0))
+	n.Close()
+
+	err := n.NotifyRelation(context.Background(), "quan12yt@gmail.com", "quang@gmail.com", model.StatusFriend)
+	assert.Equal(t, ErrClosed, err)
+
+	n = &Notifier{templates: DefaultTemplates(), queue: make(chan message), ctx: context.Background()}
+	err = n.NotifyRelation(context.Background(), "quan12yt@gmail.com", "quang@gmail.com", model.StatusFriend)
+	assert.Equal(t, ErrQueueFull, err)
+}
+
+func TestLoadTemplates(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "friend.subject.tmpl"), []byte("New friend:\n{{.Actor}}"), 0644))
+
+	templates, err := LoadTemplates(dir)
+	assert.Nil(t, err)
+	subject, body, err := templates.Render(KindFriend, Data{Actor: "quan12yt@gmail.com", Recipient: "quang@gmail.com"})
+	assert.Nil(t, err)
+	assert.Equal(t, "New friend:\nquan12yt@gmail.com", subject)
+	assert.Equal(t, "Hello quang@gmail.com,\n\nquan12yt@gmail.com added you as a friend.\n", body)
+	n := &Notifier{from: "noreply@friends.local"}
+	assert.Contains(t, string(n.compose(message{to: "quang@gmail.com", subject: subject, body: body})),
+		"Subject: New friend: quan12yt@gmail.com\r\n")
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "update.body.tmpl"), []byte("{{.Missing}}"), 0644))
+	templates, err = LoadTemplates(dir)
+	assert.Nil(t, err)
+	_, _, err = templates.Render(KindUpdate, Data{})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,116 @@
+package mail
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"text/template"
+)
+
+// Kind names the event a mail is sent for, and the files of its templates.
+type Kind string
+
+const (
+	// KindFriend is sent to the email someone added as a friend.
+	KindFriend Kind = "friend"
+	// KindSubscribe is sent to the email someone subscribed to.
+	KindSubscribe Kind = "subscribe"
+	// KindMention is sent to the emails mentioned by a posted update.
+	KindMention Kind = "mention"
+	// KindUpdate is sent to the other recipients of a posted update.
+	KindUpdate Kind = "update"
+)
+
+var kinds = []Kind{KindFriend, KindSubscribe, KindMention, KindUpdate}
+
+// Data is what the templates are executed with. Text and UpdateId are only
+// set for updates.
+type Data struct {
+	Actor     string
+	Recipient string
+	Text      string
+	UpdateId  string
+}
+
+var defaultTemplates = map[Kind][2]string{
+	KindFriend: {
+		"{{.Actor}} added you as a friend",
+		"Hello {{.Recipient}},\n\n{{.Actor}} added you as a friend.\n",
+	},
+	KindSubscribe: {
+		"{{.Actor}} subscribed to your updates",
+		"Hello {{.Recipient}},\n\n{{.Actor}} subscribed to your updates.\n",
+	},
+	KindMention: {
+		"{{.Actor}} mentioned you",
+		"Hello {{.Recipient}},\n\n{{.Actor}} mentioned you in an update:\n\n{{.Text}}\n",
+	},
+	KindUpdate: {
+		"New update from {{.Actor}}",
+		"Hello {{.Recipient}},\n\n{{.Actor}} posted an update:\n\n{{.Text}}\n",
+	},
+}
+
+// Templates renders the subject and the body of the mail of each Kind.
+type Templates struct {
+	subjects map[Kind]*template.Template
+	bodies   map[Kind]*template.Template
+}
+
+// DefaultTemplates returns the built-in templates.
+func DefaultTemplates() *Templates {
+	t, err := LoadTemplates("")
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// LoadTemplates returns the built-in templates, replaced by the files
+// <kind>.subject.tmpl and <kind>.body.tmpl of dir that exist, such as
+// friend.subject.tmpl. An empty dir keeps every built-in template.
+func LoadTemplates(dir string) (*Templates, error) {
+	t := &Templates{
+		subjects: make(map[Kind]*template.Template),
+		bodies:   make(map[Kind]*template.Template),
+	}
+	for _, kind := range kinds {
+		subject, err := load(dir, string(kind)+".subject.tmpl", defaultTemplates[kind][0])
+		if err != nil {
+			return nil, err
+		}
+		body, err := load(dir, string(kind)+".body.tmpl", defaultTemplates[kind][1])
+		if err != nil {
+			return nil, err
+		}
+		t.subjects[kind] = subject
+		t.bodies[kind] = body
+	}
+	return t, nil
+}
+
+func load(dir string, name string, fallback string) (*template.Template, error) {
+	text := fallback
+	if dir != "" {
+		content, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			text = string(content)
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return template.New(name).Option("missingkey=error").Parse(text)
+}
+
+// Render returns the subject and the body of the mail of kind.
+func (t *Templates) Render(kind Kind, data Data) (string, string, error) {
+	var subject, body bytes.Buffer
+	if err := t.subjects[kind].Execute(&subject, data); err != nil {
+		return "", "", err
+	}
+	if err := t.bodies[kind].Execute(&body, data); err != nil {
+		return "", "", err
+	}
+	return subject.String(), body.String(), nil
+}
@@ -0,0 +1,238 @@
+// Package migrate applies the numbered migrations of db/migration and records
+// the applied versions in the schema_migrations table.
+package migrate
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Migration is a pair of files named 000001_name.up.sql and 000001_name.down.sql.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status tells whether a migration has been applied.
+type Status struct {
+	Migration
+	Applied bool
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Load reads the migrations found at the root of fsys, sorted by version.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		m := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || m == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		mg, ok := byVersion[version]
+		if !ok {
+			mg = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mg
+		} else if mg.Name != m[2] {
+			return nil, fmt.Errorf("migration: version %d is used by %s and %s", version, mg.Name, m[2])
+		}
+		if m[3] == "up" {
+			mg.Up = string(content)
+		} else {
+			mg.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mg := range byVersion {
+		if mg.Up == "" {
+			return nil, fmt.Errorf("migration: version %d has no up file", mg.Version)
+		}
+		migrations = append(migrations, *mg)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+type Migrator struct {
+	Db         *sql.DB
+	Migrations []Migration
+}
+
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		Db:         db,
+		Migrations: migrations,
+	}, nil
+}
+
+const createTable = `create table if not exists schema_migrations (
+	version int8 NOT NULL,
+	applied_at timestamptz NOT NULL DEFAULT now(),
+	CONSTRAINT schema_migrations_pk PRIMARY KEY (version)
+)`
+
+func (m *Migrator) applied() (map[int64]bool, error) {
+	if _, err := m.Db.Exec(createTable); err != nil {
+		return nil, err
+	}
+	rows, err := m.Db.Query(`select version from schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := make(map[int64]bool)
+	for rows.Next() {
+		var version int64
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+// Status lists every migration and whether it has been applied.
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	status := make([]Status, len(m.Migrations))
+	for i, mg := range m.Migrations {
+		status[i] = Status{Migration: mg, Applied: applied[mg.Version]}
+	}
+	return status, nil
+}
+
+// Up applies every pending migration.
+func (m *Migrator) Up() error {
+	if len(m.Migrations) == 0 {
+		return nil
+	}
+	return m.Goto(m.Migrations[len(m.Migrations)-1].Version)
+}
+
+// Down reverts the last n applied migrations.
+func (m *Migrator) Down(n int) error {
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+	for i := len(m.Migrations) - 1; i >= 0 && n > 0; i-- {
+		if !applied[m.Migrations[i].Version] {
+			continue
+		}
+		if err := m.apply(m.Migrations[i], false); err != nil {
+			return err
+		}
+		n--
+	}
+	return nil
+}
+
+// Goto applies or reverts migrations until exactly the ones up to version
+// are applied. Version 0 reverts everything.
+func (m *Migrator) Goto(version int64) error {
+	found := version == 0
+	for _, mg := range m.Migrations {
+		found = found || mg.Version == version
+	}
+	if !found {
+		return fmt.Errorf("migration: version %d not found", version)
+	}
+
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+	for _, mg := range m.Migrations {
+		if mg.Version <= version && !applied[mg.Version] {
+			if err := m.apply(mg, true); err != nil {
+				return err
+			}
+		}
+	}
+	for i := len(m.Migrations) - 1; i >= 0; i-- {
+		mg := m.Migrations[i]
+		if mg.Version > version && applied[mg.Version] {
+			if err := m.apply(mg, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// apply runs one migration and records it in a single transaction. The table
+// lock makes concurrent migrators wait, the one coming second skips the
+// migration.
+func (m *Migrator) apply(mg Migration, up bool) (err error) {
+	tx, err := m.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.Exec(`lock table schema_migrations in exclusive mode`); err != nil {
+		return err
+	}
+	var count int
+	if err = tx.QueryRow(`select count(*) from schema_migrations where version = $1`, mg.Version).Scan(&count); err != nil {
+		return err
+	}
+	if (count > 0) == up {
+		return tx.Commit()
+	}
+
+	script, record := mg.Up, `insert into schema_migrations (version) values ($1)`
+	if !up {
+		script, record = mg.Down, `delete from schema_migrations where version = $1`
+	}
+	if strings.TrimSpace(script) != "" {
+		if _, err = tx.Exec(script); err != nil {
+			return wrap(mg, up, err)
+		}
+	}
+	if _, err = tx.Exec(record, mg.Version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func wrap(mg Migration, up bool, err error) error {
+	direction := "up"
+	if !up {
+		direction = "down"
+	}
+	return fmt.Errorf("migration %06d_%s.%s.sql: %w", mg.Version, mg.Name, direction, err)
+}
@@ -0,0 +1,173 @@
+package migrate
+
+import (
+	"errors"
+	"friend-management-v1/db/migration"
+	"regexp"
+	"testing"
+	"testing/fstest"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var files = fstest.MapFS{
+	"000002_second.up.sql":   {Data: []byte("create table b (id int)")},
+	"000002_second.down.sql": {Data: []byte("drop table b")},
+	"000001_first.up.sql":    {Data: []byte("create table a (id int)")},
+	"000001_first.down.sql":  {Data: []byte("drop table a")},
+	"README.md":              {Data: []byte("not a migration")},
+}
+
+func newMigrator(t *testing.T) (*Migrator, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	m, err := New(db, files)
+	assert.Nil(t, err)
+	return m, mock
+}
+
+func expectApplied(mock sqlmock.Sqlmock, versions ...int64) {
+	mock.ExpectExec(regexp.QuoteMeta(createTable)).WillReturnResult(sqlmock.NewResult(0, 0))
+	rows := sqlmock.NewRows([]string{"version"})
+	for _, v := range versions {
+		rows.AddRow(v)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(`select version from schema_migrations`)).WillReturnRows(rows)
+}
+
+func expectApply(mock sqlmock.Sqlmock, version int64, script string, up bool) {
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`lock table schema_migrations in exclusive mode`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	count := 0
+	if !up {
+		count = 1
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(`select count(*) from schema_migrations where version = $1`)).
+		WithArgs(version).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(count))
+	mock.ExpectExec(regexp.QuoteMeta(script)).WillReturnResult(sqlmock.NewResult(0, 0))
+	if up {
+		mock.ExpectExec(regexp.QuoteMeta(`insert into schema_migrations (version) values ($1)`)).
+			WithArgs(version).WillReturnResult(sqlmock.NewResult(1, 1))
+	} else {
+		mock.ExpectExec(regexp.QuoteMeta(`delete from schema_migrations where version = $1`)).
+			WithArgs(version).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectCommit()
+}
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load(files)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(migrations))
+	assert.Equal(t, int64(1), migrations[0].Version)
+	assert.Equal(t, "first", migrations[0].Name)
+	assert.Equal(t, "drop table a", migrations[0].Down)
+	assert.Equal(t, int64(2), migrations[1].Version)
+}
+
+func TestLoadMissingUp(t *testing.T) {
+	_, err := Load(fstest.MapFS{"000001_first.down.sql": {Data: []byte("drop table a")}})
+
+	assert.NotNil(t, err)
+	assert.Equal(t, "migration: version 1 has no up file", err.Error())
+}
+
+func TestLoadEmbedded(t *testing.T) {
+	migrations, err := Load(migration.Files)
+
+	assert.Nil(t, err)
+	assert.NotEmpty(t, migrations)
+	assert.Equal(t, "init_schema", migrations[0].Name)
+	assert.NotContains(t, migrations[0].Up, "=email")
+}
+
+func TestUp(t *testing.T) {
+	m, mock := newMigrator(t)
+	expectApplied(mock, 1)
+	expectApply(mock, 2, "create table b (id int)", true)
+
+	err := m.Up()
+
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestUpFailedRollsBack(t *testing.T) {
+	m, mock := newMigrator(t)
+	expectApplied(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`lock table schema_migrations in exclusive mode`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`select count(*) from schema_migrations where version = $1`)).
+		WithArgs(int64(1)).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec(regexp.QuoteMeta("create table a (id int)")).WillReturnError(errors.New("syntax error"))
+	mock.ExpectRollback()
+
+	err := m.Up()
+
+	assert.NotNil(t, err)
+	assert.Equal(t, "migration 000001_first.up.sql: syntax error", err.Error())
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestUpSkipsMigrationAppliedConcurrently(t *testing.T) {
+	m, mock := newMigrator(t)
+	expectApplied(mock, 1)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`lock table schema_migrations in exclusive mode`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`select count(*) from schema_migrations where version = $1`)).
+		WithArgs(int64(2)).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectCommit()
+
+	err := m.Up()
+
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestDown(t *testing.T) {
+	m, mock := newMigrator(t)
+	expectApplied(mock, 1, 2)
+	expectApply(mock, 2, "drop table b", false)
+
+	err := m.Down(1)
+
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestGoto(t *testing.T) {
+	m, mock := newMigrator(t)
+	expectApplied(mock, 1, 2)
+	expectApply(mock, 2, "drop table b", false)
+	expectApply(mock, 1, "drop table a", false)
+
+	err := m.Goto(0)
+
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestGotoUnknownVersion(t *testing.T) {
+	m, _ := newMigrator(t)
+
+	err := m.Goto(7)
+
+	assert.NotNil(t, err)
+	assert.Equal(t, "migration: version 7 not found", err.Error())
+}
+
+func TestStatus(t *testing.T) {
+	m, mock := newMigrator(t)
+	expectApplied(mock, 1)
+
+	status, err := m.Status()
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(status))
+	assert.Equal(t, true, status[0].Applied)
+	assert.Equal(t, false, status[1].Applied)
+}
@@ -0,0 +1,346 @@
+// Package ratelimit limits the requests of the API with token buckets kept
+// per client, per requestor email and per endpoint.
+package ratelimit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"friend-management-v1/internal/auth"
+	"friend-management-v1/model"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rate lets Burst requests through at once, then one more every Per / Burst.
+// The zero Rate sets no limit.
+type Rate struct {
+	Burst int
+	Per   time.Duration
+}
+
+func (r Rate) enabled() bool {
+	return r.Burst > 0 && r.Per > 0
+}
+
+// sweepEvery is how often the buckets that are full again are dropped.
+const sweepEvery = time.Minute
+
+// Limiter keeps the token buckets. A request takes one token from the bucket
+// of its client, of its requestor and of its endpoint, or none when any of
+// them is empty.
+type Limiter struct {
+	client    Rate
+	requestor Rate
+	endpoint  Rate
+	endpoints map[string]Rate
+	now       func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// Option configures a Limiter.
+type Option func(l *Limiter)
+
+// WithClientRate limits each API key or token, or each IP address when the
+// request is not authenticated.
+func WithClientRate(rate Rate) Option {
+	return func(l *Limiter) {
+		l.client = rate
+	}
+}
+
+// WithRequestorRate limits each email a request is made for: the email of its
+// principal, or without one its requestor, sender or email, or the first of
+// its friends.
+func WithRequestorRate(rate Rate) Option {
+	return func(l *Limiter) {
+		l.requestor = rate
+	}
+}
+
+// WithEndpointRate limits the requests to each endpoint, whoever makes them,
+// with the entry of perEndpoint for its path, such as /api/add, or with rate.
+func WithEndpointRate(rate Rate, perEndpoint map[string]Rate) Option {
+	return func(l *Limiter) {
+		l.endpoint = rate
+		l.endpoints = perEndpoint
+	}
+}
+
+// WithClock replaces time.Now when refilling the buckets.
+func WithClock(now func() time.Time) Option {
+	return func(l *Limiter) {
+		l.now = now
+	}
+}
+
+func New(opts ...Option) *Limiter {
+	l := &Limiter{
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Enabled tells whether any limit is set.
+func (l *Limiter) Enabled() bool {
+	if l.client.enabled() || l.requestor.enabled() || l.endpoint.enabled() {
+		return true
+	}
+	for _, rate := range l.endpoints {
+		if rate.enabled() {
+			return true
+		}
+	}
+	return false
+}
+
+type bucket struct {
+	rate   Rate
+	tokens float64
+	last   time.Time
+}
+
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last)
+	if elapsed > 0 {
+		b.tokens = math.Min(float64(b.rate.Burst), b.tokens+float64(b.rate.Burst)*elapsed.Seconds()/b.rate.Per.Seconds())
+		b.last = now
+	}
+}
+
+// wait returns how long until the bucket holds tokens.
+func (b *bucket) wait(tokens float64) time.Duration {
+	if b.tokens >= tokens {
+		return 0
+	}
+	return time.Duration((tokens - b.tokens) * float64(b.rate.Per) / float64(b.rate.Burst))
+}
+
+// limit is one bucket a request takes a token from.
+type limit struct {
+	key    string
+	rate   Rate
+	reason string
+}
+
+// Result tells whether a request may go through, with the state of its most
+// exhausted bucket.
+type Result struct {
+	Allowed bool
+	// Reason names the empty bucket of a request not allowed.
+	Reason    string
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again, and RetryAfter
+	// until it holds a token.
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+func (l *Limiter) take(limits []limit) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if now.Sub(l.lastSweep) >= sweepEvery {
+		l.sweep(now)
+	}
+	buckets := make([]*bucket, len(limits))
+	for i, lim := range limits {
+		b, ok := l.buckets[lim.key]
+		if !ok || b.rate != lim.rate {
+			b = &bucket{rate: lim.rate, tokens: float64(lim.rate.Burst), last: now}
+			l.buckets[lim.key] = b
+		}
+		b.refill(now)
+		if b.tokens < 1 {
+			return Result{
+				Reason:     limits[i].reason,
+				Limit:      b.rate.Burst,
+				Reset:      b.wait(float64(b.rate.Burst)),
+				RetryAfter: b.wait(1),
+			}
+		}
+		buckets[i] = b
+	}
+	result := Result{Allowed: true}
+	for i, b := range buckets {
+		b.tokens--
+		if remaining := int(b.tokens); i == 0 || remaining < result.Remaining {
+			result.Limit = b.rate.Burst
+			result.Remaining = remaining
+			result.Reset = b.wait(float64(b.rate.Burst))
+		}
+	}
+	return result
+}
+
+// sweep drops the buckets that are full, a new bucket starts full anyway.
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.rate.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// Middleware answers 429 to the requests over a limit, and 413 to a body over
+// maxBodyBytes when it reads the requestor from it. Every response carries
+// the X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset headers
+// of the most exhausted bucket, and a 429 the Retry-After header. It must run
+// after the authentication to limit the principals rather than their
+// addresses.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limits, err := l.limits(r)
+		if err == errBodyTooLarge {
+			writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if len(limits) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		result := l.take(limits)
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		w.Header().Set("X-RateLimit-Reset", strconv.Itoa(seconds(result.Reset)))
+		if !result.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(seconds(result.RetryAfter)))
+			writeError(w, http.StatusTooManyRequests, "too many requests "+result.Reason)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	response, _ := json.Marshal(model.NewErrorResponse(message))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(response)
+}
+
+// seconds rounds d up to whole seconds.
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+func (l *Limiter) limits(r *http.Request) ([]limit, error) {
+	var limits []limit
+	if l.client.enabled() {
+		if p, ok := auth.FromContext(r.Context()); ok {
+			limits = append(limits, limit{"client:key:" + p.Id, l.client, "from this client"})
+		} else {
+			limits = append(limits, limit{"client:ip:" + clientIP(r), l.client, "from this client"})
+		}
+	}
+	if l.requestor.enabled() {
+		email, err := requestorOf(r)
+		if err != nil {
+			return nil, err
+		}
+		if email != "" {
+			limits = append(limits, limit{"requestor:" + email, l.requestor, "for " + email})
+		}
+	}
+	path := endpointOf(r)
+	rate, ok := l.endpoints[path]
+	if !ok {
+		rate = l.endpoint
+	}
+	if rate.enabled() {
+		limits = append(limits, limit{"endpoint:" + path, rate, "to " + path})
+	}
+	return limits, nil
+}
+
+// endpointOf returns the path of the endpoint a request is routed to, without
+// the format extension middleware.URLFormat strips, so /api/add.json shares
+// the bucket of /api/add.
+func endpointOf(r *http.Request) string {
+	path := r.URL.Path
+	if strings.Index(path, ".") > 0 {
+		base := strings.LastIndex(path, "/")
+		if idx := strings.LastIndex(path[base:], "."); idx > 0 {
+			return path[:base+idx]
+		}
+	}
+	return path
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+type requestBody struct {
+	Requestor string   `json:"requestor"`
+	Sender    string   `json:"sender"`
+	Email     string   `json:"email"`
+	Friends   []string `json:"friends"`
+}
+
+// maxBodyBytes bounds the body read for the requestor of a request.
+const maxBodyBytes = 1 << 20
+
+var errBodyTooLarge = fmt.Errorf("request body must not be larger than %d bytes", maxBodyBytes)
+
+// requestorOf returns the email the request is made for: the email of the
+// principal, which the body can not change, or the one of the JSON body, put
+// back for the handler. It fails on a body over maxBodyBytes, or that could
+// not be read.
+func requestorOf(r *http.Request) (string, error) {
+	if p, ok := auth.FromContext(r.Context()); ok && p.Email != "" {
+		return p.Email, nil
+	}
+	if r.Body == nil || r.Body == http.NoBody {
+		return "", nil
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBodyBytes+1))
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	if len(body) > maxBodyBytes {
+		return "", errBodyTooLarge
+	}
+	var rq requestBody
+	if json.Unmarshal(body, &rq) != nil {
+		return "", nil
+	}
+	switch {
+	case rq.Requestor != "":
+		return rq.Requestor, nil
+	case rq.Sender != "":
+		return rq.Sender, nil
+	case rq.Email != "":
+		return rq.Email, nil
+	case len(rq.Friends) > 0:
+		return rq.Friends[0], nil
+	}
+	return "", nil
+}
@@ -0,0 +1,207 @@
+package ratelimit
+
+import (
+	"bytes"
+	"encoding/json"
+	"friend-management-v1/internal/auth"
+	"friend-management-v1/model"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func newClock() *clock {
+	return &clock{now: time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func serve(handler http.Handler, path, body, remoteAddr string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest("POST", path, bytes.NewBufferString(body))
+	request.RemoteAddr = remoteAddr
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, request)
+	return rr
+}
+
+func echo() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write(body)
+	})
+}
+
+func TestClientRate(t *testing.T) {
+	c := newClock()
+	handler := New(WithClientRate(Rate{Burst: 2, Per: time.Minute}), WithClock(c.Now)).Middleware(echo())
+
+	rr := serve(handler, "/api/add", `{}`, "10.0.0.1:5000")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "2", rr.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "1", rr.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "30", rr.Header().Get("X-RateLimit-Reset"))
+	assert.Equal(t, http.StatusOK, serve(handler, "/api/add", `{}`, "10.0.0.1:5001").Code)
+
+	rr = serve(handler, "/api/add", `{}`, "10.0.0.1:5000")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "30", rr.Header().Get("Retry-After"))
+	assert.Equal(t, "0", rr.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "60", rr.Header().Get("X-RateLimit-Reset"))
+	var response model.ErrorResponse
+	assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.False(t, response.Success)
+	assert.Equal(t, "too many requests from this client", response.Error)
+
+	assert.Equal(t, http.StatusOK, serve(handler, "/api/add", `{}`, "10.0.0.2:5000").Code)
+
+	c.now = c.now.Add(30 * time.Second)
+	assert.Equal(t, http.StatusOK, serve(handler, "/api/add", `{}`, "10.0.0.1:5000").Code)
+	assert.Equal(t, http.StatusTooManyRequests, serve(handler, "/api/add", `{}`, "10.0.0.1:5000").Code)
+}
+
+func TestClientRateOfPrincipal(t *testing.T) {
+	limiter := New(WithClientRate(Rate{Burst: 1, Per: time.Minute}), WithClock(newClock().Now))
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := auth.Principal{Id: r.Header.Get("X-Principal")}
+		limiter.Middleware(echo()).ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), p)))
+	})
+
+	for _, principal := range []string{"mobile", "support"} {
+		request := httptest.NewRequest("POST", "/api/add", nil)
+		request.Header.Set("X-Principal", principal)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, request)
+		assert.Equal(t, http.StatusOK, rr.Code)
+	}
+}
+
+func TestRequestorRate(t *testing.T) {
+	handler := New(WithRequestorRate(Rate{Burst: 1, Per: time.Minute}), WithClock(newClock().Now)).Middleware(echo())
+
+	body := `{"friends": ["quan@gmail.com", "quang@gmail.com"]}`
+	rr := serve(handler, "/api/add", body, "10.0.0.1:5000")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, body, rr.Body.String())
+
+	rr = serve(handler, "/api/block", `{"requestor": "quan@gmail.com", "target": "hau@gmail.com"}`, "10.0.0.2:5000")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Contains(t, rr.Body.String(), "too many requests for quan@gmail.com")
+
+	assert.Equal(t, http.StatusOK, serve(handler, "/api/retrieve", `{"sender": "quang@gmail.com", "text": "hi"}`, "10.0.0.1:5000").Code)
+	assert.Equal(t, http.StatusOK, serve(handler, "/api/users", "", "10.0.0.1:5000").Code)
+	assert.Equal(t, http.StatusOK, serve(handler, "/api/users", "", "10.0.0.1:5000").Code)
+}
+
+func TestRequestorRateOfPrincipal(t *testing.T) {
+	limiter := New(WithRequestorRate(Rate{Burst: 1, Per: time.Minute}), WithClock(newClock().Now))
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := auth.Principal{Id: "mobile", Email: r.Header.Get("X-Principal")}
+		limiter.Middleware(echo()).ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), p)))
+	})
+	serve := func(principal string, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest("POST", "/api/block", bytes.NewBufferString(body))
+		request.Header.Set("X-Principal", principal)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, request)
+		return rr
+	}
+
+	// the principal is limited whatever requestor its body names
+	assert.Equal(t, http.StatusOK, serve("quan@gmail.com", `{"requestor": "hau@gmail.com"}`).Code)
+	rr := serve("quan@gmail.com", `{"requestor": "quang@gmail.com"}`)
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Contains(t, rr.Body.String(), "too many requests for quan@gmail.com")
+	assert.Equal(t, http.StatusOK, serve("hau@gmail.com", `{"requestor": "hau@gmail.com"}`).Code)
+}
+
+func TestRequestorOfLargeBody(t *testing.T) {
+	handler := New(WithRequestorRate(Rate{Burst: 1, Per: time.Minute}), WithClock(newClock().Now)).Middleware(echo())
+	prefix := `{"requestor": "quan@gmail.com", "text": "`
+	body := prefix + strings.Repeat("a", maxBodyBytes) + `"}`
+
+	rr := serve(handler, "/api/block", body, "10.0.0.1:5000")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	var response model.ErrorResponse
+	assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.False(t, response.Success)
+	assert.Equal(t, "request body must not be larger than 1048576 bytes", response.Error)
+
+	// a body of maxBodyBytes reaches the handler whole and takes a token
+	body = prefix + strings.Repeat("a", maxBodyBytes-len(prefix)-2) + `"}`
+	rr = serve(handler, "/api/block", body, "10.0.0.1:5000")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, body, rr.Body.String())
+	assert.Equal(t, http.StatusTooManyRequests, serve(handler, "/api/block", `{"requestor": "quan@gmail.com"}`, "10.0.0.1:5000").Code)
+}
+
+func TestEndpointRate(t *testing.T) {
+	handler := New(
+		WithEndpointRate(Rate{Burst: 2, Per: time.Minute}, map[string]Rate{"/api/add": {Burst: 1, Per: time.Minute}}),
+		WithClock(newClock().Now)).Middleware(echo())
+
+	assert.Equal(t, http.StatusOK, serve(handler, "/api/add", `{}`, "10.0.0.1:5000").Code)
+	rr := serve(handler, "/api/add", `{}`, "10.0.0.2:5000")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "60", rr.Header().Get("Retry-After"))
+	assert.Contains(t, rr.Body.String(), "too many requests to /api/add")
+
+	assert.Equal(t, http.StatusOK, serve(handler, "/api/block", `{}`, "10.0.0.1:5000").Code)
+	assert.Equal(t, http.StatusOK, serve(handler, "/api/block", `{}`, "10.0.0.1:5000").Code)
+	assert.Equal(t, http.StatusTooManyRequests, serve(handler, "/api/block", `{}`, "10.0.0.1:5000").Code)
+
+	// a format extension reaches the same handler, it takes from the same bucket
+	rr = serve(handler, "/api/add.json", `{}`, "10.0.0.1:5000")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Contains(t, rr.Body.String(), "too many requests to /api/add")
+	assert.Equal(t, http.StatusTooManyRequests, serve(handler, "/api/block.x", `{}`, "10.0.0.1:5000").Code)
+	assert.Equal(t, http.StatusOK, serve(handler, "/api/v1.2/add", `{}`, "10.0.0.1:5000").Code)
+}
+
+func TestDeniedRequestTakesNoToken(t *testing.T) {
+	handler := New(
+		WithClientRate(Rate{Burst: 2, Per: time.Minute}),
+		WithRequestorRate(Rate{Burst: 1, Per: time.Minute}),
+		WithClock(newClock().Now)).Middleware(echo())
+
+	rr := serve(handler, "/api/block", `{"requestor": "quan@gmail.com"}`, "10.0.0.1:5000")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "1", rr.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "0", rr.Header().Get("X-RateLimit-Remaining"))
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusTooManyRequests, serve(handler, "/api/block", `{"requestor": "quan@gmail.com"}`, "10.0.0.1:5000").Code)
+	}
+	rr = serve(handler, "/api/block", `{"requestor": "hau@gmail.com"}`, "10.0.0.1:5000")
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestSweep(t *testing.T) {
+	c := newClock()
+	limiter := New(WithClientRate(Rate{Burst: 2, Per: time.Second}), WithClock(c.Now))
+	handler := limiter.Middleware(echo())
+	serve(handler, "/api/add", `{}`, "10.0.0.1:5000")
+	serve(handler, "/api/add", `{}`, "10.0.0.2:5000")
+	assert.Len(t, limiter.buckets, 2)
+
+	c.now = c.now.Add(sweepEvery)
+	serve(handler, "/api/add", `{}`, "10.0.0.3:5000")
+
+	assert.Len(t, limiter.buckets, 1)
+}
+
+func TestEnabled(t *testing.T) {
+	assert.False(t, New().Enabled())
+	assert.False(t, New(WithEndpointRate(Rate{}, map[string]Rate{"/api/add": {}})).Enabled())
+	assert.True(t, New(WithEndpointRate(Rate{}, map[string]Rate{"/api/add": {Burst: 1, Per: time.Second}})).Enabled())
+	assert.True(t, New(WithRequestorRate(Rate{Burst: 1, Per: time.Second})).Enabled())
+}
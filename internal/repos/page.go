@@ -0,0 +1,68 @@
+package repos
+
+import (
+	"encoding/base64"
+	"errors"
+	"friend-management-v1/model"
+	"sort"
+	"strconv"
+)
+
+// ErrInvalidCursor is returned for a cursor that is not the NextCursor of a
+// previous page.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// A cursor is the id of the last email of a page. Pages are ordered by id, so
+// the next page starts after it even when emails are added meanwhile.
+func encodeCursor(id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(id))
+}
+
+func decodeCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	id, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil || id < 1 {
+		return 0, ErrInvalidCursor
+	}
+	return id, nil
+}
+
+// pageOf returns the page of the emails of ids, the same way RelationRepoImp
+// pages its queries. ids must not contain duplicates.
+func (repo *RelationRepoMemory) pageOf(ids []string, page model.Page) (model.EmailPage, error) {
+	after, err := decodeCursor(page.Cursor)
+	if err != nil {
+		return model.EmailPage{}, err
+	}
+	sorted := make([]int, 0, len(ids))
+	for _, id := range ids {
+		i, err := strconv.Atoi(id)
+		if err != nil {
+			return model.EmailPage{}, err
+		}
+		sorted = append(sorted, i)
+	}
+	sort.Ints(sorted)
+
+	result := model.EmailPage{Emails: []string{}, Total: len(sorted)}
+	var last string
+	for _, i := range sorted {
+		if int64(i) <= after {
+			continue
+		}
+		if len(result.Emails) == page.Limit {
+			result.NextCursor = encodeCursor(last)
+			break
+		}
+		last = strconv.Itoa(i)
+		email, _ := repo.emailOf(last)
+		result.Emails = append(result.Emails, email)
+	}
+	return result, nil
+}
@@ -1,12 +1,36 @@
 package repos
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"friend-management-v1/model"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/lib/pq"
 )
 
 type RelationRepoImp struct {
 	Db *sql.DB
+	// tx is set on the repository passed to the function of Transaction.
+	tx *sql.Tx
+}
+
+// dbtx is implemented by both *sql.DB and *sql.Tx.
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func (repo *RelationRepoImp) conn() dbtx {
+	if repo.tx != nil {
+		return repo.tx
+	}
+	return repo.Db
 }
 
 func NewRelationRepo(db *sql.DB) RelationRepo {
@@ -15,90 +39,436 @@ func NewRelationRepo(db *sql.DB) RelationRepo {
 	}
 }
 
-func (repo *RelationRepoImp) CheckIfExist(id1 string, id2 string, status string) bool {
+// CheckIfExist reports whether id1 has a relation with status to id2.
+func (repo *RelationRepoImp) CheckIfExist(ctx context.Context, id1 string, id2 string, status model.RelationStatus) (bool, error) {
 	sql_query := `select fr.relation_id 
 	from friend_relationship fr 
-	where ((fr.your_id = $1 and fr.friend_id = $2) or (fr.your_id =$2 and fr.friend_id =$1)) and status = $3`
-	rows, _ := repo.Db.Query(sql_query, id1, id2, status)
-	return (rows.Next())
+	where fr.your_id = $1 and fr.friend_id = $2 and status = $3`
+	rows, err := repo.conn().QueryContext(ctx, sql_query, id1, id2, status)
+	if err != nil {
+		return false, dbError(ctx, err)
+	}
+	defer rows.Close()
+	exist := rows.Next()
+	if err := rows.Err(); err != nil {
+		return false, dbError(ctx, err)
+	}
+	return exist, nil
+}
+
+// Transaction runs fn with a repository bound to one transaction, committed
+// when fn returns nil and rolled back otherwise. Called inside fn it joins the
+// running transaction.
+func (repo *RelationRepoImp) Transaction(ctx context.Context, fn func(tx RelationRepo) error) error {
+	if repo.tx != nil {
+		return fn(repo)
+	}
+	tx, err := repo.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return dbError(ctx, err)
+	}
+	if err := fn(&RelationRepoImp{Db: repo.Db, tx: tx}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return dbError(ctx, err)
+	}
+	return nil
+}
+
+// LockEmails locks the email rows of ids until the transaction ends. They are
+// locked in id order so two writes about the same emails can not deadlock.
+func (repo *RelationRepoImp) LockEmails(ctx context.Context, ids []string) error {
+	params := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		params[i] = "$" + strconv.Itoa(i+1)
+		args[i] = id
+	}
+	sql_query := `select e.email_id from email e
+	where e.email_id in (` + strings.Join(params, ", ") + `)
+	order by e.email_id for update`
+
+	if _, err := repo.conn().ExecContext(ctx, sql_query, args...); err != nil {
+		return dbError(ctx, err)
+	}
+	return nil
+}
+
+func (repo *RelationRepoImp) GetIdFromEmail(ctx context.Context, email string) (string, error) {
+	sql_query := `select e.email_id from email e where e.email = $1`
+
+	var id string
+	err := repo.conn().QueryRowContext(ctx, sql_query, email).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", &EmailNotFoundError{Email: email}
+	}
+	if err != nil {
+		return "", dbError(ctx, err)
+	}
+	return id, nil
+}
+
+func (repo *RelationRepoImp) CreateEmail(ctx context.Context, email string) (string, error) {
+	sql_query := `insert into email (email)
+	values ($1)
+	returning email_id`
+
+	var id string
+	if err := repo.conn().QueryRowContext(ctx, sql_query, email).Scan(&id); err != nil {
+		return "", dbError(ctx, err)
+	}
+	return id, nil
 }
 
-func (repo *RelationRepoImp) GetIdFromEmail(email string) (string, error) {
-	sql_query := "select e.email_id from email e where e.email = '" + email + "'"
+func (repo *RelationRepoImp) GetAllEmails(ctx context.Context) ([]model.User, error) {
+	sql_query := `select e.email_id, e.email
+	from email e
+	order by e.email_id`
 
-	rows, err := repo.Db.Query(sql_query)
+	rows, err := repo.conn().QueryContext(ctx, sql_query)
 	if err != nil {
-		return "", err
+		return nil, dbError(ctx, err)
 	}
-	var ids string
+	defer rows.Close()
+	users := []model.User{}
 	for rows.Next() {
-		var i string
-		err = rows.Scan(&i)
+		var user model.User
+		err = rows.Scan(&user.Id, &user.Email)
 		if err != nil {
-			return "", err
+			return nil, dbError(ctx, err)
 		}
-		ids = i
+		users = append(users, user)
 	}
-	if ids == "" {
-		return "", errors.New("email: " + email + " is not exist in database")
-	}
-	return ids, nil
+	return users, nil
 }
 
-func (repo *RelationRepoImp) GetEmailByStatus(id string, status string) ([]string, error) {
-	sql_query := `select distinct e.email
-	from email e left join friend_relationship fr 
-	on (e.email_id = fr.friend_id) or (e.email_id = fr.your_id)
-	where (fr.your_id = $1 or fr.friend_id = $1) and fr.status = $2 and e.email_id != $1`
+func (repo *RelationRepoImp) GetUnregisteredEmails(ctx context.Context, emails []string) ([]string, error) {
+	sql_query := `select m.email
+	from unnest($1::text[]) with ordinality m(email, n)
+	where not exists (select 1 from email e where e.email = m.email)
+	order by m.n`
 
-	rows, err := repo.Db.Query(sql_query, id, status)
+	rows, err := repo.conn().QueryContext(ctx, sql_query, pq.Array(emails))
 	if err != nil {
-		return nil, err
+		return nil, dbError(ctx, err)
 	}
-	var friends []string
+	defer rows.Close()
+	unregistered := []string{}
 	for rows.Next() {
 		var email string
 		err = rows.Scan(&email)
 		if err != nil {
-			return nil, err
+			return nil, dbError(ctx, err)
 		}
-		friends = append(friends, email)
+		unregistered = append(unregistered, email)
 	}
-	// defer pro.Db.Close()
-	return friends, nil
+	if err := rows.Err(); err != nil {
+		return nil, dbError(ctx, err)
+	}
+	return unregistered, nil
+}
+
+// GetEmailByStatus returns the emails id has a relation with status to. Unless
+// the status is BLOCK, an email is left out when either side has blocked the
+// other.
+func (repo *RelationRepoImp) GetEmailByStatus(ctx context.Context, id string, status model.RelationStatus, page model.Page) (model.EmailPage, error) {
+	sql_query := `select distinct e.email_id, e.email
+	from friend_relationship fr join email e
+	on e.email_id = fr.friend_id
+	where fr.your_id = $1 and fr.status = $2
+	and ($2 = 'BLOCK' or not exists (select 1 from friend_relationship b
+		where b.status = 'BLOCK'
+		and ((b.your_id = $1 and b.friend_id = e.email_id) or (b.your_id = e.email_id and b.friend_id = $1))))`
+
+	return repo.emailPage(ctx, sql_query, []interface{}{id, status}, page)
 }
 
-func (repo *RelationRepoImp) GetRetrivableEmails(id string) ([]string, error) {
-	sql_query := `select distinct e.email 
-	from friend_relationship fr left join email e
-	on e.email_id = fr.your_id 
-	where fr.friend_id = $1 and ((fr.status = 'FRIEND' or fr.status = 'SUBCRIBE') and fr.status != 'BLOCK')`
+// GetCommonEmails returns the friends all of ids have in common, leaving out
+// the emails blocked by or blocking any of them. ids must not repeat.
+func (repo *RelationRepoImp) GetCommonEmails(ctx context.Context, ids []string, page model.Page) (model.EmailPage, error) {
+	sql_query := `select e.email_id, e.email
+	from email e
+	where e.email_id in (select fr.friend_id from friend_relationship fr
+		where fr.your_id = any($1::int8[]) and fr.status = 'FRIEND'
+		group by fr.friend_id
+		having count(distinct fr.your_id) = cardinality($1::int8[]))
+	and not exists (select 1 from friend_relationship b
+		where b.status = 'BLOCK'
+		and ((b.your_id = any($1::int8[]) and b.friend_id = e.email_id) or (b.your_id = e.email_id and b.friend_id = any($1::int8[]))))`
 
-	rows, err := repo.Db.Query(sql_query, id)
+	return repo.emailPage(ctx, sql_query, []interface{}{pq.Array(ids)}, page)
+}
+
+// GetRetrivableEmails returns the friends and subscribers of id, together with
+// the registered emails of mentions, that have not blocked it.
+func (repo *RelationRepoImp) GetRetrivableEmails(ctx context.Context, id string, mentions []string, page model.Page) (model.EmailPage, error) {
+	sql_query := `select e.email_id, e.email
+	from email e
+	where (exists (select 1 from friend_relationship fr
+		where fr.your_id = e.email_id and fr.friend_id = $1 and (fr.status = 'FRIEND' or fr.status = 'SUBCRIBE'))
+		or e.email = any($2))
+	and not exists (select 1 from friend_relationship b
+		where b.your_id = e.email_id and b.friend_id = $1 and b.status = 'BLOCK')`
+
+	return repo.emailPage(ctx, sql_query, []interface{}{id, pq.Array(mentions)}, page)
+}
+
+// GetSuggestions ranks the friends of the friends of id by the number of
+// friends they have in common with id. A friendship hidden by a block between
+// its two emails does not count, and no one blocked by or blocking id is
+// suggested.
+func (repo *RelationRepoImp) GetSuggestions(ctx context.Context, id string, limit int) ([]model.Suggestion, error) {
+	sql_query := `select s.email, count(*), array_agg(m.email order by m.email_id)
+	from friend_relationship f1
+	join friend_relationship f2 on f2.your_id = f1.friend_id and f2.status = 'FRIEND'
+	join email m on m.email_id = f1.friend_id
+	join email s on s.email_id = f2.friend_id
+	where f1.your_id = $1 and f1.status = 'FRIEND'
+	and f2.friend_id <> $1
+	and not exists (select 1 from friend_relationship x
+		where x.your_id = $1 and x.friend_id = f2.friend_id and x.status = 'FRIEND')
+	and not exists (select 1 from friend_relationship b
+		where b.status = 'BLOCK'
+		and ((b.your_id = $1 and b.friend_id in (f1.friend_id, f2.friend_id))
+			or (b.friend_id = $1 and b.your_id in (f1.friend_id, f2.friend_id))
+			or (b.your_id = f1.friend_id and b.friend_id = f2.friend_id)
+			or (b.your_id = f2.friend_id and b.friend_id = f1.friend_id)))
+	group by s.email_id, s.email
+	order by count(*) desc, s.email_id
+	limit $2`
+
+	rows, err := repo.conn().QueryContext(ctx, sql_query, id, limit)
 	if err != nil {
-		return nil, err
+		return nil, dbError(ctx, err)
 	}
-	var friends []string
+	defer rows.Close()
+	suggestions := []model.Suggestion{}
 	for rows.Next() {
-		var email string
-		err = rows.Scan(&email)
+		var suggestion model.Suggestion
+		err = rows.Scan(&suggestion.Email, &suggestion.MutualCount, pq.Array(&suggestion.MutualFriends))
+		if err != nil {
+			return nil, dbError(ctx, err)
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError(ctx, err)
+	}
+	return suggestions, nil
+}
+
+// GetFriendsOf loads the friends of a whole frontier of the path search in one
+// query.
+func (repo *RelationRepoImp) GetFriendsOf(ctx context.Context, ids []string) (map[string][]model.User, error) {
+	sql_query := `select fr.your_id, e.email_id, e.email
+	from friend_relationship fr join email e
+	on e.email_id = fr.friend_id
+	where fr.your_id = any($1::int8[]) and fr.status = 'FRIEND'
+	and not exists (select 1 from friend_relationship b
+		where b.status = 'BLOCK'
+		and ((b.your_id = fr.your_id and b.friend_id = fr.friend_id) or (b.your_id = fr.friend_id and b.friend_id = fr.your_id)))
+	order by fr.your_id, e.email_id`
+
+	rows, err := repo.conn().QueryContext(ctx, sql_query, pq.Array(ids))
+	if err != nil {
+		return nil, dbError(ctx, err)
+	}
+	defer rows.Close()
+	friends := make(map[string][]model.User)
+	for rows.Next() {
+		var id string
+		var friend model.User
+		err = rows.Scan(&id, &friend.Id, &friend.Email)
 		if err != nil {
-			return nil, err
+			return nil, dbError(ctx, err)
 		}
-		friends = append(friends, email)
+		friends[id] = append(friends[id], friend)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError(ctx, err)
 	}
-	// defer pro.Db.Close()
 	return friends, nil
 }
 
-func (repo *RelationRepoImp) AddRelation(ids []string, status string) (bool, error) {
+// emailPage counts the rows of query, which selects email_id and email, and
+// returns the page of them. The cursor and the limit follow the args of query.
+func (repo *RelationRepoImp) emailPage(ctx context.Context, query string, args []interface{}, page model.Page) (model.EmailPage, error) {
+	after, err := decodeCursor(page.Cursor)
+	if err != nil {
+		return model.EmailPage{}, err
+	}
+	result := model.EmailPage{Emails: []string{}}
+	count_query := `select count(*) from (` + query + `) r`
+	if err := repo.conn().QueryRowContext(ctx, count_query, args...).Scan(&result.Total); err != nil {
+		return model.EmailPage{}, dbError(ctx, err)
+	}
+
+	page_query := `select r.email_id, r.email from (` + query + `) r
+	where r.email_id > $` + strconv.Itoa(len(args)+1) + `
+	order by r.email_id
+	limit $` + strconv.Itoa(len(args)+2)
+	rows, err := repo.conn().QueryContext(ctx, page_query, append(args, after, page.Limit+1)...)
+	if err != nil {
+		return model.EmailPage{}, dbError(ctx, err)
+	}
+	defer rows.Close()
+	var last string
+	for rows.Next() {
+		var id, email string
+		err = rows.Scan(&id, &email)
+		if err != nil {
+			return model.EmailPage{}, dbError(ctx, err)
+		}
+		if len(result.Emails) == page.Limit {
+			result.NextCursor = encodeCursor(last)
+			break
+		}
+		result.Emails = append(result.Emails, email)
+		last = id
+	}
+	if err := rows.Err(); err != nil {
+		return model.EmailPage{}, dbError(ctx, err)
+	}
+	return result, nil
+}
+
+// AddRelation inserts a two-way relation, it is only used for FRIEND.
+func (repo *RelationRepoImp) AddRelation(ctx context.Context, ids []string, status model.RelationStatus) (bool, error) {
 	sql_query := `insert into friend_relationship (your_id, friend_id, status)
 	values ($1, $2, $3), ($2, $1, $3)`
 
-	result, err := repo.Db.Exec(sql_query, ids[0], ids[1], status)
-	rs := (result == nil)
+	if _, err := repo.conn().ExecContext(ctx, sql_query, ids[0], ids[1], status); err != nil {
+		return false, dbError(ctx, err)
+	}
+	if err := repo.audit(ctx, ids, model.AuditAdd, "", status); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// AddDirectedRelation inserts a one-way relation from ids[0] to ids[1].
+func (repo *RelationRepoImp) AddDirectedRelation(ctx context.Context, ids []string, status model.RelationStatus) (bool, error) {
+	sql_query := `insert into friend_relationship (your_id, friend_id, status)
+	values ($1, $2, $3)`
+
+	if _, err := repo.conn().ExecContext(ctx, sql_query, ids[0], ids[1], status); err != nil {
+		return false, dbError(ctx, err)
+	}
+	if err := repo.audit(ctx, ids, model.AuditAdd, "", status); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RemoveRelation deletes a two-way relation, it is only used for FRIEND.
+func (repo *RelationRepoImp) RemoveRelation(ctx context.Context, ids []string, status model.RelationStatus) (bool, error) {
+	sql_query := `delete from friend_relationship
+	where ((your_id = $1 and friend_id = $2) or (your_id = $2 and friend_id = $1)) and status = $3`
+
+	result, err := repo.conn().ExecContext(ctx, sql_query, ids[0], ids[1], status)
+	if err != nil {
+		return false, dbError(ctx, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, dbError(ctx, err)
+	}
+	if affected == 0 {
+		return false, nil
+	}
+	return true, repo.audit(ctx, ids, model.AuditRemove, status, "")
+}
+
+// RemoveDirectedRelation deletes the one-way relation from ids[0] to ids[1].
+func (repo *RelationRepoImp) RemoveDirectedRelation(ctx context.Context, ids []string, status model.RelationStatus) (bool, error) {
+	sql_query := `delete from friend_relationship
+	where your_id = $1 and friend_id = $2 and status = $3`
+
+	result, err := repo.conn().ExecContext(ctx, sql_query, ids[0], ids[1], status)
+	if err != nil {
+		return false, dbError(ctx, err)
+	}
+	affected, err := result.RowsAffected()
 	if err != nil {
-		return rs, err
+		return false, dbError(ctx, err)
+	}
+	if affected == 0 {
+		return false, nil
+	}
+	return true, repo.audit(ctx, ids, model.AuditRemove, status, "")
+}
+
+// audit appends the record of the change of the relation from ids[0] to
+// ids[1].
+func (repo *RelationRepoImp) audit(ctx context.Context, ids []string, action model.AuditAction, oldStatus model.RelationStatus, newStatus model.RelationStatus) error {
+	sql_query := `insert into relation_audit (actor_id, target_id, action, old_status, new_status, request_id)
+	values ($1, $2, $3, $4, $5, $6)`
+
+	_, err := repo.conn().ExecContext(ctx, sql_query, ids[0], ids[1], action, oldStatus, newStatus, middleware.GetReqID(ctx))
+	if err != nil {
+		return dbError(ctx, err)
+	}
+	return nil
+}
+
+func (repo *RelationRepoImp) GetAuditRecords(ctx context.Context, id string, from time.Time, to time.Time, page model.Page) (model.AuditPage, error) {
+	after, err := decodeCursor(page.Cursor)
+	if err != nil {
+		return model.AuditPage{}, err
+	}
+	sql_query := `select a.audit_id, actor.email, target.email, a.action, a.old_status, a.new_status, a.request_id, a.created_at
+	from relation_audit a
+	join email actor on actor.email_id = a.actor_id
+	join email target on target.email_id = a.target_id
+	where (a.actor_id = $1 or a.target_id = $1)
+	and ($2::timestamptz is null or a.created_at >= $2)
+	and ($3::timestamptz is null or a.created_at < $3)
+	and a.audit_id > $4
+	order by a.audit_id
+	limit $5`
+
+	rows, err := repo.conn().QueryContext(ctx, sql_query, id, nullTime(from), nullTime(to), after, page.Limit+1)
+	if err != nil {
+		return model.AuditPage{}, dbError(ctx, err)
+	}
+	defer rows.Close()
+	result := model.AuditPage{Records: []model.AuditRecord{}}
+	for rows.Next() {
+		var record model.AuditRecord
+		err := rows.Scan(&record.Id, &record.Actor, &record.Target, &record.Action,
+			&record.OldStatus, &record.NewStatus, &record.RequestId, &record.CreatedAt)
+		if err != nil {
+			return model.AuditPage{}, dbError(ctx, err)
+		}
+		if len(result.Records) == page.Limit {
+			result.NextCursor = encodeCursor(result.Records[len(result.Records)-1].Id)
+			break
+		}
+		result.Records = append(result.Records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return model.AuditPage{}, dbError(ctx, err)
+	}
+	return result, nil
+}
+
+// nullTime returns t as a query argument, NULL for the zero time.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// dbError returns the context error when ctx is done, the driver reports a
+// cancelled or timed out query with its own error. A unique violation is
+// returned as ErrDuplicate.
+func dbError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrDuplicate
 	}
-	return rs, nil
+	return err
 }
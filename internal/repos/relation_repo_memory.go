@@ -0,0 +1,553 @@
+package repos
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"friend-management-v1/internal/utils"
+	"friend-management-v1/model"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+type memoryRelation struct {
+	yourId   string
+	friendId string
+	status   model.RelationStatus
+}
+
+type memoryStore struct {
+	mu        sync.RWMutex
+	emails    []string
+	ids       map[string]string
+	relations []memoryRelation
+	// audits holds every audit record, the id of a record is its index plus
+	// one.
+	audits []memoryAudit
+}
+
+type memoryAudit struct {
+	actorId   string
+	targetId  string
+	action    model.AuditAction
+	oldStatus model.RelationStatus
+	newStatus model.RelationStatus
+	requestId string
+	createdAt time.Time
+}
+
+// RelationRepoMemory keeps emails and relationships in memory so the server
+// can run without Postgres. It is safe for concurrent use. Like the SQL
+// repository, a call made with a done context returns the context error.
+type RelationRepoMemory struct {
+	*memoryStore
+	// tx is set on the repository passed to the function of Transaction,
+	// which holds the write lock for the whole transaction.
+	tx bool
+}
+
+func NewRelationRepoMemory() *RelationRepoMemory {
+	return &RelationRepoMemory{
+		memoryStore: &memoryStore{ids: make(map[string]string)},
+	}
+}
+
+func (repo *RelationRepoMemory) lock() func() {
+	if repo.tx {
+		return func() {}
+	}
+	repo.mu.Lock()
+	return repo.mu.Unlock
+}
+
+func (repo *RelationRepoMemory) rlock() func() {
+	if repo.tx {
+		return func() {}
+	}
+	repo.mu.RLock()
+	return repo.mu.RUnlock
+}
+
+// AddEmail stores an email and returns its id. Ids start at 1 and follow the
+// insertion order, the same as the identity column of the email table.
+func (repo *RelationRepoMemory) AddEmail(email string) string {
+	defer repo.lock()()
+	return repo.addEmail(email)
+}
+
+func (repo *RelationRepoMemory) addEmail(email string) string {
+	if id, ok := repo.ids[email]; ok {
+		return id
+	}
+	repo.emails = append(repo.emails, email)
+	id := strconv.Itoa(len(repo.emails))
+	repo.ids[email] = id
+	return id
+}
+
+func (repo *RelationRepoMemory) emailOf(id string) (string, bool) {
+	i, err := strconv.Atoi(id)
+	if err != nil || i < 1 || i > len(repo.emails) {
+		return "", false
+	}
+	return repo.emails[i-1], true
+}
+
+// CheckIfExist reports whether id1 has a relation with status to id2.
+func (repo *RelationRepoMemory) CheckIfExist(ctx context.Context, id1 string, id2 string, status model.RelationStatus) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	defer repo.rlock()()
+	return repo.exist(id1, id2, status), nil
+}
+
+func (repo *RelationRepoMemory) exist(id1 string, id2 string, status model.RelationStatus) bool {
+	for _, r := range repo.relations {
+		if r.status == status && r.yourId == id1 && r.friendId == id2 {
+			return true
+		}
+	}
+	return false
+}
+
+func (repo *RelationRepoMemory) GetIdFromEmail(ctx context.Context, email string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	defer repo.rlock()()
+
+	id, ok := repo.ids[email]
+	if !ok {
+		return "", &EmailNotFoundError{Email: email}
+	}
+	return id, nil
+}
+
+func (repo *RelationRepoMemory) CreateEmail(ctx context.Context, email string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	defer repo.lock()()
+
+	if _, ok := repo.ids[email]; ok {
+		return "", ErrDuplicate
+	}
+	return repo.addEmail(email), nil
+}
+
+func (repo *RelationRepoMemory) GetAllEmails(ctx context.Context) ([]model.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	defer repo.rlock()()
+
+	users := make([]model.User, len(repo.emails))
+	for i, email := range repo.emails {
+		users[i] = model.User{Id: strconv.Itoa(i + 1), Email: email}
+	}
+	return users, nil
+}
+
+func (repo *RelationRepoMemory) GetUnregisteredEmails(ctx context.Context, emails []string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	defer repo.rlock()()
+
+	unregistered := []string{}
+	for _, email := range emails {
+		if _, ok := repo.ids[email]; !ok {
+			unregistered = append(unregistered, email)
+		}
+	}
+	return unregistered, nil
+}
+
+// GetEmailByStatus returns the emails id has a relation with status to. Unless
+// the status is BLOCK, an email is left out when either side has blocked the
+// other.
+func (repo *RelationRepoMemory) GetEmailByStatus(ctx context.Context, id string, status model.RelationStatus, page model.Page) (model.EmailPage, error) {
+	if err := ctx.Err(); err != nil {
+		return model.EmailPage{}, err
+	}
+	defer repo.rlock()()
+
+	return repo.pageOf(repo.related(id, status), page)
+}
+
+// GetCommonEmails intersects the friend lists of ids.
+func (repo *RelationRepoMemory) GetCommonEmails(ctx context.Context, ids []string, page model.Page) (model.EmailPage, error) {
+	if err := ctx.Err(); err != nil {
+		return model.EmailPage{}, err
+	}
+	defer repo.rlock()()
+
+	var common []string
+	for i, id := range ids {
+		friends := repo.related(id, model.StatusFriend)
+		if i == 0 {
+			common = friends
+			continue
+		}
+		common = utils.RetainSlices(common, friends)
+	}
+	return repo.pageOf(common, page)
+}
+
+func (repo *RelationRepoMemory) related(id string, status model.RelationStatus) []string {
+	var ids []string
+	seen := make(map[string]bool)
+	for _, r := range repo.relations {
+		if r.status != status || r.yourId != id {
+			continue
+		}
+		other := r.friendId
+		if seen[other] {
+			continue
+		}
+		if status != model.StatusBlock && (repo.exist(id, other, model.StatusBlock) || repo.exist(other, id, model.StatusBlock)) {
+			continue
+		}
+		seen[other] = true
+		ids = append(ids, other)
+	}
+	return ids
+}
+
+// GetRetrivableEmails returns the friends and subscribers of id, together with
+// the registered emails of mentions, that have not blocked it.
+func (repo *RelationRepoMemory) GetRetrivableEmails(ctx context.Context, id string, mentions []string, page model.Page) (model.EmailPage, error) {
+	if err := ctx.Err(); err != nil {
+		return model.EmailPage{}, err
+	}
+	defer repo.rlock()()
+
+	var ids []string
+	seen := make(map[string]bool)
+	for _, r := range repo.relations {
+		if r.friendId != id || (r.status != model.StatusFriend && r.status != model.StatusSubcribe) {
+			continue
+		}
+		if seen[r.yourId] || repo.exist(r.yourId, id, model.StatusBlock) {
+			continue
+		}
+		seen[r.yourId] = true
+		ids = append(ids, r.yourId)
+	}
+	for _, email := range mentions {
+		if mentioned, ok := repo.ids[email]; ok && !seen[mentioned] && !repo.exist(mentioned, id, model.StatusBlock) {
+			seen[mentioned] = true
+			ids = append(ids, mentioned)
+		}
+	}
+	return repo.pageOf(ids, page)
+}
+
+// GetSuggestions ranks the friends of the friends of id by the number of
+// friends they have in common with id.
+func (repo *RelationRepoMemory) GetSuggestions(ctx context.Context, id string, limit int) ([]model.Suggestion, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	defer repo.rlock()()
+
+	friends := repo.related(id, model.StatusFriend)
+	sort.Slice(friends, func(i, j int) bool { return idLess(friends[i], friends[j]) })
+	mutual := make(map[string][]string)
+	var candidates []string
+	for _, friend := range friends {
+		for _, other := range repo.related(friend, model.StatusFriend) {
+			if other == id || repo.exist(id, other, model.StatusFriend) ||
+				repo.exist(id, other, model.StatusBlock) || repo.exist(other, id, model.StatusBlock) {
+				continue
+			}
+			if _, ok := mutual[other]; !ok {
+				candidates = append(candidates, other)
+			}
+			email, _ := repo.emailOf(friend)
+			mutual[other] = append(mutual[other], email)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		ci, cj := len(mutual[candidates[i]]), len(mutual[candidates[j]])
+		if ci != cj {
+			return ci > cj
+		}
+		return idLess(candidates[i], candidates[j])
+	})
+
+	suggestions := []model.Suggestion{}
+	for _, candidate := range candidates {
+		if len(suggestions) == limit {
+			break
+		}
+		email, _ := repo.emailOf(candidate)
+		suggestions = append(suggestions, model.Suggestion{
+			Email:         email,
+			MutualCount:   len(mutual[candidate]),
+			MutualFriends: mutual[candidate],
+		})
+	}
+	return suggestions, nil
+}
+
+// GetFriendsOf returns the friends of each of ids, in id order.
+func (repo *RelationRepoMemory) GetFriendsOf(ctx context.Context, ids []string) (map[string][]model.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	defer repo.rlock()()
+
+	friends := make(map[string][]model.User)
+	for _, id := range ids {
+		related := repo.related(id, model.StatusFriend)
+		sort.Slice(related, func(i, j int) bool { return idLess(related[i], related[j]) })
+		for _, friend := range related {
+			email, _ := repo.emailOf(friend)
+			friends[id] = append(friends[id], model.User{Id: friend, Email: email})
+		}
+	}
+	return friends, nil
+}
+
+func idLess(id1 string, id2 string) bool {
+	i1, _ := strconv.Atoi(id1)
+	i2, _ := strconv.Atoi(id2)
+	return i1 < i2
+}
+
+// AddRelation inserts a two-way relation, it is only used for FRIEND.
+func (repo *RelationRepoMemory) AddRelation(ctx context.Context, ids []string, status model.RelationStatus) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	defer repo.lock()()
+
+	if err := repo.checkIds(ids); err != nil {
+		return false, err
+	}
+	if repo.exist(ids[0], ids[1], status) || repo.exist(ids[1], ids[0], status) {
+		return false, ErrDuplicate
+	}
+	repo.relations = append(repo.relations,
+		memoryRelation{yourId: ids[0], friendId: ids[1], status: status},
+		memoryRelation{yourId: ids[1], friendId: ids[0], status: status})
+	repo.audit(ctx, ids, model.AuditAdd, "", status)
+	return true, nil
+}
+
+// AddDirectedRelation inserts a one-way relation from ids[0] to ids[1].
+func (repo *RelationRepoMemory) AddDirectedRelation(ctx context.Context, ids []string, status model.RelationStatus) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	defer repo.lock()()
+
+	if err := repo.checkIds(ids); err != nil {
+		return false, err
+	}
+	if repo.exist(ids[0], ids[1], status) {
+		return false, ErrDuplicate
+	}
+	repo.relations = append(repo.relations, memoryRelation{yourId: ids[0], friendId: ids[1], status: status})
+	repo.audit(ctx, ids, model.AuditAdd, "", status)
+	return true, nil
+}
+
+func (repo *RelationRepoMemory) checkIds(ids []string) error {
+	for _, id := range ids[:2] {
+		if _, ok := repo.emailOf(id); !ok {
+			return errors.New("email id: " + id + " is not exist in database")
+		}
+	}
+	return nil
+}
+
+// RemoveRelation deletes a two-way relation, it is only used for FRIEND.
+func (repo *RelationRepoMemory) RemoveRelation(ctx context.Context, ids []string, status model.RelationStatus) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	defer repo.lock()()
+
+	removed := repo.remove(ids[0], ids[1], status)
+	removed = repo.remove(ids[1], ids[0], status) || removed
+	if removed {
+		repo.audit(ctx, ids, model.AuditRemove, status, "")
+	}
+	return removed, nil
+}
+
+// RemoveDirectedRelation deletes the one-way relation from ids[0] to ids[1].
+func (repo *RelationRepoMemory) RemoveDirectedRelation(ctx context.Context, ids []string, status model.RelationStatus) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	defer repo.lock()()
+
+	removed := repo.remove(ids[0], ids[1], status)
+	if removed {
+		repo.audit(ctx, ids, model.AuditRemove, status, "")
+	}
+	return removed, nil
+}
+
+// audit appends the record of the change of the relation from ids[0] to
+// ids[1], the write lock must be held.
+func (repo *RelationRepoMemory) audit(ctx context.Context, ids []string, action model.AuditAction, oldStatus model.RelationStatus, newStatus model.RelationStatus) {
+	repo.audits = append(repo.audits, memoryAudit{
+		actorId:   ids[0],
+		targetId:  ids[1],
+		action:    action,
+		oldStatus: oldStatus,
+		newStatus: newStatus,
+		requestId: middleware.GetReqID(ctx),
+		createdAt: time.Now(),
+	})
+}
+
+func (repo *RelationRepoMemory) GetAuditRecords(ctx context.Context, id string, from time.Time, to time.Time, page model.Page) (model.AuditPage, error) {
+	if err := ctx.Err(); err != nil {
+		return model.AuditPage{}, err
+	}
+	after, err := decodeCursor(page.Cursor)
+	if err != nil {
+		return model.AuditPage{}, err
+	}
+	defer repo.rlock()()
+
+	result := model.AuditPage{Records: []model.AuditRecord{}}
+	for i := int(after); i < len(repo.audits); i++ {
+		a := repo.audits[i]
+		if a.actorId != id && a.targetId != id {
+			continue
+		}
+		if (!from.IsZero() && a.createdAt.Before(from)) || (!to.IsZero() && !a.createdAt.Before(to)) {
+			continue
+		}
+		if len(result.Records) == page.Limit {
+			result.NextCursor = encodeCursor(result.Records[len(result.Records)-1].Id)
+			break
+		}
+		actor, _ := repo.emailOf(a.actorId)
+		target, _ := repo.emailOf(a.targetId)
+		result.Records = append(result.Records, model.AuditRecord{
+			Id:        strconv.Itoa(i + 1),
+			Actor:     actor,
+			Target:    target,
+			Action:    a.action,
+			OldStatus: a.oldStatus,
+			NewStatus: a.newStatus,
+			RequestId: a.requestId,
+			CreatedAt: a.createdAt,
+		})
+	}
+	return result, nil
+}
+
+func (repo *RelationRepoMemory) remove(id1 string, id2 string, status model.RelationStatus) bool {
+	kept := repo.relations[:0]
+	for _, r := range repo.relations {
+		if r.status == status && r.yourId == id1 && r.friendId == id2 {
+			continue
+		}
+		kept = append(kept, r)
+	}
+	removed := len(kept) < len(repo.relations)
+	repo.relations = kept
+	return removed
+}
+
+// Transaction runs fn while holding the write lock, so it is serialized with
+// every other call. The emails and relations are restored when fn fails.
+func (repo *RelationRepoMemory) Transaction(ctx context.Context, fn func(tx RelationRepo) error) error {
+	if repo.tx {
+		return fn(repo)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	emails := len(repo.emails)
+	audits := len(repo.audits)
+	relations := append([]memoryRelation(nil), repo.relations...)
+	if err := fn(&RelationRepoMemory{memoryStore: repo.memoryStore, tx: true}); err != nil {
+		for _, email := range repo.emails[emails:] {
+			delete(repo.ids, email)
+		}
+		repo.emails = repo.emails[:emails]
+		repo.audits = repo.audits[:audits]
+		repo.relations = relations
+		return err
+	}
+	return nil
+}
+
+// LockEmails only checks ctx, a transaction already holds the whole store.
+func (repo *RelationRepoMemory) LockEmails(ctx context.Context, ids []string) error {
+	return ctx.Err()
+}
+
+var (
+	seedEmailInsert    = regexp.MustCompile(`(?is)^insert\s+into\s+email\s*\(\s*email\s*\)\s*values\s*(.*)$`)
+	seedRelationInsert = regexp.MustCompile(`(?is)^insert\s+into\s+friend_relationship\s*\(\s*your_id\s*,\s*friend_id\s*,\s*status\s*\)\s*values\s*(.*)$`)
+	seedEmailValue     = regexp.MustCompile(`\(\s*'([^']*)'\s*\)`)
+	seedRelationValue  = regexp.MustCompile(`\(\s*(\d+)\s*,\s*(\d+)\s*,\s*'([^']*)'\s*\)`)
+)
+
+// Seed loads the rows inserted by a SQL script such as init.sql. Only the
+// inserts into email and friend_relationship are read, every other statement
+// is skipped. Like the status check constraint, an unknown status is an error.
+func (repo *RelationRepoMemory) Seed(r io.Reader) error {
+	defer repo.lock()()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Split(scanStatements)
+	for scanner.Scan() {
+		stmt := strings.TrimSpace(scanner.Text())
+		if m := seedEmailInsert.FindStringSubmatch(stmt); m != nil {
+			for _, v := range seedEmailValue.FindAllStringSubmatch(m[1], -1) {
+				repo.addEmail(v[1])
+			}
+			continue
+		}
+		if m := seedRelationInsert.FindStringSubmatch(stmt); m != nil {
+			for _, v := range seedRelationValue.FindAllStringSubmatch(m[1], -1) {
+				if _, ok := repo.emailOf(v[1]); !ok {
+					return errors.New("seed: email id " + v[1] + " is not exist")
+				}
+				if _, ok := repo.emailOf(v[2]); !ok {
+					return errors.New("seed: email id " + v[2] + " is not exist")
+				}
+				status := model.RelationStatus(v[3])
+				if !status.IsValid() {
+					return errors.New("seed: status " + v[3] + " is not valid")
+				}
+				repo.relations = append(repo.relations, memoryRelation{yourId: v[1], friendId: v[2], status: status})
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// scanStatements is a bufio.SplitFunc returning one SQL statement at a time.
+func scanStatements(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := bytes.IndexByte(data, ';'); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
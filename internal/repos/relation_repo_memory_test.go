@@ -0,0 +1,461 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"friend-management-v1/model"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemorySeedFromInitSQL(t *testing.T) {
+	f, err := os.Open("../../init.sql")
+	assert.Nil(t, err)
+	defer f.Close()
+
+	repo := NewRelationRepoMemory()
+	assert.Nil(t, repo.Seed(f))
+
+	id, err := repo.GetIdFromEmail(context.Background(), "quan12yt@gmail.com")
+	assert.Nil(t, err)
+	assert.Equal(t, "1", id)
+
+	friends := friendsOf(t, repo, id, model.StatusFriend)
+	// tonhut@gmail.com is a friend too, but quan12yt@gmail.com has blocked it.
+	assert.Equal(t, []string{"quang@gmail.com"}, friends)
+	assert.Equal(t, true, exists(t, repo, "1", "3", model.StatusBlock))
+	assert.Equal(t, false, exists(t, repo, "3", "1", model.StatusBlock))
+}
+
+func TestMemorySeedUnknownId(t *testing.T) {
+	repo := NewRelationRepoMemory()
+	err := repo.Seed(strings.NewReader(`insert into friend_relationship (your_id, friend_id, status) values (1, 2, 'FRIEND');`))
+
+	assert.NotNil(t, err)
+}
+
+func TestMemorySeedInvalidStatus(t *testing.T) {
+	repo := NewRelationRepoMemory()
+	err := repo.Seed(strings.NewReader(`insert into email(email) values ('quan12yt@gmail.com'), ('quang@gmail.com');
+	insert into friend_relationship (your_id, friend_id, status) values (1, 2, 'SUBSCRIBE');`))
+
+	assert.NotNil(t, err)
+	assert.Equal(t, "seed: status SUBSCRIBE is not valid", err.Error())
+}
+
+func TestMemoryGetIdFromEmailNotExist(t *testing.T) {
+	repo := NewRelationRepoMemory()
+
+	_, err := repo.GetIdFromEmail(context.Background(), "quan12yt@gmail.com")
+
+	assert.NotNil(t, err)
+	assert.Equal(t, "email: quan12yt@gmail.com is not exist in database", err.Error())
+}
+
+func TestMemoryCanceledContext(t *testing.T) {
+	repo := NewRelationRepoMemory()
+	id1 := repo.AddEmail("quan12yt@gmail.com")
+	id2 := repo.AddEmail("quang@gmail.com")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := repo.AddRelation(ctx, []string{id1, id2}, model.StatusFriend)
+
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, false, exists(t, repo, id1, id2, model.StatusFriend))
+}
+
+func TestMemoryCreateEmail(t *testing.T) {
+	repo := NewRelationRepoMemory()
+	repo.AddEmail("quan12yt@gmail.com")
+
+	id, err := repo.CreateEmail(context.Background(), "quang@gmail.com")
+	assert.Nil(t, err)
+	assert.Equal(t, "2", id)
+	_, err = repo.CreateEmail(context.Background(), "quang@gmail.com")
+	assert.Equal(t, ErrDuplicate, err)
+
+	users, err := repo.GetAllEmails(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, []model.User{
+		{Id: "1", Email: "quan12yt@gmail.com"},
+		{Id: "2", Email: "quang@gmail.com"},
+	}, users)
+}
+
+func TestMemoryAddRelation(t *testing.T) {
+	repo := NewRelationRepoMemory()
+	id1 := repo.AddEmail("quan12yt@gmail.com")
+	id2 := repo.AddEmail("quang@gmail.com")
+
+	assert.Equal(t, false, exists(t, repo, id1, id2, model.StatusFriend))
+
+	ok, err := repo.AddRelation(context.Background(), []string{id1, id2}, model.StatusFriend)
+
+	assert.Nil(t, err)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, true, exists(t, repo, id2, id1, model.StatusFriend))
+	friends := friendsOf(t, repo, id2, model.StatusFriend)
+	assert.Equal(t, []string{"quan12yt@gmail.com"}, friends)
+	recipients := recipientsOf(t, repo, id1)
+	assert.Equal(t, []string{"quang@gmail.com"}, recipients)
+
+	_, err = repo.AddRelation(context.Background(), []string{id1, "99"}, model.StatusFriend)
+	assert.NotNil(t, err)
+}
+
+func TestMemoryRemoveRelation(t *testing.T) {
+	repo := NewRelationRepoMemory()
+	id1 := repo.AddEmail("quan12yt@gmail.com")
+	id2 := repo.AddEmail("quang@gmail.com")
+	repo.AddRelation(context.Background(), []string{id1, id2}, model.StatusFriend)
+	repo.AddDirectedRelation(context.Background(), []string{id1, id2}, model.StatusBlock)
+
+	removed, err := repo.RemoveRelation(context.Background(), []string{id2, id1}, model.StatusFriend)
+
+	assert.Nil(t, err)
+	assert.Equal(t, true, removed)
+	assert.Equal(t, false, exists(t, repo, id1, id2, model.StatusFriend))
+	assert.Equal(t, true, exists(t, repo, id1, id2, model.StatusBlock))
+
+	removed, err = repo.RemoveRelation(context.Background(), []string{id1, id2}, model.StatusFriend)
+	assert.Nil(t, err)
+	assert.Equal(t, false, removed)
+}
+
+func TestMemoryBlockHidesRelations(t *testing.T) {
+	repo := NewRelationRepoMemory()
+	id1 := repo.AddEmail("quan12yt@gmail.com")
+	id2 := repo.AddEmail("quang@gmail.com")
+	id3 := repo.AddEmail("hau@gmail.com")
+	repo.AddRelation(context.Background(), []string{id1, id2}, model.StatusFriend)
+	repo.AddRelation(context.Background(), []string{id1, id3}, model.StatusFriend)
+	repo.AddDirectedRelation(context.Background(), []string{id1, id3}, model.StatusBlock)
+
+	friends := friendsOf(t, repo, id1, model.StatusFriend)
+	assert.Equal(t, []string{"quang@gmail.com"}, friends)
+	friends = friendsOf(t, repo, id3, model.StatusFriend)
+	assert.Empty(t, friends)
+	blocked := friendsOf(t, repo, id1, model.StatusBlock)
+	assert.Equal(t, []string{"hau@gmail.com"}, blocked)
+	blocked = friendsOf(t, repo, id3, model.StatusBlock)
+	assert.Empty(t, blocked)
+	recipients := recipientsOf(t, repo, id3)
+	assert.Empty(t, recipients)
+	recipients = recipientsOf(t, repo, id1)
+	assert.Equal(t, []string{"quang@gmail.com", "hau@gmail.com"}, recipients)
+}
+
+func TestMemoryDirectedRelation(t *testing.T) {
+	repo := NewRelationRepoMemory()
+	id1 := repo.AddEmail("quan12yt@gmail.com")
+	id2 := repo.AddEmail("quang@gmail.com")
+
+	_, err := repo.AddDirectedRelation(context.Background(), []string{id1, id2}, model.StatusSubcribe)
+
+	assert.Nil(t, err)
+	assert.Equal(t, true, exists(t, repo, id1, id2, model.StatusSubcribe))
+	assert.Equal(t, false, exists(t, repo, id2, id1, model.StatusSubcribe))
+	recipients := recipientsOf(t, repo, id2)
+	assert.Equal(t, []string{"quan12yt@gmail.com"}, recipients)
+	recipients = recipientsOf(t, repo, id1)
+	assert.Empty(t, recipients)
+
+	removed, _ := repo.RemoveDirectedRelation(context.Background(), []string{id2, id1}, model.StatusSubcribe)
+	assert.Equal(t, false, removed)
+	removed, _ = repo.RemoveDirectedRelation(context.Background(), []string{id1, id2}, model.StatusSubcribe)
+	assert.Equal(t, true, removed)
+	assert.Equal(t, false, exists(t, repo, id1, id2, model.StatusSubcribe))
+}
+
+func TestMemoryPagination(t *testing.T) {
+	repo := NewRelationRepoMemory()
+	hub := repo.AddEmail("hub@gmail.com")
+	var ids []string
+	for i := 0; i < 5; i++ {
+		ids = append(ids, repo.AddEmail(fmt.Sprintf("user%d@gmail.com", i)))
+	}
+	// added out of id order, pages are still ordered by id
+	for i := len(ids) - 1; i >= 0; i-- {
+		repo.AddRelation(context.Background(), []string{hub, ids[i]}, model.StatusFriend)
+	}
+
+	var emails []string
+	page := model.Page{Limit: 2}
+	for n := 0; n < 3; n++ {
+		result, err := repo.GetEmailByStatus(context.Background(), hub, model.StatusFriend, page)
+		assert.Nil(t, err)
+		assert.Equal(t, 5, result.Total)
+		emails = append(emails, result.Emails...)
+		assert.Equal(t, n == 2, result.NextCursor == "")
+		page.Cursor = result.NextCursor
+	}
+	assert.Equal(t, []string{"user0@gmail.com", "user1@gmail.com", "user2@gmail.com", "user3@gmail.com", "user4@gmail.com"}, emails)
+
+	_, err := repo.GetEmailByStatus(context.Background(), hub, model.StatusFriend, model.Page{Limit: 2, Cursor: "?"})
+	assert.Equal(t, ErrInvalidCursor, err)
+}
+
+func TestMemoryCommonEmails(t *testing.T) {
+	repo := NewRelationRepoMemory()
+	id1 := repo.AddEmail("quan12yt@gmail.com")
+	id2 := repo.AddEmail("quang@gmail.com")
+	id3 := repo.AddEmail("hau@gmail.com")
+	id4 := repo.AddEmail("len@gmail.com")
+	for _, id := range []string{id3, id4} {
+		repo.AddRelation(context.Background(), []string{id1, id}, model.StatusFriend)
+		repo.AddRelation(context.Background(), []string{id2, id}, model.StatusFriend)
+	}
+	repo.AddDirectedRelation(context.Background(), []string{id4, id2}, model.StatusBlock)
+
+	page, err := repo.GetCommonEmails(context.Background(), []string{id1, id2}, model.Page{Limit: 10})
+
+	assert.Nil(t, err)
+	assert.Equal(t, model.EmailPage{Emails: []string{"hau@gmail.com"}, Total: 1}, page)
+
+	id5 := repo.AddEmail("toan@gmail.com")
+	repo.AddRelation(context.Background(), []string{id5, id3}, model.StatusFriend)
+	repo.AddRelation(context.Background(), []string{id5, id1}, model.StatusFriend)
+
+	page, err = repo.GetCommonEmails(context.Background(), []string{id1, id2, id5}, model.Page{Limit: 10})
+
+	assert.Nil(t, err)
+	assert.Equal(t, model.EmailPage{Emails: []string{"hau@gmail.com"}, Total: 1}, page)
+}
+
+func TestMemoryRetrivableMentions(t *testing.T) {
+	repo := NewRelationRepoMemory()
+	id1 := repo.AddEmail("quan12yt@gmail.com")
+	id2 := repo.AddEmail("quang@gmail.com")
+	repo.AddEmail("hau@gmail.com")
+	id4 := repo.AddEmail("len@gmail.com")
+	repo.AddDirectedRelation(context.Background(), []string{id2, id1}, model.StatusSubcribe)
+	repo.AddDirectedRelation(context.Background(), []string{id4, id1}, model.StatusBlock)
+	mentions := []string{"hau@gmail.com", "quang@gmail.com", "unknown@gmail.com", "len@gmail.com"}
+
+	page, err := repo.GetRetrivableEmails(context.Background(), id1, mentions, model.Page{Limit: 10})
+
+	assert.Nil(t, err)
+	assert.Equal(t, model.EmailPage{Emails: []string{"quang@gmail.com", "hau@gmail.com"}, Total: 2}, page)
+
+	unregistered, err := repo.GetUnregisteredEmails(context.Background(), mentions)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"unknown@gmail.com"}, unregistered)
+}
+
+func TestMemorySuggestions(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRelationRepoMemory()
+	me := repo.AddEmail("quan12yt@gmail.com")
+	f1 := repo.AddEmail("quang@gmail.com")
+	f2 := repo.AddEmail("hau@gmail.com")
+	s1 := repo.AddEmail("len@gmail.com")
+	s2 := repo.AddEmail("toan@gmail.com")
+	blocked := repo.AddEmail("nhut@gmail.com")
+	for _, id := range []string{f1, f2} {
+		repo.AddRelation(ctx, []string{me, id}, model.StatusFriend)
+		repo.AddRelation(ctx, []string{id, s2}, model.StatusFriend)
+		repo.AddRelation(ctx, []string{id, blocked}, model.StatusFriend)
+	}
+	repo.AddRelation(ctx, []string{f1, f2}, model.StatusFriend)
+	repo.AddRelation(ctx, []string{f1, s1}, model.StatusFriend)
+	repo.AddDirectedRelation(ctx, []string{blocked, me}, model.StatusBlock)
+
+	suggestions, err := repo.GetSuggestions(ctx, me, 10)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []model.Suggestion{
+		{Email: "toan@gmail.com", MutualCount: 2, MutualFriends: []string{"quang@gmail.com", "hau@gmail.com"}},
+		{Email: "len@gmail.com", MutualCount: 1, MutualFriends: []string{"quang@gmail.com"}},
+	}, suggestions)
+
+	suggestions, err = repo.GetSuggestions(ctx, me, 1)
+	assert.Nil(t, err)
+	assert.Len(t, suggestions, 1)
+
+	suggestions, err = repo.GetSuggestions(ctx, s1, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"quan12yt@gmail.com", "hau@gmail.com", "toan@gmail.com", "nhut@gmail.com"}, emailsOf(suggestions))
+}
+
+func emailsOf(suggestions []model.Suggestion) []string {
+	emails := []string{}
+	for _, s := range suggestions {
+		emails = append(emails, s.Email)
+	}
+	return emails
+}
+
+func TestMemoryFriendsOf(t *testing.T) {
+	ctx := context.Background()
+	repo := NewRelationRepoMemory()
+	id1 := repo.AddEmail("quan12yt@gmail.com")
+	id2 := repo.AddEmail("quang@gmail.com")
+	id3 := repo.AddEmail("hau@gmail.com")
+	repo.AddRelation(ctx, []string{id1, id3}, model.StatusFriend)
+	repo.AddRelation(ctx, []string{id1, id2}, model.StatusFriend)
+	repo.AddRelation(ctx, []string{id2, id3}, model.StatusFriend)
+	repo.AddDirectedRelation(ctx, []string{id3, id2}, model.StatusBlock)
+
+	friends, err := repo.GetFriendsOf(ctx, []string{id1, id2})
+
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]model.User{
+		id1: {{Id: id2, Email: "quang@gmail.com"}, {Id: id3, Email: "hau@gmail.com"}},
+		id2: {{Id: id1, Email: "quan12yt@gmail.com"}},
+	}, friends)
+}
+
+func TestMemoryConcurrentUse(t *testing.T) {
+	repo := NewRelationRepoMemory()
+	hub := repo.AddEmail("hub@gmail.com")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := repo.AddEmail(fmt.Sprintf("user%d@gmail.com", i))
+			repo.AddRelation(context.Background(), []string{hub, id}, model.StatusFriend)
+			repo.GetEmailByStatus(context.Background(), hub, model.StatusFriend, model.Page{Limit: 10})
+		}(i)
+	}
+	wg.Wait()
+
+	friends := friendsOf(t, repo, hub, model.StatusFriend)
+	assert.Equal(t, 50, len(friends))
+}
+
+func TestMemoryAddDuplicate(t *testing.T) {
+	repo := NewRelationRepoMemory()
+	id1 := repo.AddEmail("quan12yt@gmail.com")
+	id2 := repo.AddEmail("quang@gmail.com")
+
+	_, err := repo.AddRelation(context.Background(), []string{id1, id2}, model.StatusFriend)
+	assert.Nil(t, err)
+	_, err = repo.AddRelation(context.Background(), []string{id2, id1}, model.StatusFriend)
+	assert.Equal(t, ErrDuplicate, err)
+	_, err = repo.AddDirectedRelation(context.Background(), []string{id1, id2}, model.StatusBlock)
+	assert.Nil(t, err)
+	_, err = repo.AddDirectedRelation(context.Background(), []string{id1, id2}, model.StatusBlock)
+	assert.Equal(t, ErrDuplicate, err)
+}
+
+func TestMemoryTransactionRollback(t *testing.T) {
+	repo := NewRelationRepoMemory()
+	id1 := repo.AddEmail("quan12yt@gmail.com")
+	id2 := repo.AddEmail("quang@gmail.com")
+
+	err := repo.Transaction(context.Background(), func(tx RelationRepo) error {
+		if _, err := tx.AddRelation(context.Background(), []string{id1, id2}, model.StatusFriend); err != nil {
+			return err
+		}
+		return errors.New("rollback")
+	})
+
+	assert.Equal(t, "rollback", err.Error())
+	assert.Equal(t, false, exists(t, repo, id1, id2, model.StatusFriend))
+	assert.Equal(t, false, exists(t, repo, id2, id1, model.StatusFriend))
+}
+
+func TestMemoryTransactionSerializesWrites(t *testing.T) {
+	repo := NewRelationRepoMemory()
+	id1 := repo.AddEmail("quan12yt@gmail.com")
+	id2 := repo.AddEmail("quang@gmail.com")
+	ids := []string{id1, id2}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- repo.Transaction(context.Background(), func(tx RelationRepo) error {
+				exist, err := tx.CheckIfExist(context.Background(), id1, id2, model.StatusFriend)
+				if err != nil || exist {
+					return err
+				}
+				_, err = tx.AddRelation(context.Background(), ids, model.StatusFriend)
+				return err
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, 2, len(repo.relations))
+}
+
+func exists(t *testing.T, repo *RelationRepoMemory, id1 string, id2 string, status model.RelationStatus) bool {
+	exist, err := repo.CheckIfExist(context.Background(), id1, id2, status)
+	assert.Nil(t, err)
+	return exist
+}
+
+func friendsOf(t *testing.T, repo *RelationRepoMemory, id string, status model.RelationStatus) []string {
+	page, err := repo.GetEmailByStatus(context.Background(), id, status, model.Page{Limit: 1000})
+	assert.Nil(t, err)
+	return page.Emails
+}
+
+func recipientsOf(t *testing.T, repo *RelationRepoMemory, id string) []string {
+	page, err := repo.GetRetrivableEmails(context.Background(), id, nil, model.Page{Limit: 1000})
+	assert.Nil(t, err)
+	return page.Emails
+}
+
+func TestMemoryAuditRecords(t *testing.T) {
+	repo := NewRelationRepoMemory()
+	id1 := repo.AddEmail("quan12yt@gmail.com")
+	id2 := repo.AddEmail("quang@gmail.com")
+	id3 := repo.AddEmail("hau@gmail.com")
+	ctx := context.WithValue(context.Background(), middleware.RequestIDKey, "host/abc-000001")
+	start := time.Now()
+
+	repo.AddRelation(ctx, []string{id1, id2}, model.StatusFriend)
+	repo.AddDirectedRelation(context.Background(), []string{id3, id1}, model.StatusBlock)
+	repo.RemoveRelation(context.Background(), []string{id3, id2}, model.StatusFriend)
+	repo.RemoveDirectedRelation(context.Background(), []string{id3, id1}, model.StatusBlock)
+	err := repo.Transaction(context.Background(), func(tx RelationRepo) error {
+		tx.AddDirectedRelation(context.Background(), []string{id1, id3}, model.StatusSubcribe)
+		return errors.New("rolled back")
+	})
+	assert.NotNil(t, err)
+
+	page, err := repo.GetAuditRecords(context.Background(), id1, start, time.Time{}, model.Page{Limit: 2})
+	assert.Nil(t, err)
+	assert.Len(t, page.Records, 2)
+	assert.Equal(t, model.AuditRecord{
+		Id:        "1",
+		Actor:     "quan12yt@gmail.com",
+		Target:    "quang@gmail.com",
+		Action:    model.AuditAdd,
+		NewStatus: model.StatusFriend,
+		RequestId: "host/abc-000001",
+		CreatedAt: page.Records[0].CreatedAt,
+	}, page.Records[0])
+	assert.Equal(t, "hau@gmail.com", page.Records[1].Actor)
+	assert.NotEmpty(t, page.NextCursor)
+
+	page, err = repo.GetAuditRecords(context.Background(), id1, start, time.Time{}, model.Page{Limit: 2, Cursor: page.NextCursor})
+	assert.Nil(t, err)
+	assert.Len(t, page.Records, 1)
+	assert.Equal(t, model.AuditRemove, page.Records[0].Action)
+	assert.Equal(t, model.StatusBlock, page.Records[0].OldStatus)
+	assert.Empty(t, page.NextCursor)
+
+	page, err = repo.GetAuditRecords(context.Background(), id1, time.Time{}, start, model.Page{Limit: 10})
+	assert.Nil(t, err)
+	assert.Empty(t, page.Records)
+}
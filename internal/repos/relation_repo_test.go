@@ -1,11 +1,17 @@
 package repos
 
 import (
+	"context"
+	"database/sql"
 	"errors"
+	"friend-management-v1/model"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -14,18 +20,18 @@ func TestCheckIfExistFalse(t *testing.T) {
 	repo := RelationRepoImp{Db: db}
 	ids := []string{"1", "2"}
 
-	status := "FRIEND"
+	status := model.StatusFriend
 
 	sql_query := `select fr.relation_id 
 	from friend_relationship fr 
-	where ((fr.your_id = $1 and fr.friend_id = $2) or (fr.your_id =$2 and fr.friend_id =$1)) and status = $3`
+	where fr.your_id = $1 and fr.friend_id = $2 and status = $3`
 
 	mock.ExpectQuery(regexp.QuoteMeta(sql_query)).
 		WillReturnRows(sqlmock.NewRows([]string{"relation_id"}))
 
-	resp := repo.CheckIfExist(ids[0], ids[1], status)
+	resp, err := repo.CheckIfExist(context.Background(), ids[0], ids[1], status)
 
-	assert.NotNil(t, resp)
+	assert.Nil(t, err)
 	assert.Equal(t, false, resp)
 }
 
@@ -34,58 +40,260 @@ func TestCheckIfExistTrue(t *testing.T) {
 	repo := RelationRepoImp{Db: db}
 	ids := []string{"1", "2"}
 
-	status := "FRIEND"
+	status := model.StatusFriend
 
 	sql_query := `select fr.relation_id 
 	from friend_relationship fr 
-	where ((fr.your_id = $1 and fr.friend_id = $2) or (fr.your_id =$2 and fr.friend_id =$1)) and status = $3`
+	where fr.your_id = $1 and fr.friend_id = $2 and status = $3`
 
 	mock.ExpectQuery(regexp.QuoteMeta(sql_query)).
 		WillReturnRows(sqlmock.NewRows([]string{"relation_id"}).AddRow("1"))
 
-	resp := repo.CheckIfExist(ids[0], ids[1], status)
+	resp, err := repo.CheckIfExist(context.Background(), ids[0], ids[1], status)
 
-	assert.NotNil(t, resp)
+	assert.Nil(t, err)
 	assert.Equal(t, true, resp)
 }
+
+func TestCheckIfExistFailed(t *testing.T) {
+	db, mock := DbMock()
+	repo := RelationRepoImp{Db: db}
+
+	mock.ExpectQuery("select fr.relation_id").
+		WillReturnError(errors.New("connection refused"))
+
+	resp, err := repo.CheckIfExist(context.Background(), "1", "2", model.StatusFriend)
+
+	assert.Equal(t, false, resp)
+	assert.NotNil(t, err)
+	assert.Equal(t, "connection refused", err.Error())
+}
+
+func TestTransactionCommit(t *testing.T) {
+	db, mock := DbMock()
+	repo := RelationRepoImp{Db: db}
+	ids := []string{"1", "2"}
+	lock_query := `select e.email_id from email e
+	where e.email_id in ($1, $2)
+	order by e.email_id for update`
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(lock_query)).WithArgs(ids[0], ids[1]).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("insert into friend_relationship").WithArgs(ids[0], ids[1], model.StatusFriend).WillReturnResult(sqlmock.NewResult(1, 2))
+	mock.ExpectExec("insert into relation_audit").WithArgs(ids[0], ids[1], model.AuditAdd, "", model.StatusFriend, "").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err := repo.Transaction(context.Background(), func(tx RelationRepo) error {
+		if err := tx.LockEmails(context.Background(), ids); err != nil {
+			return err
+		}
+		_, err := tx.AddRelation(context.Background(), ids, model.StatusFriend)
+		return err
+	})
+
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestTransactionRollback(t *testing.T) {
+	db, mock := DbMock()
+	repo := RelationRepoImp{Db: db}
+	ids := []string{"1", "2"}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("insert into friend_relationship").WithArgs(ids[0], ids[1], model.StatusFriend).
+		WillReturnError(&pq.Error{Code: "23505"})
+	mock.ExpectRollback()
+
+	err := repo.Transaction(context.Background(), func(tx RelationRepo) error {
+		_, err := tx.AddRelation(context.Background(), ids, model.StatusFriend)
+		return err
+	})
+
+	assert.Equal(t, ErrDuplicate, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
 func TestGetIdFromEmail(t *testing.T) {
 	db, mock := DbMock()
 	repo := RelationRepoImp{Db: db}
-	email := "quan12yt@gmail.com"
-	sql_query := "select e.email_id from email e where e.email = '" + email + "'"
+	sql_query := `select e.email_id from email e where e.email = $1`
 
-	mock.ExpectQuery(sql_query).
+	mock.ExpectQuery(regexp.QuoteMeta(sql_query)).WithArgs("quan12yt@gmail.com").
 		WillReturnRows(sqlmock.NewRows([]string{"email_id"}).
 			AddRow("2"))
 
-	resp, err := repo.GetIdFromEmail("quan12yt@gmail.com")
+	resp, err := repo.GetIdFromEmail(context.Background(), "quan12yt@gmail.com")
 
 	assert.Nil(t, err)
 	assert.NotNil(t, resp)
 	assert.Equal(t, "2", resp)
 }
 
+func TestGetIdFromEmailNotExist(t *testing.T) {
+	db, mock := DbMock()
+	repo := RelationRepoImp{Db: db}
+
+	mock.ExpectQuery("select e.email_id from email e").
+		WillReturnRows(sqlmock.NewRows([]string{"email_id"}))
+
+	_, err := repo.GetIdFromEmail(context.Background(), "quan12yt@gmail.com")
+
+	var notFound *EmailNotFoundError
+	assert.True(t, errors.As(err, &notFound))
+	assert.Equal(t, "email: quan12yt@gmail.com is not exist in database", err.Error())
+}
+
+func TestGetIdFromEmailQuoted(t *testing.T) {
+	db, mock := DbMock()
+	repo := RelationRepoImp{Db: db}
+	sql_query := `select e.email_id from email e where e.email = $1`
+
+	mock.ExpectQuery(regexp.QuoteMeta(sql_query)).WithArgs("o'neil@gmail.com").
+		WillReturnRows(sqlmock.NewRows([]string{"email_id"}).AddRow("7"))
+
+	resp, err := repo.GetIdFromEmail(context.Background(), "o'neil@gmail.com")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "7", resp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateEmail(t *testing.T) {
+	db, mock := DbMock()
+	repo := RelationRepoImp{Db: db}
+	sql_query := `insert into email (email)
+	values ($1)
+	returning email_id`
+
+	mock.ExpectQuery(regexp.QuoteMeta(sql_query)).WithArgs("quan12yt@gmail.com").
+		WillReturnRows(sqlmock.NewRows([]string{"email_id"}).AddRow("6"))
+
+	id, err := repo.CreateEmail(context.Background(), "quan12yt@gmail.com")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "6", id)
+}
+
+func TestCreateEmailDuplicate(t *testing.T) {
+	db, mock := DbMock()
+	repo := RelationRepoImp{Db: db}
+
+	mock.ExpectQuery("insert into email").WithArgs("quan12yt@gmail.com").
+		WillReturnError(&pq.Error{Code: "23505"})
+
+	_, err := repo.CreateEmail(context.Background(), "quan12yt@gmail.com")
+
+	assert.Equal(t, ErrDuplicate, err)
+}
+
+func TestGetAllEmails(t *testing.T) {
+	db, mock := DbMock()
+	repo := RelationRepoImp{Db: db}
+	sql_query := `select e.email_id, e.email
+	from email e
+	order by e.email_id`
+
+	mock.ExpectQuery(regexp.QuoteMeta(sql_query)).
+		WillReturnRows(sqlmock.NewRows([]string{"email_id", "email"}).
+			AddRow("1", "quan12yt@gmail.com").
+			AddRow("2", "letoan@gmail.com"))
+
+	resp, err := repo.GetAllEmails(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, []model.User{
+		{Id: "1", Email: "quan12yt@gmail.com"},
+		{Id: "2", Email: "letoan@gmail.com"},
+	}, resp)
+}
+
 func TestGetEmailByStatus(t *testing.T) {
 	db, mock := DbMock()
 	repo := RelationRepoImp{Db: db}
 
 	id := "1"
-	status := "FRIEND"
+	status := model.StatusFriend
+
+	sql_query := `select distinct e.email_id, e.email
+	from friend_relationship fr join email e
+	on e.email_id = fr.friend_id
+	where fr.your_id = $1 and fr.status = $2
+	and ($2 = 'BLOCK' or not exists (select 1 from friend_relationship b
+		where b.status = 'BLOCK'
+		and ((b.your_id = $1 and b.friend_id = e.email_id) or (b.your_id = e.email_id and b.friend_id = $1))))`
+
+	mock.ExpectQuery(regexp.QuoteMeta(`select count(*) from (`+sql_query+`) r`)).
+		WithArgs(id, status).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta(`select r.email_id, r.email from (`+sql_query+`) r
+	where r.email_id > $3
+	order by r.email_id
+	limit $4`)).
+		WithArgs(id, status, int64(0), 11).
+		WillReturnRows(sqlmock.NewRows([]string{"email_id", "email"}).
+			AddRow("2", "quan12yt@gmail.com"))
+
+	resp, err := repo.GetEmailByStatus(context.Background(), id, status, model.Page{Limit: 10})
 
-	sql_query := `select distinct e.email
-	from email e left join friend_relationship fr 
-	on (e.email_id = fr.friend_id) or (e.email_id = fr.your_id)
-	where (fr.your_id = $1 or fr.friend_id = $1) and fr.status = $2 and e.email_id != $1`
+	assert.Nil(t, err)
+	assert.Equal(t, model.EmailPage{Emails: []string{"quan12yt@gmail.com"}, Total: 1}, resp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
 
-	mock.ExpectQuery(regexp.QuoteMeta(sql_query)).
-		WillReturnRows(sqlmock.NewRows([]string{"email"}).
-			AddRow("quan12yt@gmail.com"))
+func TestGetEmailByStatusNextPage(t *testing.T) {
+	db, mock := DbMock()
+	repo := RelationRepoImp{Db: db}
 
-	resp, err := repo.GetEmailByStatus(id, status)
+	mock.ExpectQuery("select count").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(5))
+	mock.ExpectQuery("select r.email_id, r.email").
+		WithArgs("1", model.StatusFriend, int64(2), 3).
+		WillReturnRows(sqlmock.NewRows([]string{"email_id", "email"}).
+			AddRow("3", "letoan@gmail.com").
+			AddRow("4", "quang@gmail.com").
+			AddRow("5", "len@gmail.com"))
+
+	resp, err := repo.GetEmailByStatus(context.Background(), "1", model.StatusFriend, model.Page{Limit: 2, Cursor: encodeCursor("2")})
 
 	assert.Nil(t, err)
-	assert.NotNil(t, resp)
-	assert.Equal(t, "quan12yt@gmail.com", resp[0])
+	assert.Equal(t, []string{"letoan@gmail.com", "quang@gmail.com"}, resp.Emails)
+	assert.Equal(t, 5, resp.Total)
+	assert.Equal(t, encodeCursor("4"), resp.NextCursor)
+}
+
+func TestGetEmailByStatusInvalidCursor(t *testing.T) {
+	db, _ := DbMock()
+	repo := RelationRepoImp{Db: db}
+
+	_, err := repo.GetEmailByStatus(context.Background(), "1", model.StatusFriend, model.Page{Limit: 2, Cursor: "not a cursor"})
+
+	assert.Equal(t, ErrInvalidCursor, err)
+}
+
+func TestGetCommonEmails(t *testing.T) {
+	db, mock := DbMock()
+	repo := RelationRepoImp{Db: db}
+
+	sql_query := `select e.email_id, e.email
+	from email e
+	where e.email_id in (select fr.friend_id from friend_relationship fr
+		where fr.your_id = any($1::int8[]) and fr.status = 'FRIEND'
+		group by fr.friend_id
+		having count(distinct fr.your_id) = cardinality($1::int8[]))`
+	ids := pq.Array([]string{"1", "2", "4"})
+
+	mock.ExpectQuery(regexp.QuoteMeta(`select count(*) from (` + sql_query)).
+		WithArgs(ids).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta(`select r.email_id, r.email from (`+sql_query)).
+		WithArgs(ids, int64(0), 11).
+		WillReturnRows(sqlmock.NewRows([]string{"email_id", "email"}).
+			AddRow("3", "letoan@gmail.com"))
+
+	resp, err := repo.GetCommonEmails(context.Background(), []string{"1", "2", "4"}, model.Page{Limit: 10})
+
+	assert.Nil(t, err)
+	assert.Equal(t, model.EmailPage{Emails: []string{"letoan@gmail.com"}, Total: 1}, resp)
 }
 
 func TestGetRetrivableEmails(t *testing.T) {
@@ -93,51 +301,299 @@ func TestGetRetrivableEmails(t *testing.T) {
 	repo := RelationRepoImp{Db: db}
 	id := "1"
 
-	sql_query := `select distinct e.email 
-	from friend_relationship fr left join email e
-	on e.email_id = fr.your_id 
-	where fr.friend_id = $1 and ((fr.status = 'FRIEND' or fr.status = 'SUBCRIBE') and fr.status != 'BLOCK')`
+	sql_query := `select e.email_id, e.email
+	from email e
+	where (exists (select 1 from friend_relationship fr
+		where fr.your_id = e.email_id and fr.friend_id = $1 and (fr.status = 'FRIEND' or fr.status = 'SUBCRIBE'))
+		or e.email = any($2))
+	and not exists (select 1 from friend_relationship b
+		where b.your_id = e.email_id and b.friend_id = $1 and b.status = 'BLOCK')`
+
+	mock.ExpectQuery(regexp.QuoteMeta(`select count(*) from (` + sql_query + `) r`)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta(`select r.email_id, r.email from (` + sql_query + `) r`)).
+		WillReturnRows(sqlmock.NewRows([]string{"email_id", "email"}).
+			AddRow("2", "quan12yt@gmail.com"))
+
+	resp, err := repo.GetRetrivableEmails(context.Background(), id, []string{"quan12yt@gmail.com"}, model.Page{Limit: 10})
+
+	assert.Nil(t, err)
+	assert.Equal(t, model.EmailPage{Emails: []string{"quan12yt@gmail.com"}, Total: 1}, resp)
+}
+
+func TestGetUnregisteredEmails(t *testing.T) {
+	db, mock := DbMock()
+	repo := RelationRepoImp{Db: db}
+
+	sql_query := `select m.email
+	from unnest($1::text[]) with ordinality m(email, n)
+	where not exists (select 1 from email e where e.email = m.email)
+	order by m.n`
+	emails := []string{"quan12yt@gmail.com", "nobody@gmail.com"}
 
 	mock.ExpectQuery(regexp.QuoteMeta(sql_query)).
-		WillReturnRows(sqlmock.NewRows([]string{"email"}).
-			AddRow("quan12yt@gmail.com"))
+		WithArgs(pq.Array(emails)).
+		WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("nobody@gmail.com"))
 
-	resp, err := repo.GetRetrivableEmails(id)
+	resp, err := repo.GetUnregisteredEmails(context.Background(), emails)
 
 	assert.Nil(t, err)
-	assert.NotNil(t, resp)
-	assert.Equal(t, "quan12yt@gmail.com", resp[0])
+	assert.Equal(t, []string{"nobody@gmail.com"}, resp)
+}
+
+func TestGetRetrivableEmailsTimeout(t *testing.T) {
+	db, mock := DbMock()
+	repo := RelationRepoImp{Db: db}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	mock.ExpectQuery("select count").
+		WillDelayFor(time.Second).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+	resp, err := repo.GetRetrivableEmails(ctx, "1", nil, model.Page{Limit: 10})
+
+	assert.Equal(t, model.EmailPage{}, resp)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestGetSuggestions(t *testing.T) {
+	db, mock := DbMock()
+	repo := RelationRepoImp{Db: db}
+
+	sql_query := `select s.email, count(*), array_agg(m.email order by m.email_id)
+	from friend_relationship f1
+	join friend_relationship f2 on f2.your_id = f1.friend_id and f2.status = 'FRIEND'`
+
+	mock.ExpectQuery(regexp.QuoteMeta(sql_query)).
+		WithArgs("1", 10).
+		WillReturnRows(sqlmock.NewRows([]string{"email", "count", "array_agg"}).
+			AddRow("toan@gmail.com", 2, "{quang@gmail.com,hau@gmail.com}").
+			AddRow("len@gmail.com", 1, "{quang@gmail.com}"))
+
+	resp, err := repo.GetSuggestions(context.Background(), "1", 10)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []model.Suggestion{
+		{Email: "toan@gmail.com", MutualCount: 2, MutualFriends: []string{"quang@gmail.com", "hau@gmail.com"}},
+		{Email: "len@gmail.com", MutualCount: 1, MutualFriends: []string{"quang@gmail.com"}},
+	}, resp)
+}
+
+func TestGetFriendsOf(t *testing.T) {
+	db, mock := DbMock()
+	repo := RelationRepoImp{Db: db}
+
+	sql_query := `select fr.your_id, e.email_id, e.email
+	from friend_relationship fr join email e
+	on e.email_id = fr.friend_id
+	where fr.your_id = any($1::int8[]) and fr.status = 'FRIEND'`
+
+	mock.ExpectQuery(regexp.QuoteMeta(sql_query)).
+		WithArgs(pq.Array([]string{"1", "2"})).
+		WillReturnRows(sqlmock.NewRows([]string{"your_id", "email_id", "email"}).
+			AddRow("1", "2", "quang@gmail.com").
+			AddRow("1", "3", "hau@gmail.com").
+			AddRow("2", "1", "quan12yt@gmail.com"))
+
+	resp, err := repo.GetFriendsOf(context.Background(), []string{"1", "2"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]model.User{
+		"1": {{Id: "2", Email: "quang@gmail.com"}, {Id: "3", Email: "hau@gmail.com"}},
+		"2": {{Id: "1", Email: "quan12yt@gmail.com"}},
+	}, resp)
 }
 
 func TestAddRelationSucceed(t *testing.T) {
 	db, mock := DbMock()
 	repo := RelationRepoImp{Db: db}
 	id := []string{"1", "2"}
-	status := "FRIEND"
+	status := model.StatusFriend
 	sql_query := `insert into friend_relationship (your_id, friend_id, status)
 	values ($1, $2, $3), ($2, $1, $3)`
 	result := sqlmock.NewResult(1, 1)
 
 	mock.ExpectExec(regexp.QuoteMeta(sql_query)).WithArgs(id[0], id[1], status).WillReturnResult(result).WillReturnError(nil)
+	mock.ExpectExec("insert into relation_audit").WithArgs(id[0], id[1], model.AuditAdd, "", status, "").WillReturnResult(result)
 
-	resp, err := repo.AddRelation(id, status)
+	resp, err := repo.AddRelation(context.Background(), id, status)
 
 	assert.Nil(t, err)
-	assert.NotNil(t, resp)
-	assert.Equal(t, result, result)
+	assert.Equal(t, true, resp)
+	assert.Nil(t, mock.ExpectationsWereMet())
 }
 
 func TestAddRelationFailed(t *testing.T) {
 	db, mock := DbMock()
 	repo := RelationRepoImp{Db: db}
 	id := []string{"1", "2"}
-	status := "FRIEND"
+	status := model.StatusFriend
 	sql_query := `insert into friend_relationship (your_id, friend_id, status)
 	values ($1, $2, $3), ($2, $1, $3)`
 
 	mock.ExpectExec(regexp.QuoteMeta(sql_query)).WithArgs(id[0], id[1], status).WillReturnResult(nil).WillReturnError(errors.New(""))
 
-	_, err := repo.AddRelation(id, status)
+	resp, err := repo.AddRelation(context.Background(), id, status)
+
+	assert.NotNil(t, err)
+	assert.Equal(t, false, resp)
+}
+
+func TestRemoveRelationSucceed(t *testing.T) {
+	db, mock := DbMock()
+	repo := RelationRepoImp{Db: db}
+	id := []string{"1", "2"}
+	status := model.StatusFriend
+	sql_query := `delete from friend_relationship
+	where ((your_id = $1 and friend_id = $2) or (your_id = $2 and friend_id = $1)) and status = $3`
+
+	mock.ExpectExec(regexp.QuoteMeta(sql_query)).WithArgs(id[0], id[1], status).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("insert into relation_audit").WithArgs(id[0], id[1], model.AuditRemove, status, "", "").WillReturnResult(sqlmock.NewResult(1, 1))
+
+	resp, err := repo.RemoveRelation(context.Background(), id, status)
+
+	assert.Nil(t, err)
+	assert.Equal(t, true, resp)
+}
+
+func TestRemoveRelationNothingRemoved(t *testing.T) {
+	db, mock := DbMock()
+	repo := RelationRepoImp{Db: db}
+	id := []string{"1", "2"}
+	status := model.StatusFriend
+	sql_query := `delete from friend_relationship
+	where ((your_id = $1 and friend_id = $2) or (your_id = $2 and friend_id = $1)) and status = $3`
+
+	mock.ExpectExec(regexp.QuoteMeta(sql_query)).WithArgs(id[0], id[1], status).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	resp, err := repo.RemoveRelation(context.Background(), id, status)
+
+	assert.Nil(t, err)
+	assert.Equal(t, false, resp)
+}
+
+func TestRemoveRelationFailed(t *testing.T) {
+	db, mock := DbMock()
+	repo := RelationRepoImp{Db: db}
+	id := []string{"1", "2"}
+	status := model.StatusFriend
+	sql_query := `delete from friend_relationship
+	where ((your_id = $1 and friend_id = $2) or (your_id = $2 and friend_id = $1)) and status = $3`
+
+	mock.ExpectExec(regexp.QuoteMeta(sql_query)).WithArgs(id[0], id[1], status).WillReturnError(errors.New(""))
+
+	_, err := repo.RemoveRelation(context.Background(), id, status)
+
+	assert.NotNil(t, err)
+}
+
+func TestAddDirectedRelationSucceed(t *testing.T) {
+	db, mock := DbMock()
+	repo := RelationRepoImp{Db: db}
+	id := []string{"1", "2"}
+	status := model.StatusSubcribe
+	sql_query := `insert into friend_relationship (your_id, friend_id, status)
+	values ($1, $2, $3)`
+
+	mock.ExpectExec(regexp.QuoteMeta(sql_query)).WithArgs(id[0], id[1], status).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("insert into relation_audit").WithArgs(id[0], id[1], model.AuditAdd, "", status, "").WillReturnResult(sqlmock.NewResult(1, 1))
+
+	resp, err := repo.AddDirectedRelation(context.Background(), id, status)
+
+	assert.Nil(t, err)
+	assert.Equal(t, true, resp)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestAddDirectedRelationFailed(t *testing.T) {
+	db, mock := DbMock()
+	repo := RelationRepoImp{Db: db}
+	id := []string{"1", "2"}
+	status := model.StatusSubcribe
+	sql_query := `insert into friend_relationship (your_id, friend_id, status)
+	values ($1, $2, $3)`
+
+	mock.ExpectExec(regexp.QuoteMeta(sql_query)).WithArgs(id[0], id[1], status).WillReturnError(errors.New(""))
+
+	resp, err := repo.AddDirectedRelation(context.Background(), id, status)
 
 	assert.NotNil(t, err)
+	assert.Equal(t, false, resp)
+}
+
+func TestRemoveDirectedRelationSucceed(t *testing.T) {
+	db, mock := DbMock()
+	repo := RelationRepoImp{Db: db}
+	id := []string{"1", "2"}
+	status := model.StatusBlock
+	sql_query := `delete from friend_relationship
+	where your_id = $1 and friend_id = $2 and status = $3`
+
+	mock.ExpectExec(regexp.QuoteMeta(sql_query)).WithArgs(id[0], id[1], status).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("insert into relation_audit").WithArgs(id[0], id[1], model.AuditRemove, status, "", "").WillReturnResult(sqlmock.NewResult(1, 1))
+
+	resp, err := repo.RemoveDirectedRelation(context.Background(), id, status)
+
+	assert.Nil(t, err)
+	assert.Equal(t, true, resp)
+}
+
+func TestRemoveDirectedRelationFailed(t *testing.T) {
+	db, mock := DbMock()
+	repo := RelationRepoImp{Db: db}
+	id := []string{"1", "2"}
+	status := model.StatusBlock
+	sql_query := `delete from friend_relationship
+	where your_id = $1 and friend_id = $2 and status = $3`
+
+	mock.ExpectExec(regexp.QuoteMeta(sql_query)).WithArgs(id[0], id[1], status).WillReturnError(errors.New(""))
+
+	_, err := repo.RemoveDirectedRelation(context.Background(), id, status)
+
+	assert.NotNil(t, err)
+}
+
+func TestAuditRequestId(t *testing.T) {
+	db, mock := DbMock()
+	repo := RelationRepoImp{Db: db}
+	ctx := context.WithValue(context.Background(), middleware.RequestIDKey, "host/abc-000001")
+
+	mock.ExpectExec("insert into friend_relationship").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`insert into relation_audit (actor_id, target_id, action, old_status, new_status, request_id)`)).
+		WithArgs("1", "2", model.AuditAdd, "", model.StatusBlock, "host/abc-000001").
+		WillReturnError(errors.New("insert failed"))
+
+	_, err := repo.AddDirectedRelation(ctx, []string{"1", "2"}, model.StatusBlock)
+
+	assert.Equal(t, errors.New("insert failed"), err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAuditRecords(t *testing.T) {
+	db, mock := DbMock()
+	repo := RelationRepoImp{Db: db}
+	from := time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)
+	at := time.Date(2021, 5, 6, 14, 20, 59, 0, time.UTC)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`select a.audit_id, actor.email, target.email, a.action, a.old_status, a.new_status, a.request_id, a.created_at`)).
+		WithArgs("1", sql.NullTime{Time: from, Valid: true}, sql.NullTime{}, int64(0), 2).
+		WillReturnRows(sqlmock.NewRows([]string{"audit_id", "actor", "target", "action", "old_status", "new_status", "request_id", "created_at"}).
+			AddRow("3", "quan12yt@gmail.com", "quang@gmail.com", "ADD", "", "BLOCK", "host/abc-000001", at).
+			AddRow("5", "quang@gmail.com", "quan12yt@gmail.com", "ADD", "", "SUBCRIBE", "host/abc-000002", at))
+
+	page, err := repo.GetAuditRecords(context.Background(), "1", from, time.Time{}, model.Page{Limit: 1})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []model.AuditRecord{{
+		Id:        "3",
+		Actor:     "quan12yt@gmail.com",
+		Target:    "quang@gmail.com",
+		Action:    model.AuditAdd,
+		NewStatus: model.StatusBlock,
+		RequestId: "host/abc-000001",
+		CreatedAt: at,
+	}}, page.Records)
+	assert.Equal(t, encodeCursor("3"), page.NextCursor)
+	assert.Nil(t, mock.ExpectationsWereMet())
 }
@@ -1,9 +1,66 @@
 package repos
 
+import (
+	"context"
+	"errors"
+	"friend-management-v1/model"
+	"time"
+)
+
+// ErrDuplicate is returned when a relation or an email that already exists is
+// added again.
+var ErrDuplicate = errors.New("relation already exists")
+
+// EmailNotFoundError is returned by GetIdFromEmail for an unknown email.
+type EmailNotFoundError struct {
+	Email string
+}
+
+func (e *EmailNotFoundError) Error() string {
+	return "email: " + e.Email + " is not exist in database"
+}
+
+// RelationRepo stores relationships as rows from your_id to friend_id. A
+// FRIEND relation is two-way and stored in both directions, SUBCRIBE and BLOCK
+// are one-way and stored from the requestor to the target only.
 type RelationRepo interface {
-	CheckIfExist(id1 string, id2 string, status string) bool
-	GetIdFromEmail(email string) (string, error)
-	GetEmailByStatus(id string, status string) ([]string, error)
-	GetRetrivableEmails(id string) ([]string, error)
-	AddRelation(ids []string, status string) (bool, error)
+	CheckIfExist(ctx context.Context, id1 string, id2 string, status model.RelationStatus) (bool, error)
+	GetIdFromEmail(ctx context.Context, email string) (string, error)
+	// CreateEmail registers email and returns its id, or ErrDuplicate when it
+	// is already registered.
+	CreateEmail(ctx context.Context, email string) (string, error)
+	// GetAllEmails returns every registered email in id order.
+	GetAllEmails(ctx context.Context) ([]model.User, error)
+	// GetUnregisteredEmails returns the emails that are not registered, in
+	// the order of emails.
+	GetUnregisteredEmails(ctx context.Context, emails []string) ([]string, error)
+	// GetEmailByStatus, GetCommonEmails and GetRetrivableEmails return one
+	// page of emails ordered by id, page.Limit must be positive.
+	GetEmailByStatus(ctx context.Context, id string, status model.RelationStatus, page model.Page) (model.EmailPage, error)
+	GetCommonEmails(ctx context.Context, ids []string, page model.Page) (model.EmailPage, error)
+	GetRetrivableEmails(ctx context.Context, id string, mentions []string, page model.Page) (model.EmailPage, error)
+	// GetSuggestions returns at most limit friends of the friends of id that
+	// are not id, its friends or blocked, most mutual friends first.
+	GetSuggestions(ctx context.Context, id string, limit int) ([]model.Suggestion, error)
+	// GetFriendsOf returns the friends of each of ids, in id order, leaving
+	// out the friendships hidden by a block between the two emails.
+	GetFriendsOf(ctx context.Context, ids []string) (map[string][]model.User, error)
+	// AddRelation, AddDirectedRelation, RemoveRelation and
+	// RemoveDirectedRelation append an audit record of the change made by
+	// ids[0] toward ids[1], with the request id chi's middleware.RequestID put
+	// in ctx. Called in a Transaction the record is kept only with the change.
+	AddRelation(ctx context.Context, ids []string, status model.RelationStatus) (bool, error)
+	AddDirectedRelation(ctx context.Context, ids []string, status model.RelationStatus) (bool, error)
+	RemoveRelation(ctx context.Context, ids []string, status model.RelationStatus) (bool, error)
+	RemoveDirectedRelation(ctx context.Context, ids []string, status model.RelationStatus) (bool, error)
+	// GetAuditRecords returns one page of the audit records where id is the
+	// actor or the target, oldest first. They are created from from, included,
+	// to to, excluded, a zero time leaving that side open.
+	GetAuditRecords(ctx context.Context, id string, from time.Time, to time.Time, page model.Page) (model.AuditPage, error)
+	// Transaction runs fn with a RelationRepo bound to one transaction, the
+	// writes of fn are kept only when it returns nil.
+	Transaction(ctx context.Context, fn func(tx RelationRepo) error) error
+	// LockEmails makes the other transactions locking one of ids wait until
+	// the current transaction ends.
+	LockEmails(ctx context.Context, ids []string) error
 }
@@ -0,0 +1,128 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	"friend-management-v1/model"
+
+	"github.com/lib/pq"
+)
+
+type UpdateRepoImp struct {
+	Db *sql.DB
+}
+
+func NewUpdateRepo(db *sql.DB) UpdateRepo {
+	return &UpdateRepoImp{
+		Db: db,
+	}
+}
+
+// CreateUpdate stores the update and its inbox items in one transaction.
+func (repo *UpdateRepoImp) CreateUpdate(ctx context.Context, sender model.User, text string, recipients []string) (model.Update, error) {
+	update_query := `insert into email_update (sender_id, text)
+	values ($1, $2)
+	returning update_id, created_at`
+	inbox_query := `insert into inbox (recipient_id, update_id)
+	select e.email_id, $1
+	from email e
+	where e.email = any($2)`
+
+	tx, err := repo.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Update{}, dbError(ctx, err)
+	}
+	update := model.Update{Sender: sender.Email, Text: text}
+	if err := tx.QueryRowContext(ctx, update_query, sender.Id, text).Scan(&update.Id, &update.CreatedAt); err != nil {
+		tx.Rollback()
+		return model.Update{}, dbError(ctx, err)
+	}
+	if _, err := tx.ExecContext(ctx, inbox_query, update.Id, pq.Array(recipients)); err != nil {
+		tx.Rollback()
+		return model.Update{}, dbError(ctx, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return model.Update{}, dbError(ctx, err)
+	}
+	return update, nil
+}
+
+func (repo *UpdateRepoImp) GetUpdate(ctx context.Context, id string) (model.Update, error) {
+	sql_query := `select u.update_id, s.email, u.text, u.created_at
+	from email_update u
+	join email s on s.email_id = u.sender_id
+	where u.update_id = $1`
+
+	var update model.Update
+	err := repo.Db.QueryRowContext(ctx, sql_query, id).Scan(&update.Id, &update.Sender, &update.Text, &update.CreatedAt)
+	if err == sql.ErrNoRows {
+		return model.Update{}, ErrUpdateNotFound
+	}
+	if err != nil {
+		return model.Update{}, dbError(ctx, err)
+	}
+	return update, nil
+}
+
+func (repo *UpdateRepoImp) GetInbox(ctx context.Context, recipientId string, page model.Page, unreadOnly bool) (model.InboxPage, error) {
+	before, err := decodeCursor(page.Cursor)
+	if err != nil {
+		return model.InboxPage{}, err
+	}
+	count_query := `select count(*), count(*) filter (where i.read_at is null)
+	from inbox i
+	where i.recipient_id = $1`
+	page_query := `select i.inbox_id, u.update_id, s.email, u.text, u.created_at, i.read_at is not null
+	from inbox i
+	join email_update u on u.update_id = i.update_id
+	join email s on s.email_id = u.sender_id
+	where i.recipient_id = $1
+	and ($2 = false or i.read_at is null)
+	and ($3 = 0 or i.inbox_id < $3)
+	order by i.inbox_id desc
+	limit $4`
+
+	result := model.InboxPage{Items: []model.InboxItem{}}
+	if err := repo.Db.QueryRowContext(ctx, count_query, recipientId).Scan(&result.Total, &result.Unread); err != nil {
+		return model.InboxPage{}, dbError(ctx, err)
+	}
+	if unreadOnly {
+		result.Total = result.Unread
+	}
+	rows, err := repo.Db.QueryContext(ctx, page_query, recipientId, unreadOnly, before, page.Limit+1)
+	if err != nil {
+		return model.InboxPage{}, dbError(ctx, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item model.InboxItem
+		err = rows.Scan(&item.Id, &item.Update.Id, &item.Update.Sender, &item.Update.Text, &item.Update.CreatedAt, &item.Read)
+		if err != nil {
+			return model.InboxPage{}, dbError(ctx, err)
+		}
+		if len(result.Items) == page.Limit {
+			result.NextCursor = encodeCursor(result.Items[len(result.Items)-1].Id)
+			break
+		}
+		result.Items = append(result.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return model.InboxPage{}, dbError(ctx, err)
+	}
+	return result, nil
+}
+
+func (repo *UpdateRepoImp) MarkRead(ctx context.Context, recipientId string, ids []string) (int, error) {
+	sql_query := `update inbox set read_at = now()
+	where recipient_id = $1 and inbox_id = any($2::int8[]) and read_at is null`
+
+	result, err := repo.Db.ExecContext(ctx, sql_query, recipientId, pq.Array(ids))
+	if err != nil {
+		return 0, dbError(ctx, err)
+	}
+	marked, err := result.RowsAffected()
+	if err != nil {
+		return 0, dbError(ctx, err)
+	}
+	return int(marked), nil
+}
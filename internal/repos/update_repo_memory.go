@@ -0,0 +1,144 @@
+package repos
+
+import (
+	"context"
+	"friend-management-v1/model"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type memoryInboxItem struct {
+	recipientId string
+	update      int
+	read        bool
+}
+
+// UpdateRepoMemory keeps updates and inboxes in memory beside the emails of a
+// RelationRepoMemory. It is safe for concurrent use.
+type UpdateRepoMemory struct {
+	mu      sync.RWMutex
+	emails  *RelationRepoMemory
+	updates []model.Update
+	// inbox holds the items of every recipient, the id of an item is its
+	// index plus one.
+	inbox []memoryInboxItem
+}
+
+func NewUpdateRepoMemory(emails *RelationRepoMemory) *UpdateRepoMemory {
+	return &UpdateRepoMemory{
+		emails: emails,
+	}
+}
+
+func (repo *UpdateRepoMemory) CreateUpdate(ctx context.Context, sender model.User, text string, recipients []string) (model.Update, error) {
+	if err := ctx.Err(); err != nil {
+		return model.Update{}, err
+	}
+	ids := repo.idsOf(recipients)
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	update := model.Update{
+		Id:        strconv.Itoa(len(repo.updates) + 1),
+		Sender:    sender.Email,
+		Text:      text,
+		CreatedAt: time.Now(),
+	}
+	repo.updates = append(repo.updates, update)
+	for _, id := range ids {
+		repo.inbox = append(repo.inbox, memoryInboxItem{recipientId: id, update: len(repo.updates) - 1})
+	}
+	return update, nil
+}
+
+// idsOf returns the ids of the registered emails of emails, once each.
+func (repo *UpdateRepoMemory) idsOf(emails []string) []string {
+	defer repo.emails.rlock()()
+
+	var ids []string
+	seen := make(map[string]bool)
+	for _, email := range emails {
+		if id, ok := repo.emails.ids[email]; ok && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (repo *UpdateRepoMemory) GetUpdate(ctx context.Context, id string) (model.Update, error) {
+	if err := ctx.Err(); err != nil {
+		return model.Update{}, err
+	}
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	i, err := strconv.Atoi(id)
+	if err != nil || i < 1 || i > len(repo.updates) {
+		return model.Update{}, ErrUpdateNotFound
+	}
+	return repo.updates[i-1], nil
+}
+
+func (repo *UpdateRepoMemory) GetInbox(ctx context.Context, recipientId string, page model.Page, unreadOnly bool) (model.InboxPage, error) {
+	if err := ctx.Err(); err != nil {
+		return model.InboxPage{}, err
+	}
+	before, err := decodeCursor(page.Cursor)
+	if err != nil {
+		return model.InboxPage{}, err
+	}
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	result := model.InboxPage{Items: []model.InboxItem{}}
+	for i := len(repo.inbox) - 1; i >= 0; i-- {
+		item := repo.inbox[i]
+		if item.recipientId != recipientId {
+			continue
+		}
+		if !item.read {
+			result.Unread++
+		}
+		if unreadOnly && item.read {
+			continue
+		}
+		result.Total++
+		if before != 0 && int64(i+1) >= before {
+			continue
+		}
+		if len(result.Items) == page.Limit {
+			result.NextCursor = encodeCursor(result.Items[len(result.Items)-1].Id)
+			continue
+		}
+		result.Items = append(result.Items, model.InboxItem{
+			Id:     strconv.Itoa(i + 1),
+			Update: repo.updates[item.update],
+			Read:   item.read,
+		})
+	}
+	return result, nil
+}
+
+func (repo *UpdateRepoMemory) MarkRead(ctx context.Context, recipientId string, ids []string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	marked := 0
+	for _, id := range ids {
+		i, err := strconv.Atoi(id)
+		if err != nil || i < 1 || i > len(repo.inbox) {
+			continue
+		}
+		item := &repo.inbox[i-1]
+		if item.recipientId != recipientId || item.read {
+			continue
+		}
+		item.read = true
+		marked++
+	}
+	return marked, nil
+}
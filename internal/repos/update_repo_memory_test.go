@@ -0,0 +1,67 @@
+package repos
+
+import (
+	"context"
+	"friend-management-v1/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryInbox(t *testing.T) {
+	ctx := context.Background()
+	emails := NewRelationRepoMemory()
+	sender := emails.AddEmail("quan12yt@gmail.com")
+	recipient := emails.AddEmail("quang@gmail.com")
+	other := emails.AddEmail("hau@gmail.com")
+	repo := NewUpdateRepoMemory(emails)
+
+	for _, text := range []string{"first", "second", "third"} {
+		_, err := repo.CreateUpdate(ctx, model.User{Id: sender, Email: "quan12yt@gmail.com"}, text,
+			[]string{"quang@gmail.com", "unknown@gmail.com"})
+		assert.Nil(t, err)
+	}
+	update, err := repo.CreateUpdate(ctx, model.User{Id: sender, Email: "quan12yt@gmail.com"}, "fourth", []string{"hau@gmail.com"})
+	assert.Nil(t, err)
+	assert.Equal(t, "4", update.Id)
+	assert.Equal(t, "quan12yt@gmail.com", update.Sender)
+	stored, err := repo.GetUpdate(ctx, "4")
+	assert.Nil(t, err)
+	assert.Equal(t, update, stored)
+	_, err = repo.GetUpdate(ctx, "5")
+	assert.Equal(t, ErrUpdateNotFound, err)
+
+	inbox, err := repo.GetInbox(ctx, recipient, model.Page{Limit: 2}, false)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, inbox.Total)
+	assert.Equal(t, 3, inbox.Unread)
+	assert.Equal(t, []string{"third", "second"}, textsOf(inbox.Items))
+	assert.NotEmpty(t, inbox.NextCursor)
+
+	inbox, err = repo.GetInbox(ctx, recipient, model.Page{Limit: 2, Cursor: inbox.NextCursor}, false)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"first"}, textsOf(inbox.Items))
+	assert.Empty(t, inbox.NextCursor)
+
+	marked, err := repo.MarkRead(ctx, recipient, []string{inbox.Items[0].Id, inbox.Items[0].Id, "4", "99"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, marked)
+
+	inbox, err = repo.GetInbox(ctx, recipient, model.Page{Limit: 10}, true)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, inbox.Total)
+	assert.Equal(t, 2, inbox.Unread)
+	assert.Equal(t, []string{"third", "second"}, textsOf(inbox.Items))
+
+	inbox, err = repo.GetInbox(ctx, other, model.Page{Limit: 10}, false)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"fourth"}, textsOf(inbox.Items))
+}
+
+func textsOf(items []model.InboxItem) []string {
+	texts := []string{}
+	for _, item := range items {
+		texts = append(texts, item.Update.Text)
+	}
+	return texts
+}
@@ -0,0 +1,118 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"friend-management-v1/model"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateUpdate(t *testing.T) {
+	db, mock := DbMock()
+	repo := UpdateRepoImp{Db: db}
+	created := time.Date(2021, 5, 6, 14, 20, 59, 0, time.UTC)
+	recipients := []string{"quang@gmail.com", "hau@gmail.com"}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`insert into email_update (sender_id, text)`)).
+		WithArgs("1", "hello").
+		WillReturnRows(sqlmock.NewRows([]string{"update_id", "created_at"}).AddRow("7", created))
+	mock.ExpectExec(regexp.QuoteMeta(`insert into inbox (recipient_id, update_id)`)).
+		WithArgs("7", pq.Array(recipients)).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	update, err := repo.CreateUpdate(context.Background(), model.User{Id: "1", Email: "quan12yt@gmail.com"}, "hello", recipients)
+
+	assert.Nil(t, err)
+	assert.Equal(t, model.Update{Id: "7", Sender: "quan12yt@gmail.com", Text: "hello", CreatedAt: created}, update)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateUpdateRollback(t *testing.T) {
+	db, mock := DbMock()
+	repo := UpdateRepoImp{Db: db}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`insert into email_update (sender_id, text)`)).
+		WillReturnRows(sqlmock.NewRows([]string{"update_id", "created_at"}).AddRow("7", time.Now()))
+	mock.ExpectExec(regexp.QuoteMeta(`insert into inbox (recipient_id, update_id)`)).
+		WillReturnError(errors.New("insert failed"))
+	mock.ExpectRollback()
+
+	_, err := repo.CreateUpdate(context.Background(), model.User{Id: "1"}, "hello", nil)
+
+	assert.Equal(t, errors.New("insert failed"), err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestGetUpdate(t *testing.T) {
+	db, mock := DbMock()
+	repo := UpdateRepoImp{Db: db}
+	created := time.Date(2021, 5, 6, 14, 20, 59, 0, time.UTC)
+	sql_query := `select u.update_id, s.email, u.text, u.created_at
+	from email_update u
+	join email s on s.email_id = u.sender_id
+	where u.update_id = $1`
+
+	mock.ExpectQuery(regexp.QuoteMeta(sql_query)).WithArgs("7").
+		WillReturnRows(sqlmock.NewRows([]string{"update_id", "email", "text", "created_at"}).AddRow("7", "quan12yt@gmail.com", "hello", created))
+	mock.ExpectQuery(regexp.QuoteMeta(sql_query)).WithArgs("8").
+		WillReturnRows(sqlmock.NewRows([]string{"update_id", "email", "text", "created_at"}))
+
+	update, err := repo.GetUpdate(context.Background(), "7")
+	assert.Nil(t, err)
+	assert.Equal(t, model.Update{Id: "7", Sender: "quan12yt@gmail.com", Text: "hello", CreatedAt: created}, update)
+
+	_, err = repo.GetUpdate(context.Background(), "8")
+	assert.Equal(t, ErrUpdateNotFound, err)
+}
+
+func TestGetInbox(t *testing.T) {
+	db, mock := DbMock()
+	repo := UpdateRepoImp{Db: db}
+	created := time.Date(2021, 5, 6, 14, 20, 59, 0, time.UTC)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`select count(*), count(*) filter (where i.read_at is null)`)).
+		WithArgs("2").
+		WillReturnRows(sqlmock.NewRows([]string{"count", "count"}).AddRow(3, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`select i.inbox_id, u.update_id, s.email, u.text, u.created_at, i.read_at is not null`)).
+		WithArgs("2", false, int64(9), 3).
+		WillReturnRows(sqlmock.NewRows([]string{"inbox_id", "update_id", "email", "text", "created_at", "read"}).
+			AddRow("8", "5", "quan12yt@gmail.com", "second", created, false).
+			AddRow("4", "2", "quan12yt@gmail.com", "first", created, true).
+			AddRow("1", "1", "hau@gmail.com", "zero", created, true))
+
+	inbox, err := repo.GetInbox(context.Background(), "2", model.Page{Limit: 2, Cursor: encodeCursor("9")}, false)
+
+	assert.Nil(t, err)
+	assert.Equal(t, model.InboxPage{
+		Items: []model.InboxItem{
+			{Id: "8", Update: model.Update{Id: "5", Sender: "quan12yt@gmail.com", Text: "second", CreatedAt: created}},
+			{Id: "4", Update: model.Update{Id: "2", Sender: "quan12yt@gmail.com", Text: "first", CreatedAt: created}, Read: true},
+		},
+		NextCursor: encodeCursor("4"),
+		Total:      3,
+		Unread:     1,
+	}, inbox)
+}
+
+func TestMarkRead(t *testing.T) {
+	db, mock := DbMock()
+	repo := UpdateRepoImp{Db: db}
+
+	mock.ExpectExec(regexp.QuoteMeta(`update inbox set read_at = now()`)).
+		WithArgs("2", pq.Array([]string{"4", "8"})).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	marked, err := repo.MarkRead(context.Background(), "2", []string{"4", "8"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, marked)
+}
@@ -0,0 +1,28 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"friend-management-v1/model"
+)
+
+// ErrUpdateNotFound is returned by GetUpdate for an unknown update.
+var ErrUpdateNotFound = errors.New("update is not found")
+
+// UpdateRepo stores the updates posted through /api/retrieve and the inbox of
+// every recipient, one item per update delivered to it.
+type UpdateRepo interface {
+	// CreateUpdate stores text posted by sender and adds it to the inbox of
+	// each registered email of recipients.
+	CreateUpdate(ctx context.Context, sender model.User, text string, recipients []string) (model.Update, error)
+	// GetUpdate returns the update id, or ErrUpdateNotFound.
+	GetUpdate(ctx context.Context, id string) (model.Update, error)
+	// GetInbox returns one page of the inbox of recipientId, newest item
+	// first, page.Limit must be positive. With unreadOnly the read items are
+	// left out.
+	GetInbox(ctx context.Context, recipientId string, page model.Page, unreadOnly bool) (model.InboxPage, error)
+	// MarkRead marks the unread items of ids in the inbox of recipientId as
+	// read and returns how many were marked, the ids of other inboxes are
+	// ignored.
+	MarkRead(ctx context.Context, recipientId string, ids []string) (int, error)
+}
@@ -0,0 +1,183 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	"friend-management-v1/model"
+
+	"github.com/lib/pq"
+)
+
+type WebhookRepoImp struct {
+	Db *sql.DB
+}
+
+func NewWebhookRepo(db *sql.DB) WebhookRepo {
+	return &WebhookRepoImp{
+		Db: db,
+	}
+}
+
+func (repo *WebhookRepoImp) SetWebhook(ctx context.Context, emailId string, url string) error {
+	sql_query := `insert into webhook (email_id, url)
+	values ($1, $2)
+	on conflict (email_id) do update set url = excluded.url`
+	args := []interface{}{emailId, url}
+	if url == "" {
+		sql_query = `delete from webhook where email_id = $1`
+		args = args[:1]
+	}
+	if _, err := repo.Db.ExecContext(ctx, sql_query, args...); err != nil {
+		return dbError(ctx, err)
+	}
+	return nil
+}
+
+func (repo *WebhookRepoImp) GetWebhooks(ctx context.Context, emails []string) ([]model.Webhook, error) {
+	sql_query := `select e.email_id, e.email, w.url
+	from email e
+	join webhook w on w.email_id = e.email_id
+	where e.email = any($1)
+	order by e.email_id`
+
+	rows, err := repo.Db.QueryContext(ctx, sql_query, pq.Array(emails))
+	if err != nil {
+		return nil, dbError(ctx, err)
+	}
+	defer rows.Close()
+	webhooks := []model.Webhook{}
+	for rows.Next() {
+		var webhook model.Webhook
+		if err := rows.Scan(&webhook.EmailId, &webhook.Email, &webhook.Url); err != nil {
+			return nil, dbError(ctx, err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError(ctx, err)
+	}
+	return webhooks, nil
+}
+
+// CreateDeliveries stores the deliveries in one transaction.
+func (repo *WebhookRepoImp) CreateDeliveries(ctx context.Context, updateId string, webhooks []model.Webhook) ([]model.Delivery, error) {
+	sql_query := `insert into webhook_delivery (update_id, recipient_id, url, status)
+	values ($1, $2, $3, $4)
+	returning delivery_id`
+
+	tx, err := repo.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, dbError(ctx, err)
+	}
+	deliveries := make([]model.Delivery, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		delivery := model.Delivery{
+			UpdateId:  updateId,
+			Recipient: webhook.Email,
+			Url:       webhook.Url,
+			Status:    model.DeliveryPending,
+			Attempts:  []model.DeliveryAttempt{},
+		}
+		err := tx.QueryRowContext(ctx, sql_query, updateId, webhook.EmailId, webhook.Url, delivery.Status).Scan(&delivery.Id)
+		if err != nil {
+			tx.Rollback()
+			return nil, dbError(ctx, err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, dbError(ctx, err)
+	}
+	return deliveries, nil
+}
+
+// AddAttempt records the attempt and sets the status in one transaction.
+func (repo *WebhookRepoImp) AddAttempt(ctx context.Context, deliveryId string, attempt model.DeliveryAttempt, status model.DeliveryStatus) error {
+	attempt_query := `insert into webhook_attempt (delivery_id, attempt, status_code, error, attempted_at)
+	values ($1, $2, $3, $4, $5)`
+	status_query := `update webhook_delivery set status = $2 where delivery_id = $1`
+
+	tx, err := repo.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return dbError(ctx, err)
+	}
+	_, err = tx.ExecContext(ctx, attempt_query, deliveryId, attempt.Attempt, attempt.StatusCode, attempt.Error, attempt.AttemptedAt)
+	if err != nil {
+		tx.Rollback()
+		return dbError(ctx, err)
+	}
+	if _, err := tx.ExecContext(ctx, status_query, deliveryId, status); err != nil {
+		tx.Rollback()
+		return dbError(ctx, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return dbError(ctx, err)
+	}
+	return nil
+}
+
+func (repo *WebhookRepoImp) GetDeliveries(ctx context.Context, status model.DeliveryStatus, page model.Page) (model.DeliveryPage, error) {
+	after, err := decodeCursor(page.Cursor)
+	if err != nil {
+		return model.DeliveryPage{}, err
+	}
+	page_query := `select d.delivery_id, d.update_id, e.email, d.url, d.status
+	from webhook_delivery d
+	join email e on e.email_id = d.recipient_id
+	where d.status = $1 and d.delivery_id > $2
+	order by d.delivery_id
+	limit $3`
+	attempt_query := `select a.delivery_id, a.attempt, a.status_code, a.error, a.attempted_at
+	from webhook_attempt a
+	where a.delivery_id = any($1::int8[])
+	order by a.delivery_id, a.attempt`
+
+	result := model.DeliveryPage{Deliveries: []model.Delivery{}}
+	rows, err := repo.Db.QueryContext(ctx, page_query, status, after, page.Limit+1)
+	if err != nil {
+		return model.DeliveryPage{}, dbError(ctx, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		delivery := model.Delivery{Attempts: []model.DeliveryAttempt{}}
+		if err := rows.Scan(&delivery.Id, &delivery.UpdateId, &delivery.Recipient, &delivery.Url, &delivery.Status); err != nil {
+			return model.DeliveryPage{}, dbError(ctx, err)
+		}
+		if len(result.Deliveries) == page.Limit {
+			result.NextCursor = encodeCursor(result.Deliveries[len(result.Deliveries)-1].Id)
+			break
+		}
+		result.Deliveries = append(result.Deliveries, delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return model.DeliveryPage{}, dbError(ctx, err)
+	}
+	if len(result.Deliveries) == 0 {
+		return result, nil
+	}
+
+	ids := make([]string, len(result.Deliveries))
+	index := make(map[string]int, len(result.Deliveries))
+	for i, delivery := range result.Deliveries {
+		ids[i] = delivery.Id
+		index[delivery.Id] = i
+	}
+	attempts, err := repo.Db.QueryContext(ctx, attempt_query, pq.Array(ids))
+	if err != nil {
+		return model.DeliveryPage{}, dbError(ctx, err)
+	}
+	defer attempts.Close()
+	for attempts.Next() {
+		var id string
+		var attempt model.DeliveryAttempt
+		if err := attempts.Scan(&id, &attempt.Attempt, &attempt.StatusCode, &attempt.Error, &attempt.AttemptedAt); err != nil {
+			return model.DeliveryPage{}, dbError(ctx, err)
+		}
+		delivery := &result.Deliveries[index[id]]
+		delivery.Attempts = append(delivery.Attempts, attempt)
+	}
+	if err := attempts.Err(); err != nil {
+		return model.DeliveryPage{}, dbError(ctx, err)
+	}
+	return result, nil
+}
@@ -0,0 +1,139 @@
+package repos
+
+import (
+	"context"
+	"friend-management-v1/model"
+	"strconv"
+	"sync"
+)
+
+// WebhookRepoMemory keeps webhooks and deliveries in memory beside the emails
+// of a RelationRepoMemory. It is safe for concurrent use.
+type WebhookRepoMemory struct {
+	mu     sync.RWMutex
+	emails *RelationRepoMemory
+	// urls maps the id of an email to its webhook.
+	urls map[string]string
+	// deliveries holds every delivery, the id of a delivery is its index
+	// plus one.
+	deliveries []model.Delivery
+}
+
+func NewWebhookRepoMemory(emails *RelationRepoMemory) *WebhookRepoMemory {
+	return &WebhookRepoMemory{
+		emails: emails,
+		urls:   make(map[string]string),
+	}
+}
+
+func (repo *WebhookRepoMemory) SetWebhook(ctx context.Context, emailId string, url string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if url == "" {
+		delete(repo.urls, emailId)
+	} else {
+		repo.urls[emailId] = url
+	}
+	return nil
+}
+
+func (repo *WebhookRepoMemory) GetWebhooks(ctx context.Context, emails []string) ([]model.Webhook, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	webhooks := []model.Webhook{}
+	ids := repo.idsOf(emails)
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	for i, id := range ids {
+		if url, ok := repo.urls[id]; ok {
+			webhooks = append(webhooks, model.Webhook{EmailId: id, Email: emails[i], Url: url})
+		}
+	}
+	return webhooks, nil
+}
+
+// idsOf returns the id of each email of emails, or "" for an email that is not
+// registered.
+func (repo *WebhookRepoMemory) idsOf(emails []string) []string {
+	defer repo.emails.rlock()()
+
+	ids := make([]string, len(emails))
+	for i, email := range emails {
+		ids[i] = repo.emails.ids[email]
+	}
+	return ids
+}
+
+func (repo *WebhookRepoMemory) CreateDeliveries(ctx context.Context, updateId string, webhooks []model.Webhook) ([]model.Delivery, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	deliveries := make([]model.Delivery, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		delivery := model.Delivery{
+			Id:        strconv.Itoa(len(repo.deliveries) + 1),
+			UpdateId:  updateId,
+			Recipient: webhook.Email,
+			Url:       webhook.Url,
+			Status:    model.DeliveryPending,
+			Attempts:  []model.DeliveryAttempt{},
+		}
+		repo.deliveries = append(repo.deliveries, delivery)
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}
+
+func (repo *WebhookRepoMemory) AddAttempt(ctx context.Context, deliveryId string, attempt model.DeliveryAttempt, status model.DeliveryStatus) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	i, err := strconv.Atoi(deliveryId)
+	if err != nil || i < 1 || i > len(repo.deliveries) {
+		return nil
+	}
+	delivery := &repo.deliveries[i-1]
+	// append to a copy, the attempts of a returned delivery must not change
+	attempts := make([]model.DeliveryAttempt, len(delivery.Attempts), len(delivery.Attempts)+1)
+	copy(attempts, delivery.Attempts)
+	delivery.Attempts = append(attempts, attempt)
+	delivery.Status = status
+	return nil
+}
+
+func (repo *WebhookRepoMemory) GetDeliveries(ctx context.Context, status model.DeliveryStatus, page model.Page) (model.DeliveryPage, error) {
+	if err := ctx.Err(); err != nil {
+		return model.DeliveryPage{}, err
+	}
+	after, err := decodeCursor(page.Cursor)
+	if err != nil {
+		return model.DeliveryPage{}, err
+	}
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	result := model.DeliveryPage{Deliveries: []model.Delivery{}}
+	for i := int(after); i < len(repo.deliveries); i++ {
+		delivery := repo.deliveries[i]
+		if delivery.Status != status {
+			continue
+		}
+		if len(result.Deliveries) == page.Limit {
+			result.NextCursor = encodeCursor(result.Deliveries[len(result.Deliveries)-1].Id)
+			break
+		}
+		result.Deliveries = append(result.Deliveries, delivery)
+	}
+	return result, nil
+}
@@ -0,0 +1,50 @@
+package repos
+
+import (
+	"context"
+	"friend-management-v1/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryWebhooks(t *testing.T) {
+	ctx := context.Background()
+	emails := NewRelationRepoMemory()
+	quang := emails.AddEmail("quang@gmail.com")
+	hau := emails.AddEmail("hau@gmail.com")
+	emails.AddEmail("quan12yt@gmail.com")
+	repo := NewWebhookRepoMemory(emails)
+
+	assert.Nil(t, repo.SetWebhook(ctx, quang, "http://localhost/old"))
+	assert.Nil(t, repo.SetWebhook(ctx, quang, "http://localhost/quang"))
+	assert.Nil(t, repo.SetWebhook(ctx, hau, "http://localhost/hau"))
+	assert.Nil(t, repo.SetWebhook(ctx, hau, ""))
+
+	webhooks, err := repo.GetWebhooks(ctx, []string{"quang@gmail.com", "hau@gmail.com", "quan12yt@gmail.com", "unknown@gmail.com"})
+	assert.Nil(t, err)
+	assert.Equal(t, []model.Webhook{{EmailId: quang, Email: "quang@gmail.com", Url: "http://localhost/quang"}}, webhooks)
+
+	deliveries, err := repo.CreateDeliveries(ctx, "1", append(webhooks, webhooks...))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1", "2"}, []string{deliveries[0].Id, deliveries[1].Id})
+	assert.Equal(t, model.DeliveryPending, deliveries[0].Status)
+
+	at := time.Date(2021, 5, 6, 14, 20, 59, 0, time.UTC)
+	assert.Nil(t, repo.AddAttempt(ctx, "1", model.DeliveryAttempt{Attempt: 1, StatusCode: 500, AttemptedAt: at}, model.DeliveryPending))
+	assert.Nil(t, repo.AddAttempt(ctx, "1", model.DeliveryAttempt{Attempt: 2, StatusCode: 500, AttemptedAt: at}, model.DeliveryDead))
+	assert.Nil(t, repo.AddAttempt(ctx, "2", model.DeliveryAttempt{Attempt: 1, StatusCode: 200, AttemptedAt: at}, model.DeliveryDelivered))
+
+	page, err := repo.GetDeliveries(ctx, model.DeliveryDead, model.Page{Limit: 10})
+	assert.Nil(t, err)
+	assert.Len(t, page.Deliveries, 1)
+	assert.Equal(t, "1", page.Deliveries[0].Id)
+	assert.Len(t, page.Deliveries[0].Attempts, 2)
+	assert.Empty(t, page.NextCursor)
+	assert.Empty(t, deliveries[0].Attempts)
+
+	page, err = repo.GetDeliveries(ctx, model.DeliveryPending, model.Page{Limit: 10})
+	assert.Nil(t, err)
+	assert.Empty(t, page.Deliveries)
+}
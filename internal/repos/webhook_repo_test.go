@@ -0,0 +1,107 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"friend-management-v1/model"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetWebhook(t *testing.T) {
+	db, mock := DbMock()
+	repo := WebhookRepoImp{Db: db}
+
+	mock.ExpectExec(regexp.QuoteMeta(`insert into webhook (email_id, url)`)).
+		WithArgs("1", "http://localhost/hook").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`delete from webhook where email_id = $1`)).
+		WithArgs("1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.Nil(t, repo.SetWebhook(context.Background(), "1", "http://localhost/hook"))
+	assert.Nil(t, repo.SetWebhook(context.Background(), "1", ""))
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateDeliveries(t *testing.T) {
+	db, mock := DbMock()
+	repo := WebhookRepoImp{Db: db}
+	webhooks := []model.Webhook{
+		{EmailId: "2", Email: "quang@gmail.com", Url: "http://localhost/quang"},
+		{EmailId: "3", Email: "hau@gmail.com", Url: "http://localhost/hau"},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`insert into webhook_delivery (update_id, recipient_id, url, status)`)).
+		WithArgs("7", "2", "http://localhost/quang", model.DeliveryPending).
+		WillReturnRows(sqlmock.NewRows([]string{"delivery_id"}).AddRow("10"))
+	mock.ExpectQuery(regexp.QuoteMeta(`insert into webhook_delivery (update_id, recipient_id, url, status)`)).
+		WithArgs("7", "3", "http://localhost/hau", model.DeliveryPending).
+		WillReturnError(errors.New("insert failed"))
+	mock.ExpectRollback()
+
+	_, err := repo.CreateDeliveries(context.Background(), "7", webhooks)
+
+	assert.Equal(t, errors.New("insert failed"), err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestAddAttempt(t *testing.T) {
+	db, mock := DbMock()
+	repo := WebhookRepoImp{Db: db}
+	at := time.Date(2021, 5, 6, 14, 20, 59, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`insert into webhook_attempt (delivery_id, attempt, status_code, error, attempted_at)`)).
+		WithArgs("10", 3, 500, "", at).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`update webhook_delivery set status = $2 where delivery_id = $1`)).
+		WithArgs("10", model.DeliveryDead).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := repo.AddAttempt(context.Background(), "10", model.DeliveryAttempt{Attempt: 3, StatusCode: 500, AttemptedAt: at}, model.DeliveryDead)
+
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestGetDeliveries(t *testing.T) {
+	db, mock := DbMock()
+	repo := WebhookRepoImp{Db: db}
+	at := time.Date(2021, 5, 6, 14, 20, 59, 0, time.UTC)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`select d.delivery_id, d.update_id, e.email, d.url, d.status`)).
+		WithArgs(model.DeliveryDead, int64(0), 2).
+		WillReturnRows(sqlmock.NewRows([]string{"delivery_id", "update_id", "email", "url", "status"}).
+			AddRow("10", "7", "quang@gmail.com", "http://localhost/quang", "DEAD").
+			AddRow("12", "8", "hau@gmail.com", "http://localhost/hau", "DEAD"))
+	mock.ExpectQuery(regexp.QuoteMeta(`select a.delivery_id, a.attempt, a.status_code, a.error, a.attempted_at`)).
+		WithArgs(pq.Array([]string{"10"})).
+		WillReturnRows(sqlmock.NewRows([]string{"delivery_id", "attempt", "status_code", "error", "attempted_at"}).
+			AddRow("10", 1, 0, "connection refused", at).
+			AddRow("10", 2, 503, "", at))
+
+	page, err := repo.GetDeliveries(context.Background(), model.DeliveryDead, model.Page{Limit: 1})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []model.Delivery{{
+		Id:        "10",
+		UpdateId:  "7",
+		Recipient: "quang@gmail.com",
+		Url:       "http://localhost/quang",
+		Status:    model.DeliveryDead,
+		Attempts: []model.DeliveryAttempt{
+			{Attempt: 1, Error: "connection refused", AttemptedAt: at},
+			{Attempt: 2, StatusCode: 503, AttemptedAt: at},
+		},
+	}}, page.Deliveries)
+	assert.Equal(t, encodeCursor("10"), page.NextCursor)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,26 @@
+package repos
+
+import (
+	"context"
+	"friend-management-v1/model"
+)
+
+// WebhookRepo stores the webhook URL of every email and the deliveries of the
+// updates posted to them, with every attempt made.
+type WebhookRepo interface {
+	// SetWebhook registers url as the webhook of emailId, replacing the
+	// previous one. An empty url removes the webhook.
+	SetWebhook(ctx context.Context, emailId string, url string) error
+	// GetWebhooks returns the webhooks of the registered emails of emails
+	// that have one.
+	GetWebhooks(ctx context.Context, emails []string) ([]model.Webhook, error)
+	// CreateDeliveries stores a PENDING delivery of updateId to each of
+	// webhooks and returns them in the same order.
+	CreateDeliveries(ctx context.Context, updateId string, webhooks []model.Webhook) ([]model.Delivery, error)
+	// AddAttempt records an attempt of deliveryId and sets the status of the
+	// delivery to status.
+	AddAttempt(ctx context.Context, deliveryId string, attempt model.DeliveryAttempt, status model.DeliveryStatus) error
+	// GetDeliveries returns one page of the deliveries in status with their
+	// attempts, oldest first, page.Limit must be positive.
+	GetDeliveries(ctx context.Context, status model.DeliveryStatus, page model.Page) (model.DeliveryPage, error)
+}
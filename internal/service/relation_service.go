@@ -1,14 +1,35 @@
 package service
 
 import (
+	"context"
 	"friend-management-v1/model"
 )
 
 type RelationService interface {
-	GetFriendsEmail(rq model.GetFriendsRequest) ([]string, error)
-	Addfriend(rq model.AddAndGetCommonRequest) (bool, error)
-	GetCommonFriends(rq model.AddAndGetCommonRequest) ([]string, error)
-	SubcribeToEmail(rq model.SubcribeAndBlockRequest) (bool, error)
-	BlockEmail(rq model.SubcribeAndBlockRequest) (bool, error)
-	RetrieveContactEmail(rq model.RetrieveRequest) ([]string, error)
+	GetFriendsEmail(ctx context.Context, rq model.GetFriendsRequest) (model.EmailPage, error)
+	Addfriend(ctx context.Context, rq model.AddAndGetCommonRequest) (bool, error)
+	Unfriend(ctx context.Context, rq model.AddAndGetCommonRequest) (bool, error)
+	GetCommonFriends(ctx context.Context, rq model.AddAndGetCommonRequest) (model.EmailPage, error)
+	SubcribeToEmail(ctx context.Context, rq model.SubcribeAndBlockRequest) (bool, error)
+	UnsubcribeFromEmail(ctx context.Context, rq model.SubcribeAndBlockRequest) (bool, error)
+	BlockEmail(ctx context.Context, rq model.SubcribeAndBlockRequest) (bool, error)
+	UnblockEmail(ctx context.Context, rq model.SubcribeAndBlockRequest) ([]model.RelationStatus, error)
+	RetrieveContactEmail(ctx context.Context, rq model.RetrieveRequest) (model.RecipientsPage, error)
+	GetSuggestions(ctx context.Context, rq model.SuggestionsRequest) ([]model.Suggestion, error)
+	// GetPath returns the emails of the shortest chain of friends from
+	// rq.From to rq.To, or nil when there is none within the maximum depth.
+	GetPath(ctx context.Context, rq model.PathRequest) ([]string, error)
+	GetInbox(ctx context.Context, rq model.InboxRequest) (model.InboxPage, error)
+	// MarkRead returns how many unread items of rq.Ids were marked as read.
+	MarkRead(ctx context.Context, rq model.MarkReadRequest) (int, error)
+	// RegisterWebhook sets or, with an empty rq.Url, removes the webhook of
+	// rq.Email.
+	RegisterWebhook(ctx context.Context, rq model.WebhookRequest) error
+	GetDeliveries(ctx context.Context, rq model.DeliveriesRequest) (model.DeliveryPage, error)
+	// GetAuditRecords returns one page of the relation changes made by or
+	// toward rq.Email between rq.From and rq.To.
+	GetAuditRecords(ctx context.Context, rq model.AuditRequest) (model.AuditPage, error)
+	RegisterEmail(ctx context.Context, rq model.UserRequest) (model.User, error)
+	GetUser(ctx context.Context, rq model.UserRequest) (model.User, error)
+	GetUsers(ctx context.Context) ([]model.User, error)
 }
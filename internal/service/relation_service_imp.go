@@ -1,33 +1,192 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"friend-management-v1/internal/auth"
+	"friend-management-v1/internal/event"
 	"friend-management-v1/internal/repos"
 	"friend-management-v1/internal/utils"
 	"friend-management-v1/model"
+	"time"
 )
 
+// RelationServiceImp applies one block semantic to every method: when either
+// email has blocked the other they can not become friends, and the blocked
+// email is hidden from the blocker's friend lists and never delivers updates
+// to it. Relations that existed before the block are kept and come back on
+// unblock. Every write checks and changes the relations of the two emails in
+// one transaction holding both emails, so concurrent writes can not race.
+// When the context carries an auth.Principal, the email a request acts for,
+// its requestor, sender, email or first friend, must be the principal's email
+// unless the principal is an admin, and only an admin may list the deliveries.
 type RelationServiceImp struct {
-	repo repos.RelationRepo
+	repo          repos.RelationRepo
+	timeout       time.Duration
+	perOperation  map[string]time.Duration
+	autoProvision bool
+	maxPathDepth  int
+	updates       repos.UpdateRepo
+	webhooks      repos.WebhookRepo
+	checkURL      func(url string) error
+	events        event.Publisher
 }
 
-func NewRelationService(rp repos.RelationRepo) RelationService {
-	return &RelationServiceImp{
-		repo: rp,
+// Option configures a RelationServiceImp.
+type Option func(s *RelationServiceImp)
+
+// WithTimeouts bounds every call with timeout, or with the entry of
+// perOperation named after the method, such as "Addfriend". A zero timeout
+// leaves the caller's context as it is.
+func WithTimeouts(timeout time.Duration, perOperation map[string]time.Duration) Option {
+	return func(s *RelationServiceImp) {
+		s.timeout = timeout
+		s.perOperation = perOperation
+	}
+}
+
+// WithAutoProvision lets Addfriend and SubcribeToEmail register the emails
+// they do not know instead of failing, along with the relation they add.
+func WithAutoProvision(enabled bool) Option {
+	return func(s *RelationServiceImp) {
+		s.autoProvision = enabled
+	}
+}
+
+// DefaultMaxPathDepth is the path depth used without WithMaxPathDepth.
+const DefaultMaxPathDepth = 6
+
+// WithMaxPathDepth sets the longest chain of friends GetPath looks through,
+// DefaultMaxPathDepth by default.
+func WithMaxPathDepth(depth int) Option {
+	return func(s *RelationServiceImp) {
+		s.maxPathDepth = depth
+	}
+}
+
+// WithUpdateRepo stores the posted updates and the inboxes in updates. Without
+// it posting an update and reading an inbox fail with ErrUpdatesNotStored.
+func WithUpdateRepo(updates repos.UpdateRepo) Option {
+	return func(s *RelationServiceImp) {
+		s.updates = updates
+	}
+}
+
+// WithEventBus publishes the FriendAdded, Subscribed, Blocked and
+// UpdatePosted events to events once the change is stored.
+func WithEventBus(events event.Publisher) Option {
+	return func(s *RelationServiceImp) {
+		s.events = events
+	}
+}
+
+// WithWebhookRepo registers the webhooks and reads the deliveries in webhooks.
+// Without it both fail with ErrWebhooksDisabled.
+func WithWebhookRepo(webhooks repos.WebhookRepo) Option {
+	return func(s *RelationServiceImp) {
+		s.webhooks = webhooks
+	}
+}
+
+// WithWebhookURLCheck rejects the webhook URLs for which check returns an
+// error, such as the ones pointing to internal services.
+func WithWebhookURLCheck(check func(url string) error) Option {
+	return func(s *RelationServiceImp) {
+		s.checkURL = check
+	}
+}
+
+// ErrUpdatesNotStored is returned by the update operations of a service built
+// without WithUpdateRepo.
+var ErrUpdatesNotStored = errors.New("updates are not stored by this server")
+
+// ErrWebhooksDisabled is returned by the webhook operations of a service built
+// without WithWebhookRepo.
+var ErrWebhooksDisabled = errors.New("webhooks are not enabled on this server")
+
+func NewRelationService(rp repos.RelationRepo, opts ...Option) RelationService {
+	s := &RelationServiceImp{
+		repo:         rp,
+		maxPathDepth: DefaultMaxPathDepth,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
-func (s *RelationServiceImp) GetFriendsEmail(rq model.GetFriendsRequest) ([]string, error) {
-	ids, err := s.repo.GetIdFromEmail(rq.Email)
+// withTimeout returns ctx bounded by the timeout configured for operation.
+func (s *RelationServiceImp) withTimeout(ctx context.Context, operation string) (context.Context, context.CancelFunc) {
+	timeout := s.timeout
+	if d, ok := s.perOperation[operation]; ok {
+		timeout = d
+	}
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+func (s *RelationServiceImp) GetFriendsEmail(ctx context.Context, rq model.GetFriendsRequest) (model.EmailPage, error) {
+	ctx, cancel := s.withTimeout(ctx, "GetFriendsEmail")
+	defer cancel()
+	ids, err := s.repo.GetIdFromEmail(ctx, rq.Email)
 	if err != nil {
-		return nil, err
+		return model.EmailPage{}, err
+	}
+	return s.repo.GetEmailByStatus(ctx, ids, model.StatusFriend, pageOf(rq.Limit, rq.Cursor))
+}
+
+func (s *RelationServiceImp) Addfriend(ctx context.Context, rq model.AddAndGetCommonRequest) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx, "Addfriend")
+	defer cancel()
+	if err := auth.Authorize(ctx, rq.Friends[0]); err != nil {
+		return false, err
+	}
+	err := s.provisioning(ctx, func(tx repos.RelationRepo) error {
+		id1, id2, err := s.idsOf(ctx, tx, rq.Friends[0], rq.Friends[1])
+		if err != nil {
+			return err
+		}
+		ids := []string{id1, id2}
+		if err := tx.LockEmails(ctx, ids); err != nil {
+			return err
+		}
+		blocked, err := isBlocked(ctx, tx, id1, id2)
+		if err != nil {
+			return err
+		}
+		if blocked {
+			return errors.New("2 emails are blocked, cannot be friend")
+		}
+		exist, err := tx.CheckIfExist(ctx, id1, id2, model.StatusFriend)
+		if err != nil {
+			return err
+		}
+		if exist {
+			return errors.New("2 emails are already being friend")
+		}
+		_, err = tx.AddRelation(ctx, ids, model.StatusFriend)
+		if err == repos.ErrDuplicate {
+			return errors.New("2 emails are already being friend")
+		}
+		return err
+	})
+	if err != nil {
+		return false, err
 	}
-	return s.repo.GetEmailByStatus(ids, "FRIEND")
+	s.publish(ctx, event.FriendAdded{Actor: rq.Friends[0], Target: rq.Friends[1]})
+	return true, nil
 }
 
-func (s *RelationServiceImp) Addfriend(rq model.AddAndGetCommonRequest) (bool, error) {
-	id1, err1 := s.repo.GetIdFromEmail(rq.Friends[0])
-	id2, err2 := s.repo.GetIdFromEmail(rq.Friends[1])
+func (s *RelationServiceImp) Unfriend(ctx context.Context, rq model.AddAndGetCommonRequest) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx, "Unfriend")
+	defer cancel()
+	if err := auth.Authorize(ctx, rq.Friends[0]); err != nil {
+		return false, err
+	}
+	id1, err1 := s.repo.GetIdFromEmail(ctx, rq.Friends[0])
+	id2, err2 := s.repo.GetIdFromEmail(ctx, rq.Friends[1])
 
 	if err1 != nil {
 		return false, err1
@@ -36,46 +195,104 @@ func (s *RelationServiceImp) Addfriend(rq model.AddAndGetCommonRequest) (bool, e
 		return false, err2
 	}
 
-	if re := s.repo.CheckIfExist(id1, id2, "FRIEND"); re {
-		return false, errors.New("2 emails are already being friend")
-	}
 	ids := make([]string, 2)
 	ids[0] = id1
 	ids[1] = id2
-	_, err := s.repo.AddRelation(ids, "FRIEND")
+	err := s.repo.Transaction(ctx, func(tx repos.RelationRepo) error {
+		if err := tx.LockEmails(ctx, ids); err != nil {
+			return err
+		}
+		exist, err := tx.CheckIfExist(ctx, id1, id2, model.StatusFriend)
+		if err != nil {
+			return err
+		}
+		if !exist {
+			return errors.New("2 emails are not friends")
+		}
+		_, err = tx.RemoveRelation(ctx, ids, model.StatusFriend)
+		return err
+	})
 	if err != nil {
 		return false, err
 	}
 	return true, nil
 }
 
-func (s *RelationServiceImp) GetCommonFriends(rq model.AddAndGetCommonRequest) ([]string, error) {
-	id1, err1 := s.repo.GetIdFromEmail(rq.Friends[0])
-	id2, err2 := s.repo.GetIdFromEmail(rq.Friends[1])
-
-	if err1 != nil {
-		return nil, err1
-	}
-	if err2 != nil {
-		return nil, err2
+// GetCommonFriends returns the friends every email of rq.Friends has, an email
+// given twice counts once.
+func (s *RelationServiceImp) GetCommonFriends(ctx context.Context, rq model.AddAndGetCommonRequest) (model.EmailPage, error) {
+	ctx, cancel := s.withTimeout(ctx, "GetCommonFriends")
+	defer cancel()
+	var ids []string
+	for _, email := range utils.Unique(rq.Friends) {
+		id, err := s.repo.GetIdFromEmail(ctx, email)
+		if err != nil {
+			return model.EmailPage{}, err
+		}
+		ids = append(ids, id)
 	}
 
-	slice1, err1 := s.repo.GetEmailByStatus(id1, "FRIEND")
-	slice2, err2 := s.repo.GetEmailByStatus(id2, "FRIEND")
-	if err1 != nil {
-		return nil, err1
+	return s.repo.GetCommonEmails(ctx, ids, pageOf(rq.Limit, rq.Cursor))
+}
+
+func (s *RelationServiceImp) SubcribeToEmail(ctx context.Context, rq model.SubcribeAndBlockRequest) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx, "SubcribeToEmail")
+	defer cancel()
+	if err := auth.Authorize(ctx, rq.Requestor); err != nil {
+		return false, err
 	}
-	if err2 != nil {
-		return nil, err2
+	var result bool
+	err := s.provisioning(ctx, func(tx repos.RelationRepo) error {
+		id1, id2, err := s.idsOf(ctx, tx, rq.Requestor, rq.Target)
+		if err != nil {
+			return err
+		}
+		ids := []string{id1, id2}
+		if err := tx.LockEmails(ctx, ids); err != nil {
+			return err
+		}
+		blocked, err := isBlocked(ctx, tx, id1, id2)
+		if err != nil {
+			return err
+		}
+		if blocked {
+			return errors.New("target email has been blocked")
+		}
+		friend, err := tx.CheckIfExist(ctx, id1, id2, model.StatusFriend)
+		if err != nil {
+			return err
+		}
+		if friend {
+			return errors.New("already being friend to the target email, no need to subcribe")
+		}
+		subcribed, err := tx.CheckIfExist(ctx, id1, id2, model.StatusSubcribe)
+		if err != nil {
+			return err
+		}
+		if subcribed {
+			return errors.New("already subcribe to the target email")
+		}
+		result, err = tx.AddDirectedRelation(ctx, ids, model.StatusSubcribe)
+		if err == repos.ErrDuplicate {
+			return errors.New("already subcribe to the target email")
+		}
+		return err
+	})
+	if err != nil {
+		return false, err
 	}
-	result := utils.RetainSlices(slice1, slice2)
+	s.publish(ctx, event.Subscribed{Requestor: rq.Requestor, Target: rq.Target})
 	return result, nil
 }
 
-func (s *RelationServiceImp) SubcribeToEmail(rq model.SubcribeAndBlockRequest) (bool, error) {
-
-	id1, err1 := s.repo.GetIdFromEmail(rq.Requestor)
-	id2, err2 := s.repo.GetIdFromEmail(rq.Target)
+func (s *RelationServiceImp) UnsubcribeFromEmail(ctx context.Context, rq model.SubcribeAndBlockRequest) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx, "UnsubcribeFromEmail")
+	defer cancel()
+	if err := auth.Authorize(ctx, rq.Requestor); err != nil {
+		return false, err
+	}
+	id1, err1 := s.repo.GetIdFromEmail(ctx, rq.Requestor)
+	id2, err2 := s.repo.GetIdFromEmail(ctx, rq.Target)
 
 	if err1 != nil {
 		return false, err1
@@ -84,64 +301,475 @@ func (s *RelationServiceImp) SubcribeToEmail(rq model.SubcribeAndBlockRequest) (
 		return false, err2
 	}
 
-	if re := s.repo.CheckIfExist(id1, id2, "BLOCK"); re {
-		return false, errors.New("target email has been blocked")
+	ids := make([]string, 2)
+	ids[0] = id1
+	ids[1] = id2
+	var result bool
+	err := s.repo.Transaction(ctx, func(tx repos.RelationRepo) error {
+		if err := tx.LockEmails(ctx, ids); err != nil {
+			return err
+		}
+		subcribed, err := tx.CheckIfExist(ctx, id1, id2, model.StatusSubcribe)
+		if err != nil {
+			return err
+		}
+		if !subcribed {
+			return errors.New("not subcribe to the target email")
+		}
+		result, err = tx.RemoveDirectedRelation(ctx, ids, model.StatusSubcribe)
+		return err
+	})
+	return result, err
+}
+
+func (s *RelationServiceImp) BlockEmail(ctx context.Context, rq model.SubcribeAndBlockRequest) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx, "BlockEmail")
+	defer cancel()
+	if err := auth.Authorize(ctx, rq.Requestor); err != nil {
+		return false, err
 	}
-	if re := s.repo.CheckIfExist(id1, id2, "FRIEND"); re {
-		return false, errors.New("already being friend to the target email, no need to subcribe")
+	id1, err1 := s.repo.GetIdFromEmail(ctx, rq.Requestor)
+	id2, err2 := s.repo.GetIdFromEmail(ctx, rq.Target)
+
+	if err1 != nil {
+		return false, err1
 	}
-	if re := s.repo.CheckIfExist(id1, id2, "SUBCRIBE"); re {
-		return false, errors.New("already subcribe to the target email")
+	if err2 != nil {
+		return false, err2
 	}
 
 	ids := make([]string, 2)
 	ids[0] = id1
 	ids[1] = id2
-	result, err := s.repo.AddRelation(ids, "SUBCRIBE")
+	var result bool
+	err := s.repo.Transaction(ctx, func(tx repos.RelationRepo) error {
+		if err := tx.LockEmails(ctx, ids); err != nil {
+			return err
+		}
+		blocked, err := tx.CheckIfExist(ctx, id1, id2, model.StatusBlock)
+		if err != nil {
+			return err
+		}
+		if blocked {
+			return errors.New("target email has already being blocked")
+		}
+		result, err = tx.AddDirectedRelation(ctx, ids, model.StatusBlock)
+		if err == repos.ErrDuplicate {
+			return errors.New("target email has already being blocked")
+		}
+		return err
+	})
 	if err != nil {
-		return result, err
+		return false, err
 	}
+	s.publish(ctx, event.Blocked{Requestor: rq.Requestor, Target: rq.Target})
 	return result, nil
 }
 
-func (s *RelationServiceImp) BlockEmail(rq model.SubcribeAndBlockRequest) (bool, error) {
-
-	id1, err1 := s.repo.GetIdFromEmail(rq.Requestor)
-	id2, err2 := s.repo.GetIdFromEmail(rq.Target)
+// UnblockEmail removes the requestor's BLOCK relation only. Blocking never
+// deletes a friendship or subscription, so whichever of them existed before
+// the block comes back and its status is returned. A friendship stays hidden
+// while the target still blocks the requestor.
+func (s *RelationServiceImp) UnblockEmail(ctx context.Context, rq model.SubcribeAndBlockRequest) ([]model.RelationStatus, error) {
+	ctx, cancel := s.withTimeout(ctx, "UnblockEmail")
+	defer cancel()
+	if err := auth.Authorize(ctx, rq.Requestor); err != nil {
+		return nil, err
+	}
+	id1, err1 := s.repo.GetIdFromEmail(ctx, rq.Requestor)
+	id2, err2 := s.repo.GetIdFromEmail(ctx, rq.Target)
 
 	if err1 != nil {
-		return false, err1
+		return nil, err1
 	}
 	if err2 != nil {
-		return false, err2
+		return nil, err2
 	}
 
-	if re := s.repo.CheckIfExist(id1, id2, "BLOCK"); re {
-		return false, errors.New("target email has already being blocked")
-	}
 	ids := make([]string, 2)
 	ids[0] = id1
 	ids[1] = id2
-	result, err := s.repo.AddRelation(ids, "BLOCK")
+	var restored []model.RelationStatus
+	err := s.repo.Transaction(ctx, func(tx repos.RelationRepo) error {
+		if err := tx.LockEmails(ctx, ids); err != nil {
+			return err
+		}
+		blocked, err := tx.CheckIfExist(ctx, id1, id2, model.StatusBlock)
+		if err != nil {
+			return err
+		}
+		if !blocked {
+			return errors.New("target email has not been blocked")
+		}
+		if _, err := tx.RemoveDirectedRelation(ctx, ids, model.StatusBlock); err != nil {
+			return err
+		}
+
+		restored = []model.RelationStatus{}
+		friend, err := tx.CheckIfExist(ctx, id1, id2, model.StatusFriend)
+		if err != nil {
+			return err
+		}
+		blockedBack, err := tx.CheckIfExist(ctx, id2, id1, model.StatusBlock)
+		if err != nil {
+			return err
+		}
+		if friend && !blockedBack {
+			restored = append(restored, model.StatusFriend)
+		}
+		subcribed, err := tx.CheckIfExist(ctx, id1, id2, model.StatusSubcribe)
+		if err != nil {
+			return err
+		}
+		if subcribed {
+			restored = append(restored, model.StatusSubcribe)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return restored, nil
+}
+
+// pageOf returns the page of a list request, utils.DefaultLimit emails when
+// the request has no limit.
+func pageOf(limit int, cursor string) model.Page {
+	if limit == 0 {
+		limit = utils.DefaultLimit
+	}
+	return model.Page{Limit: limit, Cursor: cursor}
+}
+
+// errRegisteredMeanwhile is returned inside a transaction registering an
+// email that a concurrent request registered first.
+var errRegisteredMeanwhile = errors.New("email registered by a concurrent request")
+
+// provisioning runs fn in a transaction, and once more when an email it
+// registers was registered meanwhile: the insert aborted the transaction on
+// Postgres, the second run finds the email.
+func (s *RelationServiceImp) provisioning(ctx context.Context, fn func(tx repos.RelationRepo) error) error {
+	err := s.repo.Transaction(ctx, fn)
+	if err == errRegisteredMeanwhile {
+		err = s.repo.Transaction(ctx, fn)
+	}
+	return err
+}
+
+// idsOf returns the ids of email1 and email2 in tx. With auto-provisioning an
+// unknown email is registered in tx, so a refused write leaves it
+// unregistered.
+func (s *RelationServiceImp) idsOf(ctx context.Context, tx repos.RelationRepo, email1 string, email2 string) (string, string, error) {
+	id1, err := s.idOf(ctx, tx, email1)
+	if err != nil {
+		return "", "", err
+	}
+	id2, err := s.idOf(ctx, tx, email2)
+	if err != nil {
+		return "", "", err
+	}
+	return id1, id2, nil
+}
+
+func (s *RelationServiceImp) idOf(ctx context.Context, tx repos.RelationRepo, email string) (string, error) {
+	id, err := tx.GetIdFromEmail(ctx, email)
+	var notFound *repos.EmailNotFoundError
+	if !s.autoProvision || !errors.As(err, &notFound) {
+		return id, err
+	}
+	id, err = tx.CreateEmail(ctx, email)
+	if err == repos.ErrDuplicate {
+		return "", errRegisteredMeanwhile
+	}
+	return id, err
+}
+
+// isBlocked reports whether either email has blocked the other.
+func isBlocked(ctx context.Context, repo repos.RelationRepo, id1 string, id2 string) (bool, error) {
+	blocked, err := repo.CheckIfExist(ctx, id1, id2, model.StatusBlock)
+	if err != nil || blocked {
+		return blocked, err
+	}
+	return repo.CheckIfExist(ctx, id2, id1, model.StatusBlock)
+}
+
+// RetrieveContactEmail returns the friends and subscribers of the sender that
+// can receive its update, and the registered emails mentioned in the text that
+// have not blocked the sender. The mentioned emails that are not registered
+// are returned apart. With rq.Post the update is stored and delivered to the
+// inbox of every recipient.
+func (s *RelationServiceImp) RetrieveContactEmail(ctx context.Context, rq model.RetrieveRequest) (model.RecipientsPage, error) {
+	ctx, cancel := s.withTimeout(ctx, "RetrieveContactEmail")
+	defer cancel()
+	if err := auth.Authorize(ctx, rq.Sender); err != nil {
+		return model.RecipientsPage{}, err
+	}
+	if rq.Post && s.updates == nil {
+		return model.RecipientsPage{}, ErrUpdatesNotStored
+	}
+	id, err := s.repo.GetIdFromEmail(ctx, rq.Sender)
+	if err != nil {
+		return model.RecipientsPage{}, err
+	}
+	emails := utils.Unique(utils.GetEmailsFromText(rq.Text))
+
+	page, err := s.repo.GetRetrivableEmails(ctx, id, emails, pageOf(rq.Limit, rq.Cursor))
 	if err != nil {
-		return result, err
+		return model.RecipientsPage{}, err
+	}
+	unresolved := []string{}
+	if len(emails) > 0 {
+		unresolved, err = s.repo.GetUnregisteredEmails(ctx, emails)
+		if err != nil {
+			return model.RecipientsPage{}, err
+		}
+	}
+	result := model.RecipientsPage{EmailPage: page, UnresolvedMentions: unresolved}
+	if !rq.Post {
+		return result, nil
+	}
+
+	recipients := page.Emails
+	if page.NextCursor != "" {
+		// the returned page does not hold every recipient
+		recipients, err = s.allRecipients(ctx, id, emails)
+		if err != nil {
+			return model.RecipientsPage{}, err
+		}
 	}
+	update, err := s.updates.CreateUpdate(ctx, model.User{Id: id, Email: rq.Sender}, rq.Text, recipients)
+	if err != nil {
+		return model.RecipientsPage{}, err
+	}
+	result.UpdateId = update.Id
+	s.publish(ctx, event.UpdatePosted{Update: update, Recipients: recipients})
 	return result, nil
 }
 
-func (s *RelationServiceImp) RetrieveContactEmail(rq model.RetrieveRequest) ([]string, error) {
-	id, err := s.repo.GetIdFromEmail(rq.Sender)
-	emails := utils.GetEmailsFromText(rq.Text)
+// publish hands e to the event bus, if the service has one.
+func (s *RelationServiceImp) publish(ctx context.Context, e event.Event) {
+	if s.events != nil {
+		s.events.Publish(ctx, e)
+	}
+}
+
+// allRecipients pages through the recipients of id with the largest page.
+func (s *RelationServiceImp) allRecipients(ctx context.Context, id string, mentions []string) ([]string, error) {
+	var recipients []string
+	page := model.Page{Limit: utils.MaxLimit}
+	for {
+		result, err := s.repo.GetRetrivableEmails(ctx, id, mentions, page)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, result.Emails...)
+		if result.NextCursor == "" {
+			return recipients, nil
+		}
+		page.Cursor = result.NextCursor
+	}
+}
 
+func (s *RelationServiceImp) GetInbox(ctx context.Context, rq model.InboxRequest) (model.InboxPage, error) {
+	ctx, cancel := s.withTimeout(ctx, "GetInbox")
+	defer cancel()
+	if err := auth.Authorize(ctx, rq.Email); err != nil {
+		return model.InboxPage{}, err
+	}
+	if s.updates == nil {
+		return model.InboxPage{}, ErrUpdatesNotStored
+	}
+	id, err := s.repo.GetIdFromEmail(ctx, rq.Email)
+	if err != nil {
+		return model.InboxPage{}, err
+	}
+	return s.updates.GetInbox(ctx, id, pageOf(rq.Limit, rq.Cursor), rq.UnreadOnly)
+}
+
+func (s *RelationServiceImp) MarkRead(ctx context.Context, rq model.MarkReadRequest) (int, error) {
+	ctx, cancel := s.withTimeout(ctx, "MarkRead")
+	defer cancel()
+	if err := auth.Authorize(ctx, rq.Email); err != nil {
+		return 0, err
+	}
+	if s.updates == nil {
+		return 0, ErrUpdatesNotStored
+	}
+	id, err := s.repo.GetIdFromEmail(ctx, rq.Email)
+	if err != nil {
+		return 0, err
+	}
+	return s.updates.MarkRead(ctx, id, rq.Ids)
+}
+
+// RegisterWebhook sets the webhook of rq.Email to rq.Url, or removes it when
+// rq.Url is empty.
+func (s *RelationServiceImp) RegisterWebhook(ctx context.Context, rq model.WebhookRequest) error {
+	ctx, cancel := s.withTimeout(ctx, "RegisterWebhook")
+	defer cancel()
+	if err := auth.Authorize(ctx, rq.Email); err != nil {
+		return err
+	}
+	if s.webhooks == nil {
+		return ErrWebhooksDisabled
+	}
+	if rq.Url != "" && s.checkURL != nil {
+		if err := s.checkURL(rq.Url); err != nil {
+			return err
+		}
+	}
+	id, err := s.repo.GetIdFromEmail(ctx, rq.Email)
+	if err != nil {
+		return err
+	}
+	return s.webhooks.SetWebhook(ctx, id, rq.Url)
+}
+
+// GetDeliveries returns one page of the deliveries in rq.Status, the dead
+// letters by default.
+func (s *RelationServiceImp) GetDeliveries(ctx context.Context, rq model.DeliveriesRequest) (model.DeliveryPage, error) {
+	ctx, cancel := s.withTimeout(ctx, "GetDeliveries")
+	defer cancel()
+	if err := auth.AuthorizeAdmin(ctx); err != nil {
+		return model.DeliveryPage{}, err
+	}
+	if s.webhooks == nil {
+		return model.DeliveryPage{}, ErrWebhooksDisabled
+	}
+	status := rq.Status
+	if status == "" {
+		status = model.DeliveryDead
+	}
+	return s.webhooks.GetDeliveries(ctx, status, pageOf(rq.Limit, rq.Cursor))
+}
+
+func (s *RelationServiceImp) GetAuditRecords(ctx context.Context, rq model.AuditRequest) (model.AuditPage, error) {
+	ctx, cancel := s.withTimeout(ctx, "GetAuditRecords")
+	defer cancel()
+	if err := auth.Authorize(ctx, rq.Email); err != nil {
+		return model.AuditPage{}, err
+	}
+	id, err := s.repo.GetIdFromEmail(ctx, rq.Email)
+	if err != nil {
+		return model.AuditPage{}, err
+	}
+	return s.repo.GetAuditRecords(ctx, id, rq.From, rq.To, pageOf(rq.Limit, rq.Cursor))
+}
+
+// GetSuggestions returns the people rq.Email may know: friends of its friends,
+// most mutual friends first.
+func (s *RelationServiceImp) GetSuggestions(ctx context.Context, rq model.SuggestionsRequest) ([]model.Suggestion, error) {
+	ctx, cancel := s.withTimeout(ctx, "GetSuggestions")
+	defer cancel()
+	id, err := s.repo.GetIdFromEmail(ctx, rq.Email)
 	if err != nil {
 		return nil, err
 	}
+	return s.repo.GetSuggestions(ctx, id, pageOf(rq.Limit, "").Limit)
+}
 
-	emails2, err := s.repo.GetRetrivableEmails(id)
+// GetPath runs a breadth first search from both ends at once, each round
+// loading the friends of the smaller frontier with one repo call, until the
+// two searches meet or the path would be longer than the maximum depth. Both
+// searches only follow friendships not hidden by a block, and two emails where
+// one has blocked the other have no path.
+func (s *RelationServiceImp) GetPath(ctx context.Context, rq model.PathRequest) ([]string, error) {
+	ctx, cancel := s.withTimeout(ctx, "GetPath")
+	defer cancel()
+	from, err := s.repo.GetIdFromEmail(ctx, rq.From)
 	if err != nil {
 		return nil, err
 	}
-	result := append(emails, emails2...)
+	to, err := s.repo.GetIdFromEmail(ctx, rq.To)
+	if err != nil {
+		return nil, err
+	}
+	if from == to {
+		return []string{rq.From}, nil
+	}
+	blocked, err := isBlocked(ctx, s.repo, from, to)
+	if err != nil || blocked {
+		return nil, err
+	}
+
+	emails := map[string]string{from: rq.From, to: rq.To}
+	// forward and backward map each email reached to the one it was reached
+	// from, the search ends are their own parent.
+	forward := map[string]string{from: from}
+	backward := map[string]string{to: to}
+	forwardFrontier, backwardFrontier := []string{from}, []string{to}
+	for depth := 0; depth < s.maxPathDepth; depth++ {
+		if len(forwardFrontier) == 0 || len(backwardFrontier) == 0 {
+			return nil, nil
+		}
+		frontier, parents, others := &forwardFrontier, forward, backward
+		if len(backwardFrontier) < len(forwardFrontier) {
+			frontier, parents, others = &backwardFrontier, backward, forward
+		}
+		friends, err := s.repo.GetFriendsOf(ctx, *frontier)
+		if err != nil {
+			return nil, err
+		}
+		var next []string
+		for _, id := range *frontier {
+			for _, friend := range friends[id] {
+				if _, ok := parents[friend.Id]; ok {
+					continue
+				}
+				parents[friend.Id] = id
+				emails[friend.Id] = friend.Email
+				if _, ok := others[friend.Id]; ok {
+					return pathThrough(friend.Id, forward, backward, emails), nil
+				}
+				next = append(next, friend.Id)
+			}
+		}
+		*frontier = next
+	}
+	return nil, nil
+}
+
+// pathThrough joins the two halves of a path meeting at middle.
+func pathThrough(middle string, forward map[string]string, backward map[string]string, emails map[string]string) []string {
+	var path []string
+	for id := middle; ; id = forward[id] {
+		path = append([]string{emails[id]}, path...)
+		if forward[id] == id {
+			break
+		}
+	}
+	for id := middle; backward[id] != id; {
+		id = backward[id]
+		path = append(path, emails[id])
+	}
+	return path
+}
+
+func (s *RelationServiceImp) RegisterEmail(ctx context.Context, rq model.UserRequest) (model.User, error) {
+	ctx, cancel := s.withTimeout(ctx, "RegisterEmail")
+	defer cancel()
+	id, err := s.repo.CreateEmail(ctx, rq.Email)
+	if err == repos.ErrDuplicate {
+		return model.User{}, errors.New("email: " + rq.Email + " is already registered")
+	}
+	if err != nil {
+		return model.User{}, err
+	}
+	return model.User{Id: id, Email: rq.Email}, nil
+}
+
+func (s *RelationServiceImp) GetUser(ctx context.Context, rq model.UserRequest) (model.User, error) {
+	ctx, cancel := s.withTimeout(ctx, "GetUser")
+	defer cancel()
+	id, err := s.repo.GetIdFromEmail(ctx, rq.Email)
+	if err != nil {
+		return model.User{}, err
+	}
+	return model.User{Id: id, Email: rq.Email}, nil
+}
 
-	return utils.Unique(result), nil
+func (s *RelationServiceImp) GetUsers(ctx context.Context) ([]model.User, error) {
+	ctx, cancel := s.withTimeout(ctx, "GetUsers")
+	defer cancel()
+	return s.repo.GetAllEmails(ctx)
 }
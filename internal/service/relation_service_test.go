@@ -1,12 +1,23 @@
 package service
 
 import (
+	"context"
+	"database/sql"
 	"errors"
+	"friend-management-v1/internal/auth"
+	"friend-management-v1/internal/event"
+	"friend-management-v1/internal/repos"
 	"friend-management-v1/internal/utils"
 	"friend-management-v1/model"
 	"friend-management-v1/model/mocks"
+	"regexp"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -15,14 +26,15 @@ func TestGetFriendsBlock(t *testing.T) {
 	request := model.GetFriendsRequest{
 		Email: "quan12yt@gmail.com",
 	}
-	expect := []string{
-		"quan12yt@gmail.com",
+	expect := model.EmailPage{
+		Emails: []string{"quan12yt@gmail.com"},
+		Total:  1,
 	}
 
 	testCases := []struct {
 		name         string
 		mockId       string
-		mockResponse []string
+		mockResponse model.EmailPage
 		err          error
 		finalErr     error
 	}{
@@ -33,28 +45,26 @@ func TestGetFriendsBlock(t *testing.T) {
 			err:          nil,
 		},
 		{
-			name:         "Get Friends email not exist",
-			mockId:       "1",
-			mockResponse: nil,
-			err:          errors.New("email: quan12yt@gmail.com is not exist in database"),
-			finalErr:     errors.New("email: quan12yt@gmail.com is not exist in database"),
+			name:     "Get Friends email not exist",
+			mockId:   "1",
+			err:      errors.New("email: quan12yt@gmail.com is not exist in database"),
+			finalErr: errors.New("email: quan12yt@gmail.com is not exist in database"),
 		},
 		{
-			name:         "Get Friends email failed",
-			mockId:       "1",
-			mockResponse: nil,
-			err:          nil,
-			finalErr:     errors.New(""),
+			name:     "Get Friends email failed",
+			mockId:   "1",
+			err:      nil,
+			finalErr: errors.New(""),
 		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			mockRepo := new(mocks.RelationRepo)
 			service := NewRelationService(mockRepo)
-			mockRepo.On("GetIdFromEmail", mock.Anything).Return(tc.mockId, tc.err)
-			mockRepo.On("GetEmailByStatus", mock.Anything, mock.Anything).Return(tc.mockResponse, tc.finalErr)
+			mockRepo.On("GetIdFromEmail", mock.Anything, mock.Anything).Return(tc.mockId, tc.err)
+			mockRepo.On("GetEmailByStatus", mock.Anything, "1", model.StatusFriend, model.Page{Limit: utils.DefaultLimit}).Return(tc.mockResponse, tc.finalErr)
 
-			actual, err := service.GetFriendsEmail(request)
+			actual, err := service.GetFriendsEmail(context.Background(), request)
 
 			assert.Equal(t, tc.finalErr, err)
 			assert.Equal(t, tc.mockResponse, actual)
@@ -74,6 +84,7 @@ func TestAddFriendBlock(t *testing.T) {
 		name         string
 		mockId       string
 		checkExist   bool
+		isBlock      bool
 		mockResponse bool
 		getIdError   error
 		finalErr     error
@@ -99,6 +110,13 @@ func TestAddFriendBlock(t *testing.T) {
 			mockId:     "1",
 			finalErr:   errors.New("2 emails are already being friend"),
 		},
+		{
+			name:       "Add blocked",
+			getIdError: nil,
+			isBlock:    true,
+			mockId:     "1",
+			finalErr:   errors.New("2 emails are blocked, cannot be friend"),
+		},
 		{
 			name:       "Add failed",
 			getIdError: nil,
@@ -110,13 +128,15 @@ func TestAddFriendBlock(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			mockRepo := new(mocks.RelationRepo)
+			mockTransaction(mockRepo)
 			service := NewRelationService(mockRepo)
-			mockRepo.On("GetIdFromEmail", mock.Anything).Return(tc.mockId, tc.getIdError)
-			mockRepo.On("GetIdFromEmail", mock.Anything).Return(tc.mockId, tc.getIdError)
-			mockRepo.On("CheckIfExist", mock.Anything, mock.Anything, mock.Anything).Return(tc.checkExist)
-			mockRepo.On("AddRelation", mock.Anything, mock.Anything).Return(tc.mockResponse, tc.finalErr)
+			mockRepo.On("GetIdFromEmail", mock.Anything, mock.Anything).Return(tc.mockId, tc.getIdError)
+			mockRepo.On("GetIdFromEmail", mock.Anything, mock.Anything).Return(tc.mockId, tc.getIdError)
+			mockRepo.On("CheckIfExist", mock.Anything, mock.Anything, mock.Anything, model.StatusBlock).Return(tc.isBlock, nil)
+			mockRepo.On("CheckIfExist", mock.Anything, mock.Anything, mock.Anything, model.StatusFriend).Return(tc.checkExist, nil)
+			mockRepo.On("AddRelation", mock.Anything, mock.Anything, mock.Anything).Return(tc.mockResponse, tc.finalErr)
 
-			actual, err := service.Addfriend(request)
+			actual, err := service.Addfriend(context.Background(), request)
 
 			assert.Equal(t, tc.finalErr, err)
 			assert.Equal(t, tc.mockResponse, actual)
@@ -124,29 +144,90 @@ func TestAddFriendBlock(t *testing.T) {
 	}
 }
 
-func TestGetCommonFriendBlock(t *testing.T) {
+func TestUnfriendBlock(t *testing.T) {
 	request := model.AddAndGetCommonRequest{
 		Friends: []string{
 			"quan12yt@gmail.com",
 			"quang@gmail.com",
 		},
 	}
-	slice1 := []string{
-		"ad@gmail.com",
-		"test@gmail.com",
+
+	testCases := []struct {
+		name         string
+		mockId       string
+		isFriend     bool
+		mockResponse bool
+		getIdError   error
+		finalErr     error
+	}{
+		{
+			name:         "Unfriend succeed",
+			getIdError:   nil,
+			mockId:       "1",
+			isFriend:     true,
+			mockResponse: true,
+			finalErr:     nil,
+		},
+		{
+			name:       "Unfriend email not exist",
+			mockId:     "1",
+			getIdError: errors.New("email: quan12yt@gmail.com is not exist in database"),
+			finalErr:   errors.New("email: quan12yt@gmail.com is not exist in database"),
+		},
+		{
+			name:       "Unfriend not friends",
+			getIdError: nil,
+			isFriend:   false,
+			mockId:     "1",
+			finalErr:   errors.New("2 emails are not friends"),
+		},
+		{
+			name:       "Unfriend failed",
+			getIdError: nil,
+			isFriend:   true,
+			mockId:     "1",
+			finalErr:   errors.New(""),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(mocks.RelationRepo)
+			mockTransaction(mockRepo)
+			service := NewRelationService(mockRepo)
+			mockRepo.On("GetIdFromEmail", mock.Anything, mock.Anything).Return(tc.mockId, tc.getIdError)
+			mockRepo.On("CheckIfExist", mock.Anything, mock.Anything, mock.Anything, model.StatusFriend).Return(tc.isFriend, nil)
+			mockRepo.On("RemoveRelation", mock.Anything, mock.Anything, model.StatusFriend).Return(tc.mockResponse, tc.finalErr)
+
+			actual, err := service.Unfriend(context.Background(), request)
+
+			assert.Equal(t, tc.finalErr, err)
+			assert.Equal(t, tc.mockResponse, actual)
+		})
+	}
+}
+
+func TestGetCommonFriendBlock(t *testing.T) {
+	request := model.AddAndGetCommonRequest{
+		Friends: []string{
+			"quan12yt@gmail.com",
+			"quang@gmail.com",
+			"hau@gmail.com",
+			"quang@gmail.com",
+		},
+		Limit:  2,
+		Cursor: "Mw",
 	}
-	slice2 := []string{
-		"hau@gmail.com",
-		"test@gmail.com",
+	expect := model.EmailPage{
+		Emails:     []string{"ad@gmail.com", "test@gmail.com"},
+		NextCursor: "NQ",
+		Total:      5,
 	}
-	expect := utils.RetainSlices(slice1, slice2)
 
 	testCases := []struct {
 		name           string
 		mockId         string
-		mockResponse1  []string
-		mockResponse2  []string
-		expectResponse []string
+		mockResponse   model.EmailPage
+		expectResponse model.EmailPage
 		getIdError     error
 		finalErr       error
 	}{
@@ -154,36 +235,33 @@ func TestGetCommonFriendBlock(t *testing.T) {
 			name:           "Get common  succeed",
 			getIdError:     nil,
 			mockId:         "1",
-			mockResponse1:  slice1,
-			mockResponse2:  slice2,
+			mockResponse:   expect,
 			expectResponse: expect,
 			finalErr:       nil,
 		},
 		{
-			name:           "Get common email not exist",
-			mockId:         "1",
-			expectResponse: nil,
-			getIdError:     errors.New("email: quan12yt@gmail.com is not exist in database"),
-			finalErr:       errors.New("email: quan12yt@gmail.com is not exist in database"),
+			name:       "Get common email not exist",
+			mockId:     "1",
+			getIdError: errors.New("email: quan12yt@gmail.com is not exist in database"),
+			finalErr:   errors.New("email: quan12yt@gmail.com is not exist in database"),
 		},
 		{
-			name:           "Get common  failed",
-			getIdError:     nil,
-			mockId:         "1",
-			expectResponse: nil,
-			finalErr:       errors.New(""),
+			name:       "Get common  failed",
+			getIdError: nil,
+			mockId:     "1",
+			finalErr:   errors.New(""),
 		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			mockRepo := new(mocks.RelationRepo)
 			service := NewRelationService(mockRepo)
-			mockRepo.On("GetIdFromEmail", request.Friends[0]).Return(tc.mockId, tc.getIdError)
-			mockRepo.On("GetIdFromEmail", request.Friends[1]).Return("2", tc.getIdError)
-			mockRepo.On("GetEmailByStatus", "1", mock.Anything).Return(tc.mockResponse1, tc.finalErr)
-			mockRepo.On("GetEmailByStatus", "2", mock.Anything).Return(tc.mockResponse2, tc.finalErr)
+			mockRepo.On("GetIdFromEmail", mock.Anything, request.Friends[0]).Return(tc.mockId, tc.getIdError)
+			mockRepo.On("GetIdFromEmail", mock.Anything, request.Friends[1]).Return("2", tc.getIdError)
+			mockRepo.On("GetIdFromEmail", mock.Anything, request.Friends[2]).Return("4", tc.getIdError)
+			mockRepo.On("GetCommonEmails", mock.Anything, []string{"1", "2", "4"}, model.Page{Limit: 2, Cursor: "Mw"}).Return(tc.mockResponse, tc.finalErr)
 
-			actual, err := service.GetCommonFriends(request)
+			actual, err := service.GetCommonFriends(context.Background(), request)
 
 			assert.Equal(t, tc.finalErr, err)
 			assert.Equal(t, tc.expectResponse, actual)
@@ -262,15 +340,16 @@ func TestSubcribeBlock(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			mockRepo := new(mocks.RelationRepo)
+			mockTransaction(mockRepo)
 			service := NewRelationService(mockRepo)
-			mockRepo.On("GetIdFromEmail", mock.Anything).Return(tc.mockId, tc.getIdError)
-			mockRepo.On("GetIdFromEmail", mock.Anything).Return("2", tc.getIdError)
-			mockRepo.On("CheckIfExist", mock.Anything, mock.Anything, "BLOCK").Return(tc.isBlock)
-			mockRepo.On("CheckIfExist", mock.Anything, mock.Anything, "FRIEND").Return(tc.isFriend)
-			mockRepo.On("CheckIfExist", mock.Anything, mock.Anything, "SUBCRIBE").Return(tc.isSubcribe)
-			mockRepo.On("AddRelation", mock.Anything, "SUBCRIBE").Return(tc.expectResponse, tc.finalErr)
+			mockRepo.On("GetIdFromEmail", mock.Anything, mock.Anything).Return(tc.mockId, tc.getIdError)
+			mockRepo.On("GetIdFromEmail", mock.Anything, mock.Anything).Return("2", tc.getIdError)
+			mockRepo.On("CheckIfExist", mock.Anything, mock.Anything, mock.Anything, model.StatusBlock).Return(tc.isBlock, nil)
+			mockRepo.On("CheckIfExist", mock.Anything, mock.Anything, mock.Anything, model.StatusFriend).Return(tc.isFriend, nil)
+			mockRepo.On("CheckIfExist", mock.Anything, mock.Anything, mock.Anything, model.StatusSubcribe).Return(tc.isSubcribe, nil)
+			mockRepo.On("AddDirectedRelation", mock.Anything, mock.Anything, model.StatusSubcribe).Return(tc.expectResponse, tc.finalErr)
 
-			actual, err := service.SubcribeToEmail(request)
+			actual, err := service.SubcribeToEmail(context.Background(), request)
 
 			assert.Equal(t, tc.finalErr, err)
 			assert.Equal(t, tc.expectResponse, actual)
@@ -278,6 +357,95 @@ func TestSubcribeBlock(t *testing.T) {
 	}
 }
 
+func TestUnsubcribeBlock(t *testing.T) {
+	request := model.SubcribeAndBlockRequest{
+		Requestor: "quan12yt@gmail.com",
+		Target:    "quang@gmail.com",
+	}
+
+	testCases := []struct {
+		name           string
+		mockId         string
+		expectResponse bool
+		isSubcribe     bool
+		getIdError     error
+		finalErr       error
+	}{
+		{
+			name:           "Unsubcribe succeed",
+			getIdError:     nil,
+			mockId:         "1",
+			isSubcribe:     true,
+			expectResponse: true,
+			finalErr:       nil,
+		},
+		{
+			name:       "Unsubcribe email not exist",
+			getIdError: errors.New("email: quan12yt@gmail.com is not exist in database"),
+			mockId:     "1",
+			finalErr:   errors.New("email: quan12yt@gmail.com is not exist in database"),
+		},
+		{
+			name:       "Unsubcribe not subcribed",
+			getIdError: nil,
+			mockId:     "1",
+			isSubcribe: false,
+			finalErr:   errors.New("not subcribe to the target email"),
+		},
+		{
+			name:       "Unsubcribe failed",
+			getIdError: nil,
+			mockId:     "1",
+			isSubcribe: true,
+			finalErr:   errors.New(""),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(mocks.RelationRepo)
+			mockTransaction(mockRepo)
+			service := NewRelationService(mockRepo)
+			mockRepo.On("GetIdFromEmail", mock.Anything, mock.Anything).Return(tc.mockId, tc.getIdError)
+			mockRepo.On("CheckIfExist", mock.Anything, mock.Anything, mock.Anything, model.StatusSubcribe).Return(tc.isSubcribe, nil)
+			mockRepo.On("RemoveDirectedRelation", mock.Anything, mock.Anything, model.StatusSubcribe).Return(tc.expectResponse, tc.finalErr)
+
+			actual, err := service.UnsubcribeFromEmail(context.Background(), request)
+
+			assert.Equal(t, tc.finalErr, err)
+			assert.Equal(t, tc.expectResponse, actual)
+		})
+	}
+}
+
+func TestUnsubcribeStopsUpdates(t *testing.T) {
+	repo := repos.NewRelationRepoMemory()
+	repo.AddEmail("quan12yt@gmail.com")
+	repo.AddEmail("quang@gmail.com")
+	service := NewRelationService(repo)
+	request := model.SubcribeAndBlockRequest{
+		Requestor: "quan12yt@gmail.com",
+		Target:    "quang@gmail.com",
+	}
+	retrieve := model.RetrieveRequest{Sender: "quang@gmail.com", Text: "hello"}
+
+	_, err := service.SubcribeToEmail(context.Background(), request)
+	assert.Nil(t, err)
+	recipients, err := service.RetrieveContactEmail(context.Background(), retrieve)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"quan12yt@gmail.com"}, recipients.Emails)
+
+	recipients, err = service.RetrieveContactEmail(context.Background(), model.RetrieveRequest{Sender: request.Requestor, Text: "hello"})
+	assert.Nil(t, err)
+	assert.Empty(t, recipients.Emails)
+
+	ok, err := service.UnsubcribeFromEmail(context.Background(), request)
+	assert.Nil(t, err)
+	assert.Equal(t, true, ok)
+	recipients, err = service.RetrieveContactEmail(context.Background(), retrieve)
+	assert.Nil(t, err)
+	assert.Empty(t, recipients.Emails)
+}
+
 func TestBlockEmailBlock(t *testing.T) {
 	request := model.SubcribeAndBlockRequest{
 		Requestor: "quan12yt@gmail.com",
@@ -323,13 +491,14 @@ func TestBlockEmailBlock(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			mockRepo := new(mocks.RelationRepo)
+			mockTransaction(mockRepo)
 			service := NewRelationService(mockRepo)
-			mockRepo.On("GetIdFromEmail", mock.Anything).Return(tc.mockId, tc.getIdError)
-			mockRepo.On("GetIdFromEmail", mock.Anything).Return("2", tc.getIdError)
-			mockRepo.On("CheckIfExist", mock.Anything, mock.Anything, "BLOCK").Return(tc.isBlock)
-			mockRepo.On("AddRelation", mock.Anything, "BLOCK").Return(tc.expectResponse, tc.finalErr)
+			mockRepo.On("GetIdFromEmail", mock.Anything, mock.Anything).Return(tc.mockId, tc.getIdError)
+			mockRepo.On("GetIdFromEmail", mock.Anything, mock.Anything).Return("2", tc.getIdError)
+			mockRepo.On("CheckIfExist", mock.Anything, mock.Anything, mock.Anything, model.StatusBlock).Return(tc.isBlock, nil)
+			mockRepo.On("AddDirectedRelation", mock.Anything, mock.Anything, model.StatusBlock).Return(tc.expectResponse, tc.finalErr)
 
-			actual, err := service.BlockEmail(request)
+			actual, err := service.BlockEmail(context.Background(), request)
 
 			assert.Equal(t, tc.finalErr, err)
 			assert.Equal(t, tc.expectResponse, actual)
@@ -337,65 +506,843 @@ func TestBlockEmailBlock(t *testing.T) {
 	}
 }
 
+func TestUnblockEmailBlock(t *testing.T) {
+	request := model.SubcribeAndBlockRequest{
+		Requestor: "quan12yt@gmail.com",
+		Target:    "quang@gmail.com",
+	}
+
+	testCases := []struct {
+		name           string
+		mockId         string
+		expectResponse []model.RelationStatus
+		isBlock        bool
+		isBlockedBack  bool
+		isFriend       bool
+		isSubcribe     bool
+		getIdError     error
+		finalErr       error
+	}{
+		{
+			name:           "Unblock succeed nothing restored",
+			mockId:         "1",
+			isBlock:        true,
+			expectResponse: []model.RelationStatus{},
+		},
+		{
+			name:           "Unblock restores friendship",
+			mockId:         "1",
+			isBlock:        true,
+			isFriend:       true,
+			expectResponse: []model.RelationStatus{model.StatusFriend},
+		},
+		{
+			name:           "Unblock target still blocks requestor",
+			mockId:         "1",
+			isBlock:        true,
+			isBlockedBack:  true,
+			isFriend:       true,
+			expectResponse: []model.RelationStatus{},
+		},
+		{
+			name:           "Unblock restores subcription",
+			mockId:         "1",
+			isBlock:        true,
+			isSubcribe:     true,
+			expectResponse: []model.RelationStatus{model.StatusSubcribe},
+		},
+		{
+			name:       "Unblock email not exist",
+			getIdError: errors.New("email: quan12yt@gmail.com is not exist in database"),
+			mockId:     "1",
+			finalErr:   errors.New("email: quan12yt@gmail.com is not exist in database"),
+		},
+		{
+			name:     "Unblock not blocked",
+			mockId:   "1",
+			isBlock:  false,
+			finalErr: errors.New("target email has not been blocked"),
+		},
+		{
+			name:     "Unblock failed",
+			mockId:   "1",
+			isBlock:  true,
+			finalErr: errors.New(""),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(mocks.RelationRepo)
+			mockTransaction(mockRepo)
+			service := NewRelationService(mockRepo)
+			mockRepo.On("GetIdFromEmail", mock.Anything, request.Requestor).Return(tc.mockId, tc.getIdError)
+			mockRepo.On("GetIdFromEmail", mock.Anything, request.Target).Return("2", tc.getIdError)
+			mockRepo.On("CheckIfExist", mock.Anything, tc.mockId, "2", model.StatusBlock).Return(tc.isBlock, nil)
+			mockRepo.On("CheckIfExist", mock.Anything, "2", tc.mockId, model.StatusBlock).Return(tc.isBlockedBack, nil)
+			mockRepo.On("CheckIfExist", mock.Anything, mock.Anything, mock.Anything, model.StatusFriend).Return(tc.isFriend, nil)
+			mockRepo.On("CheckIfExist", mock.Anything, mock.Anything, mock.Anything, model.StatusSubcribe).Return(tc.isSubcribe, nil)
+			mockRepo.On("RemoveDirectedRelation", mock.Anything, mock.Anything, model.StatusBlock).Return(tc.finalErr == nil, tc.finalErr)
+
+			actual, err := service.UnblockEmail(context.Background(), request)
+
+			assert.Equal(t, tc.finalErr, err)
+			assert.Equal(t, tc.expectResponse, actual)
+		})
+	}
+}
+
+func TestUnblockRestoresFriendship(t *testing.T) {
+	repo := repos.NewRelationRepoMemory()
+	repo.AddEmail("quan12yt@gmail.com")
+	repo.AddEmail("quang@gmail.com")
+	service := NewRelationService(repo)
+	request := model.SubcribeAndBlockRequest{
+		Requestor: "quan12yt@gmail.com",
+		Target:    "quang@gmail.com",
+	}
+
+	_, err := service.Addfriend(context.Background(), model.AddAndGetCommonRequest{Friends: []string{request.Requestor, request.Target}})
+	assert.Nil(t, err)
+	_, err = service.BlockEmail(context.Background(), request)
+	assert.Nil(t, err)
+
+	restored, err := service.UnblockEmail(context.Background(), request)
+	assert.Nil(t, err)
+	assert.Equal(t, []model.RelationStatus{model.StatusFriend}, restored)
+	friends, err := service.GetFriendsEmail(context.Background(), model.GetFriendsRequest{Email: request.Requestor})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"quang@gmail.com"}, friends.Emails)
+
+	_, err = service.UnblockEmail(context.Background(), request)
+	assert.Equal(t, errors.New("target email has not been blocked"), err)
+}
+
+func TestBlockTakesEffect(t *testing.T) {
+	repo := repos.NewRelationRepoMemory()
+	repo.AddEmail("quan12yt@gmail.com")
+	repo.AddEmail("quang@gmail.com")
+	repo.AddEmail("hau@gmail.com")
+	repo.AddEmail("len@gmail.com")
+	service := NewRelationService(repo)
+
+	for _, friends := range [][]string{
+		{"quan12yt@gmail.com", "quang@gmail.com"},
+		{"quan12yt@gmail.com", "hau@gmail.com"},
+		{"quang@gmail.com", "hau@gmail.com"},
+	} {
+		_, err := service.Addfriend(context.Background(), model.AddAndGetCommonRequest{Friends: friends})
+		assert.Nil(t, err)
+	}
+	_, err := service.BlockEmail(context.Background(), model.SubcribeAndBlockRequest{Requestor: "quan12yt@gmail.com", Target: "hau@gmail.com"})
+	assert.Nil(t, err)
+	_, err = service.BlockEmail(context.Background(), model.SubcribeAndBlockRequest{Requestor: "quan12yt@gmail.com", Target: "len@gmail.com"})
+	assert.Nil(t, err)
+
+	friends, err := service.GetFriendsEmail(context.Background(), model.GetFriendsRequest{Email: "quan12yt@gmail.com"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"quang@gmail.com"}, friends.Emails)
+
+	common, err := service.GetCommonFriends(context.Background(), model.AddAndGetCommonRequest{Friends: []string{"quan12yt@gmail.com", "quang@gmail.com"}})
+	assert.Nil(t, err)
+	assert.Empty(t, common.Emails)
+
+	recipients, err := service.RetrieveContactEmail(context.Background(), model.RetrieveRequest{Sender: "hau@gmail.com", Text: "hello"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"quang@gmail.com"}, recipients.Emails)
+
+	recipients, err = service.RetrieveContactEmail(context.Background(), model.RetrieveRequest{Sender: "len@gmail.com", Text: "hello quan12yt@gmail.com and new@gmail.com"})
+	assert.Nil(t, err)
+	assert.Empty(t, recipients.Emails)
+	assert.Equal(t, []string{"new@gmail.com"}, recipients.UnresolvedMentions)
+
+	_, err = service.Addfriend(context.Background(), model.AddAndGetCommonRequest{Friends: []string{"len@gmail.com", "quan12yt@gmail.com"}})
+	assert.Equal(t, errors.New("2 emails are blocked, cannot be friend"), err)
+
+	_, err = service.SubcribeToEmail(context.Background(), model.SubcribeAndBlockRequest{Requestor: "len@gmail.com", Target: "quan12yt@gmail.com"})
+	assert.Equal(t, errors.New("target email has been blocked"), err)
+}
+
 func TestRetrieveBlock(t *testing.T) {
 	request := model.RetrieveRequest{
 		Sender: "quan12yt@gmail.com",
-		Text:   "asd hau@gmail.com",
+		Text:   "asd hau@gmail.com asd@gmail.com hau@gmail.com",
 	}
 
-	emails := []string{
-		"asd@gmail.com",
-		"test@gmail.com",
+	page := model.EmailPage{
+		Emails: []string{"asd@gmail.com", "test@gmail.com", "hau@gmail.com"},
+		Total:  3,
 	}
-
-	expect := []string{
-		"asd@gmail.com",
-		"test@gmail.com",
-		"hau@gmail.com",
+	expect := model.RecipientsPage{
+		EmailPage:          page,
+		UnresolvedMentions: []string{"asd@gmail.com"},
 	}
 	testCases := []struct {
 		name           string
 		mockId         string
-		mockResponse   []string
-		expectResponse []string
+		mockResponse   model.EmailPage
+		expectResponse model.RecipientsPage
 		err            error
 		finalErr       error
 	}{
 		{
 			name:           "Retrieve succeed",
 			mockId:         "1",
-			mockResponse:   emails,
+			mockResponse:   page,
 			expectResponse: expect,
 			err:            nil,
 		},
 		{
-			name:           "Retrieve email not exist",
-			mockId:         "1",
-			mockResponse:   nil,
-			expectResponse: nil,
-			err:            errors.New("email: quan12yt@gmail.com is not exist in database"),
-			finalErr:       errors.New("email: quan12yt@gmail.com is not exist in database"),
+			name:     "Retrieve email not exist",
+			mockId:   "1",
+			err:      errors.New("email: quan12yt@gmail.com is not exist in database"),
+			finalErr: errors.New("email: quan12yt@gmail.com is not exist in database"),
 		},
 		{
-			name:           "Retrieve email not exist",
-			mockId:         "1",
-			mockResponse:   nil,
-			expectResponse: nil,
-			err:            nil,
-			finalErr:       errors.New(""),
+			name:     "Retrieve email not exist",
+			mockId:   "1",
+			err:      nil,
+			finalErr: errors.New(""),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(mocks.RelationRepo)
+			service := NewRelationService(mockRepo)
+			mockRepo.On("GetIdFromEmail", mock.Anything, mock.Anything).Return(tc.mockId, tc.err)
+			mockRepo.On("GetRetrivableEmails", mock.Anything, "1", []string{"hau@gmail.com", "asd@gmail.com"}, model.Page{Limit: utils.DefaultLimit}).Return(tc.mockResponse, tc.finalErr)
+			mockRepo.On("GetUnregisteredEmails", mock.Anything, []string{"hau@gmail.com", "asd@gmail.com"}).Return([]string{"asd@gmail.com"}, nil)
+
+			actual, err := service.RetrieveContactEmail(context.Background(), request)
+
+			assert.Equal(t, tc.finalErr, err)
+			assert.Equal(t, tc.expectResponse, actual)
+		})
+	}
+}
+
+func TestOperationTimeouts(t *testing.T) {
+	request := model.GetFriendsRequest{
+		Email: "quan12yt@gmail.com",
+	}
+
+	testCases := []struct {
+		name        string
+		opts        []Option
+		hasDeadline bool
+		timeout     time.Duration
+	}{
+		{
+			name:        "No timeout",
+			hasDeadline: false,
+		},
+		{
+			name:        "Default timeout",
+			opts:        []Option{WithTimeouts(time.Minute, nil)},
+			hasDeadline: true,
+			timeout:     time.Minute,
+		},
+		{
+			name:        "Operation timeout",
+			opts:        []Option{WithTimeouts(time.Minute, map[string]time.Duration{"GetFriendsEmail": time.Second})},
+			hasDeadline: true,
+			timeout:     time.Second,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(mocks.RelationRepo)
+			service := NewRelationService(mockRepo, tc.opts...)
+			var deadline time.Time
+			var hasDeadline bool
+			mockRepo.On("GetIdFromEmail", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+				deadline, hasDeadline = args.Get(0).(context.Context).Deadline()
+			}).Return("1", nil)
+			mockRepo.On("GetEmailByStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.EmailPage{}, nil)
+
+			_, err := service.GetFriendsEmail(context.Background(), request)
+
+			assert.Nil(t, err)
+			assert.Equal(t, tc.hasDeadline, hasDeadline)
+			if tc.hasDeadline {
+				assert.WithinDuration(t, time.Now().Add(tc.timeout), deadline, 500*time.Millisecond)
+			}
+		})
+	}
+}
+
+func TestOperationTimeoutExpires(t *testing.T) {
+	request := model.AddAndGetCommonRequest{
+		Friends: []string{"quan12yt@gmail.com", "quang@gmail.com"},
+	}
+	mockRepo := new(mocks.RelationRepo)
+	mockTransaction(mockRepo)
+	service := NewRelationService(mockRepo, WithTimeouts(10*time.Millisecond, nil))
+	mockRepo.On("GetIdFromEmail", mock.Anything, "quan12yt@gmail.com").Return("1", nil)
+	mockRepo.On("GetIdFromEmail", mock.Anything, "quang@gmail.com").Return("2", nil)
+	mockRepo.On("CheckIfExist", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(
+		func(ctx context.Context, id1 string, id2 string, status model.RelationStatus) bool {
+			<-ctx.Done()
+			return false
+		},
+		func(ctx context.Context, id1 string, id2 string, status model.RelationStatus) error {
+			return ctx.Err()
+		})
+
+	_, err := service.Unfriend(context.Background(), request)
+
+	assert.Equal(t, context.DeadlineExceeded, err)
+	mockRepo.AssertNotCalled(t, "RemoveRelation", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// TestConcurrentWrites runs the writes against the memory repository, whose
+// transactions hold one mutex. TestAddfriendDuplicateOnPostgres covers the
+// unique index backing the row locks on Postgres.
+func TestConcurrentWrites(t *testing.T) {
+	repo := repos.NewRelationRepoMemory()
+	repo.AddEmail("quan12yt@gmail.com")
+	repo.AddEmail("quang@gmail.com")
+	service := NewRelationService(repo)
+	friends := model.AddAndGetCommonRequest{Friends: []string{"quan12yt@gmail.com", "quang@gmail.com"}}
+	block := model.SubcribeAndBlockRequest{Requestor: "quan12yt@gmail.com", Target: "quang@gmail.com"}
+
+	var wg sync.WaitGroup
+	var added, blocked int32
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if ok, err := service.Addfriend(context.Background(), friends); err == nil && ok {
+				atomic.AddInt32(&added, 1)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := service.BlockEmail(context.Background(), block); err == nil {
+				atomic.AddInt32(&blocked, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.True(t, added <= 1)
+	assert.Equal(t, int32(1), blocked)
+	restored, err := service.UnblockEmail(context.Background(), block)
+	assert.Nil(t, err)
+	if added == 1 {
+		assert.Equal(t, []model.RelationStatus{model.StatusFriend}, restored)
+	} else {
+		assert.Equal(t, []model.RelationStatus{}, restored)
+	}
+	_, err = service.Unfriend(context.Background(), friends)
+	assert.Equal(t, added == 1, err == nil)
+	_, err = service.Unfriend(context.Background(), friends)
+	assert.NotNil(t, err)
+}
+
+func TestPostUpdate(t *testing.T) {
+	ctx := context.Background()
+	repo := repos.NewRelationRepoMemory()
+	for _, email := range []string{"quan12yt@gmail.com", "quang@gmail.com", "hau@gmail.com", "len@gmail.com"} {
+		repo.AddEmail(email)
+	}
+	service := NewRelationService(repo, WithUpdateRepo(repos.NewUpdateRepoMemory(repo)))
+	_, err := service.Addfriend(ctx, model.AddAndGetCommonRequest{Friends: []string{"quan12yt@gmail.com", "quang@gmail.com"}})
+	assert.Nil(t, err)
+	_, err = service.SubcribeToEmail(ctx, model.SubcribeAndBlockRequest{Requestor: "hau@gmail.com", Target: "quan12yt@gmail.com"})
+	assert.Nil(t, err)
+
+	recipients, err := service.RetrieveContactEmail(ctx, model.RetrieveRequest{
+		Sender: "quan12yt@gmail.com",
+		Text:   "hello len@gmail.com",
+		Limit:  1,
+		Post:   true,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"quang@gmail.com"}, recipients.Emails)
+	assert.Equal(t, "1", recipients.UpdateId)
+
+	for _, email := range []string{"quang@gmail.com", "hau@gmail.com", "len@gmail.com"} {
+		inbox, err := service.GetInbox(ctx, model.InboxRequest{Email: email})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, inbox.Unread)
+		assert.Equal(t, "hello len@gmail.com", inbox.Items[0].Update.Text)
+		assert.Equal(t, "quan12yt@gmail.com", inbox.Items[0].Update.Sender)
+
+		marked, err := service.MarkRead(ctx, model.MarkReadRequest{Email: email, Ids: []string{inbox.Items[0].Id}})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, marked)
+	}
+
+	inbox, err := service.GetInbox(ctx, model.InboxRequest{Email: "quan12yt@gmail.com"})
+	assert.Nil(t, err)
+	assert.Empty(t, inbox.Items)
+
+	_, err = service.RetrieveContactEmail(ctx, model.RetrieveRequest{Sender: "quan12yt@gmail.com", Text: "hello", Post: false})
+	assert.Nil(t, err)
+	inbox, err = service.GetInbox(ctx, model.InboxRequest{Email: "quang@gmail.com", UnreadOnly: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, inbox.Total)
+}
+
+func TestUpdatesNotStored(t *testing.T) {
+	mockRepo := new(mocks.RelationRepo)
+	service := NewRelationService(mockRepo)
+
+	_, err := service.RetrieveContactEmail(context.Background(), model.RetrieveRequest{Sender: "quan12yt@gmail.com", Post: true})
+	assert.Equal(t, ErrUpdatesNotStored, err)
+	_, err = service.GetInbox(context.Background(), model.InboxRequest{Email: "quan12yt@gmail.com"})
+	assert.Equal(t, ErrUpdatesNotStored, err)
+	_, err = service.MarkRead(context.Background(), model.MarkReadRequest{Email: "quan12yt@gmail.com", Ids: []string{"1"}})
+	assert.Equal(t, ErrUpdatesNotStored, err)
+}
+
+func TestGetInboxBlock(t *testing.T) {
+	expect := model.InboxPage{
+		Items:  []model.InboxItem{{Id: "3", Update: model.Update{Id: "1", Sender: "quang@gmail.com", Text: "hello"}}},
+		Total:  1,
+		Unread: 1,
+	}
+	mockRepo := new(mocks.RelationRepo)
+	mockUpdates := new(mocks.UpdateRepo)
+	service := NewRelationService(mockRepo, WithUpdateRepo(mockUpdates))
+	mockRepo.On("GetIdFromEmail", mock.Anything, "quan12yt@gmail.com").Return("1", nil)
+	mockUpdates.On("GetInbox", mock.Anything, "1", model.Page{Limit: 5, Cursor: "Mw"}, true).Return(expect, nil)
+
+	actual, err := service.GetInbox(context.Background(), model.InboxRequest{Email: "quan12yt@gmail.com", Limit: 5, Cursor: "Mw", UnreadOnly: true})
+
+	assert.Nil(t, err)
+	assert.Equal(t, expect, actual)
+}
+
+func TestAddfriendDuplicateOnPostgres(t *testing.T) {
+	db, sqlMock := repos.DbMock()
+	service := NewRelationService(repos.NewRelationRepo(db))
+	email_query := `select e.email_id from email e where e.email = $1`
+	exist_query := `select fr.relation_id 
+	from friend_relationship fr 
+	where fr.your_id = $1 and fr.friend_id = $2 and status = $3`
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(regexp.QuoteMeta(email_query)).WithArgs("quan12yt@gmail.com").
+		WillReturnRows(sqlmock.NewRows([]string{"email_id"}).AddRow("1"))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(email_query)).WithArgs("quang@gmail.com").
+		WillReturnRows(sqlmock.NewRows([]string{"email_id"}).AddRow("2"))
+	sqlMock.ExpectExec("for update").WithArgs("1", "2").WillReturnResult(sqlmock.NewResult(0, 2))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(exist_query)).WithArgs("1", "2", model.StatusBlock).
+		WillReturnRows(sqlmock.NewRows([]string{"relation_id"}))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(exist_query)).WithArgs("2", "1", model.StatusBlock).
+		WillReturnRows(sqlmock.NewRows([]string{"relation_id"}))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(exist_query)).WithArgs("1", "2", model.StatusFriend).
+		WillReturnRows(sqlmock.NewRows([]string{"relation_id"}))
+	// a friendship committed by a concurrent write, e.g. before the emails were locked
+	sqlMock.ExpectExec("insert into friend_relationship").WithArgs("1", "2", model.StatusFriend).
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "friend_relationship_unique"})
+	sqlMock.ExpectRollback()
+
+	added, err := service.Addfriend(context.Background(), model.AddAndGetCommonRequest{Friends: []string{"quan12yt@gmail.com", "quang@gmail.com"}})
+
+	assert.False(t, added)
+	assert.Equal(t, errors.New("2 emails are already being friend"), err)
+	assert.Nil(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPublishEvents(t *testing.T) {
+	ctx := context.Background()
+	repo := repos.NewRelationRepoMemory()
+	for _, email := range []string{"quan12yt@gmail.com", "quang@gmail.com", "hau@gmail.com"} {
+		repo.AddEmail(email)
+	}
+	bus := event.NewBus()
+	var events []event.Event
+	bus.Subscribe(func(ctx context.Context, e event.Event) error {
+		events = append(events, e)
+		return errors.New("subscriber failed")
+	})
+	service := NewRelationService(repo, WithUpdateRepo(repos.NewUpdateRepoMemory(repo)), WithEventBus(bus))
+
+	_, err := service.Addfriend(ctx, model.AddAndGetCommonRequest{Friends: []string{"quan12yt@gmail.com", "quang@gmail.com"}})
+	assert.Nil(t, err)
+	_, err = service.Addfriend(ctx, model.AddAndGetCommonRequest{Friends: []string{"quan12yt@gmail.com", "quang@gmail.com"}})
+	assert.NotNil(t, err)
+	_, err = service.SubcribeToEmail(ctx, model.SubcribeAndBlockRequest{Requestor: "hau@gmail.com", Target: "quan12yt@gmail.com"})
+	assert.Nil(t, err)
+	recipients, err := service.RetrieveContactEmail(ctx, model.RetrieveRequest{Sender: "quan12yt@gmail.com", Text: "hello", Post: true})
+	assert.Nil(t, err)
+	_, err = service.RetrieveContactEmail(ctx, model.RetrieveRequest{Sender: "quan12yt@gmail.com", Text: "hello"})
+	assert.Nil(t, err)
+	_, err = service.BlockEmail(ctx, model.SubcribeAndBlockRequest{Requestor: "hau@gmail.com", Target: "quang@gmail.com"})
+	assert.Nil(t, err)
+	_, err = service.BlockEmail(ctx, model.SubcribeAndBlockRequest{Requestor: "hau@gmail.com", Target: "quang@gmail.com"})
+	assert.NotNil(t, err)
+
+	assert.Len(t, events, 4)
+	assert.Equal(t, event.FriendAdded{Actor: "quan12yt@gmail.com", Target: "quang@gmail.com"}, events[0])
+	assert.Equal(t, event.Subscribed{Requestor: "hau@gmail.com", Target: "quan12yt@gmail.com"}, events[1])
+	posted := events[2].(event.UpdatePosted)
+	assert.Equal(t, recipients.UpdateId, posted.Update.Id)
+	assert.Equal(t, "hello", posted.Update.Text)
+	assert.Equal(t, []string{"quang@gmail.com", "hau@gmail.com"}, posted.Recipients)
+	assert.Equal(t, event.Blocked{Requestor: "hau@gmail.com", Target: "quang@gmail.com"}, events[3])
+}
+
+func TestAuthorizePrincipal(t *testing.T) {
+	repo := repos.NewRelationRepoMemory()
+	for _, email := range []string{"quan12yt@gmail.com", "quang@gmail.com", "hau@gmail.com"} {
+		repo.AddEmail(email)
+	}
+	service := NewRelationService(repo)
+	user := auth.NewContext(context.Background(), auth.Principal{Id: "mobile", Email: "hau@gmail.com"})
+	admin := auth.NewContext(context.Background(), auth.Principal{Id: "support", Email: "support@gmail.com", Admin: true})
+	request := model.SubcribeAndBlockRequest{Requestor: "quan12yt@gmail.com", Target: "quang@gmail.com"}
+
+	_, err := service.SubcribeToEmail(user, request)
+	assert.True(t, errors.Is(err, auth.ErrForbidden))
+	_, err = service.UnsubcribeFromEmail(user, request)
+	assert.True(t, errors.Is(err, auth.ErrForbidden))
+	_, err = service.BlockEmail(user, request)
+	assert.True(t, errors.Is(err, auth.ErrForbidden))
+	_, err = service.UnblockEmail(user, request)
+	assert.True(t, errors.Is(err, auth.ErrForbidden))
+	_, err = service.RetrieveContactEmail(user, model.RetrieveRequest{Sender: "quan12yt@gmail.com", Text: "hello"})
+	assert.True(t, errors.Is(err, auth.ErrForbidden))
+	friends := model.AddAndGetCommonRequest{Friends: []string{"quan12yt@gmail.com", "hau@gmail.com"}}
+	_, err = service.Addfriend(user, friends)
+	assert.True(t, errors.Is(err, auth.ErrForbidden))
+	_, err = service.Unfriend(user, friends)
+	assert.True(t, errors.Is(err, auth.ErrForbidden))
+	_, err = service.GetInbox(user, model.InboxRequest{Email: "quan12yt@gmail.com"})
+	assert.True(t, errors.Is(err, auth.ErrForbidden))
+	_, err = service.MarkRead(user, model.MarkReadRequest{Email: "quan12yt@gmail.com", Ids: []string{"1"}})
+	assert.True(t, errors.Is(err, auth.ErrForbidden))
+	err = service.RegisterWebhook(user, model.WebhookRequest{Email: "quan12yt@gmail.com", Url: "https://example.com/hook"})
+	assert.True(t, errors.Is(err, auth.ErrForbidden))
+	_, err = service.GetDeliveries(user, model.DeliveriesRequest{})
+	assert.True(t, errors.Is(err, auth.ErrForbidden))
+	_, err = service.GetAuditRecords(user, model.AuditRequest{Email: "quan12yt@gmail.com"})
+	assert.True(t, errors.Is(err, auth.ErrForbidden))
+	audit, err := repo.GetAuditRecords(context.Background(), "1", time.Time{}, time.Time{}, model.Page{})
+	assert.Nil(t, err)
+	assert.Empty(t, audit.Records)
+
+	_, err = service.SubcribeToEmail(user, model.SubcribeAndBlockRequest{Requestor: "hau@gmail.com", Target: "quang@gmail.com"})
+	assert.Nil(t, err)
+	_, err = service.BlockEmail(admin, request)
+	assert.Nil(t, err)
+	_, err = service.RetrieveContactEmail(admin, model.RetrieveRequest{Sender: "quan12yt@gmail.com", Text: "hello"})
+	assert.Nil(t, err)
+	_, err = service.Addfriend(user, model.AddAndGetCommonRequest{Friends: []string{"hau@gmail.com", "quang@gmail.com"}})
+	assert.Nil(t, err)
+	_, err = service.GetAuditRecords(user, model.AuditRequest{Email: "hau@gmail.com"})
+	assert.Nil(t, err)
+	_, err = service.GetDeliveries(admin, model.DeliveriesRequest{})
+	assert.Equal(t, ErrWebhooksDisabled, err)
+}
+
+func TestGetAuditRecords(t *testing.T) {
+	from := time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)
+	expect := model.AuditPage{Records: []model.AuditRecord{{Id: "3", Action: model.AuditAdd, NewStatus: model.StatusBlock}}}
+	mockRepo := new(mocks.RelationRepo)
+	service := NewRelationService(mockRepo)
+	mockRepo.On("GetIdFromEmail", mock.Anything, "quan12yt@gmail.com").Return("1", nil)
+	mockRepo.On("GetAuditRecords", mock.Anything, "1", from, time.Time{}, model.Page{Limit: 5, Cursor: "Mw"}).Return(expect, nil)
+
+	actual, err := service.GetAuditRecords(context.Background(), model.AuditRequest{Email: "quan12yt@gmail.com", From: from, Limit: 5, Cursor: "Mw"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, expect, actual)
+}
+
+func TestWebhooks(t *testing.T) {
+	expect := model.DeliveryPage{Deliveries: []model.Delivery{{Id: "4", Status: model.DeliveryDead}}}
+	mockRepo := new(mocks.RelationRepo)
+	mockWebhooks := new(mocks.WebhookRepo)
+	service := NewRelationService(mockRepo, WithWebhookRepo(mockWebhooks))
+	mockRepo.On("GetIdFromEmail", mock.Anything, "quan12yt@gmail.com").Return("1", nil)
+	mockWebhooks.On("SetWebhook", mock.Anything, "1", "http://localhost/hook").Return(nil)
+	mockWebhooks.On("GetDeliveries", mock.Anything, model.DeliveryDead, model.Page{Limit: utils.DefaultLimit}).Return(expect, nil)
+
+	err := service.RegisterWebhook(context.Background(), model.WebhookRequest{Email: "quan12yt@gmail.com", Url: "http://localhost/hook"})
+	assert.Nil(t, err)
+	actual, err := service.GetDeliveries(context.Background(), model.DeliveriesRequest{})
+	assert.Nil(t, err)
+	assert.Equal(t, expect, actual)
+	mockWebhooks.AssertExpectations(t)
+
+	rejected := errors.New("url must not point to a private address")
+	service = NewRelationService(mockRepo, WithWebhookRepo(mockWebhooks), WithWebhookURLCheck(func(url string) error {
+		return rejected
+	}))
+	err = service.RegisterWebhook(context.Background(), model.WebhookRequest{Email: "quan12yt@gmail.com", Url: "http://10.0.0.1/hook"})
+	assert.Equal(t, rejected, err)
+	mockWebhooks.AssertNumberOfCalls(t, "SetWebhook", 1)
+
+	service = NewRelationService(mockRepo)
+	err = service.RegisterWebhook(context.Background(), model.WebhookRequest{Email: "quan12yt@gmail.com"})
+	assert.Equal(t, ErrWebhooksDisabled, err)
+	_, err = service.GetDeliveries(context.Background(), model.DeliveriesRequest{})
+	assert.Equal(t, ErrWebhooksDisabled, err)
+}
+
+func TestGetSuggestionsBlock(t *testing.T) {
+	expect := []model.Suggestion{
+		{Email: "toan@gmail.com", MutualCount: 1, MutualFriends: []string{"quang@gmail.com"}},
+	}
+	mockRepo := new(mocks.RelationRepo)
+	service := NewRelationService(mockRepo)
+	mockRepo.On("GetIdFromEmail", mock.Anything, "quan12yt@gmail.com").Return("1", nil)
+	mockRepo.On("GetIdFromEmail", mock.Anything, "quang@gmail.com").Return("", &repos.EmailNotFoundError{Email: "quang@gmail.com"})
+	mockRepo.On("GetSuggestions", mock.Anything, "1", utils.DefaultLimit).Return(expect, nil)
+
+	actual, err := service.GetSuggestions(context.Background(), model.SuggestionsRequest{Email: "quan12yt@gmail.com"})
+	assert.Nil(t, err)
+	assert.Equal(t, expect, actual)
+
+	_, err = service.GetSuggestions(context.Background(), model.SuggestionsRequest{Email: "quang@gmail.com"})
+	assert.Equal(t, "email: quang@gmail.com is not exist in database", err.Error())
+}
+
+func TestGetPath(t *testing.T) {
+	ctx := context.Background()
+	repo := repos.NewRelationRepoMemory()
+	for _, email := range []string{"a@gmail.com", "b@gmail.com", "c@gmail.com", "d@gmail.com", "e@gmail.com", "f@gmail.com", "g@gmail.com"} {
+		repo.AddEmail(email)
+	}
+	// a - b - c - d - e, with the shortcut b - f - e hidden by a block.
+	for _, pair := range [][]string{{"1", "2"}, {"2", "3"}, {"3", "4"}, {"4", "5"}, {"2", "6"}, {"6", "5"}} {
+		repo.AddRelation(ctx, pair, model.StatusFriend)
+	}
+	repo.AddDirectedRelation(ctx, []string{"5", "6"}, model.StatusBlock)
+	repo.AddDirectedRelation(ctx, []string{"7", "1"}, model.StatusBlock)
+
+	testCases := []struct {
+		name     string
+		rq       model.PathRequest
+		maxDepth int
+		expect   []string
+	}{
+		{
+			name:     "Path found",
+			rq:       model.PathRequest{From: "a@gmail.com", To: "e@gmail.com"},
+			maxDepth: DefaultMaxPathDepth,
+			expect:   []string{"a@gmail.com", "b@gmail.com", "c@gmail.com", "d@gmail.com", "e@gmail.com"},
+		},
+		{
+			name:     "Path found around a block",
+			rq:       model.PathRequest{From: "f@gmail.com", To: "d@gmail.com"},
+			maxDepth: DefaultMaxPathDepth,
+			expect:   []string{"f@gmail.com", "b@gmail.com", "c@gmail.com", "d@gmail.com"},
+		},
+		{
+			name:     "Path longer than max depth",
+			rq:       model.PathRequest{From: "a@gmail.com", To: "e@gmail.com"},
+			maxDepth: 3,
+			expect:   nil,
+		},
+		{
+			name:     "Path to itself",
+			rq:       model.PathRequest{From: "a@gmail.com", To: "a@gmail.com"},
+			maxDepth: DefaultMaxPathDepth,
+			expect:   []string{"a@gmail.com"},
+		},
+		{
+			name:     "No path to a blocked email",
+			rq:       model.PathRequest{From: "a@gmail.com", To: "g@gmail.com"},
+			maxDepth: DefaultMaxPathDepth,
+			expect:   nil,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			service := NewRelationService(repo, WithMaxPathDepth(tc.maxDepth))
+
+			actual, err := service.GetPath(ctx, tc.rq)
+
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expect, actual)
+		})
+	}
+
+	_, err := NewRelationService(repo).GetPath(ctx, model.PathRequest{From: "a@gmail.com", To: "x@gmail.com"})
+	assert.Equal(t, "email: x@gmail.com is not exist in database", err.Error())
+}
+
+func TestGetPathLoadsFrontiers(t *testing.T) {
+	mockRepo := new(mocks.RelationRepo)
+	service := NewRelationService(mockRepo)
+	mockRepo.On("GetIdFromEmail", mock.Anything, "a@gmail.com").Return("1", nil)
+	mockRepo.On("GetIdFromEmail", mock.Anything, "d@gmail.com").Return("4", nil)
+	mockRepo.On("CheckIfExist", mock.Anything, mock.Anything, mock.Anything, model.StatusBlock).Return(false, nil)
+	mockRepo.On("GetFriendsOf", mock.Anything, []string{"1"}).Return(map[string][]model.User{
+		"1": {{Id: "2", Email: "b@gmail.com"}, {Id: "3", Email: "c@gmail.com"}},
+	}, nil).Once()
+	mockRepo.On("GetFriendsOf", mock.Anything, []string{"4"}).Return(map[string][]model.User{
+		"4": {{Id: "3", Email: "c@gmail.com"}},
+	}, nil).Once()
+
+	actual, err := service.GetPath(context.Background(), model.PathRequest{From: "a@gmail.com", To: "d@gmail.com"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a@gmail.com", "c@gmail.com", "d@gmail.com"}, actual)
+	mockRepo.AssertNumberOfCalls(t, "GetFriendsOf", 2)
+}
+
+func TestRegisterEmailBlock(t *testing.T) {
+	request := model.UserRequest{
+		Email: "quan12yt@gmail.com",
+	}
+
+	testCases := []struct {
+		name     string
+		mockId   string
+		err      error
+		expect   model.User
+		finalErr error
+	}{
+		{
+			name:   "Register email succeed",
+			mockId: "6",
+			expect: model.User{Id: "6", Email: "quan12yt@gmail.com"},
+		},
+		{
+			name:     "Register email already registered",
+			err:      repos.ErrDuplicate,
+			finalErr: errors.New("email: quan12yt@gmail.com is already registered"),
+		},
+		{
+			name:     "Register email failed",
+			err:      errors.New(""),
+			finalErr: errors.New(""),
 		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			mockRepo := new(mocks.RelationRepo)
 			service := NewRelationService(mockRepo)
-			mockRepo.On("GetIdFromEmail", mock.Anything).Return(tc.mockId, tc.err)
-			mockRepo.On("GetRetrivableEmails", mock.Anything).Return(tc.mockResponse, tc.finalErr)
+			mockRepo.On("CreateEmail", mock.Anything, request.Email).Return(tc.mockId, tc.err)
 
-			actual, err := service.RetrieveContactEmail(request)
+			actual, err := service.RegisterEmail(context.Background(), request)
 
 			assert.Equal(t, tc.finalErr, err)
-			assert.Equal(t, len(tc.expectResponse), len(actual))
+			assert.Equal(t, tc.expect, actual)
 		})
 	}
 }
+
+func TestGetUserBlock(t *testing.T) {
+	mockRepo := new(mocks.RelationRepo)
+	service := NewRelationService(mockRepo)
+	mockRepo.On("GetIdFromEmail", mock.Anything, "quan12yt@gmail.com").Return("1", nil)
+	mockRepo.On("GetIdFromEmail", mock.Anything, "quang@gmail.com").Return("", &repos.EmailNotFoundError{Email: "quang@gmail.com"})
+
+	user, err := service.GetUser(context.Background(), model.UserRequest{Email: "quan12yt@gmail.com"})
+	assert.Nil(t, err)
+	assert.Equal(t, model.User{Id: "1", Email: "quan12yt@gmail.com"}, user)
+
+	_, err = service.GetUser(context.Background(), model.UserRequest{Email: "quang@gmail.com"})
+	assert.Equal(t, "email: quang@gmail.com is not exist in database", err.Error())
+}
+
+func TestGetUsersBlock(t *testing.T) {
+	expect := []model.User{{Id: "1", Email: "quan12yt@gmail.com"}}
+	mockRepo := new(mocks.RelationRepo)
+	service := NewRelationService(mockRepo)
+	mockRepo.On("GetAllEmails", mock.Anything).Return(expect, nil)
+
+	actual, err := service.GetUsers(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, expect, actual)
+}
+
+func TestAutoProvision(t *testing.T) {
+	friends := model.AddAndGetCommonRequest{Friends: []string{"quan12yt@gmail.com", "quang@gmail.com"}}
+	subcribe := model.SubcribeAndBlockRequest{Requestor: "hau@gmail.com", Target: "quan12yt@gmail.com"}
+
+	repo := repos.NewRelationRepoMemory()
+	service := NewRelationService(repo)
+	_, err := service.Addfriend(context.Background(), friends)
+	assert.Equal(t, "email: quan12yt@gmail.com is not exist in database", err.Error())
+
+	service = NewRelationService(repo, WithAutoProvision(true))
+	ok, err := service.Addfriend(context.Background(), friends)
+	assert.Nil(t, err)
+	assert.Equal(t, true, ok)
+	_, err = service.SubcribeToEmail(context.Background(), subcribe)
+	assert.Nil(t, err)
+
+	users, err := service.GetUsers(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, []model.User{
+		{Id: "1", Email: "quan12yt@gmail.com"},
+		{Id: "2", Email: "quang@gmail.com"},
+		{Id: "3", Email: "hau@gmail.com"},
+	}, users)
+	recipients, err := service.RetrieveContactEmail(context.Background(), model.RetrieveRequest{Sender: "quan12yt@gmail.com", Text: "hello"})
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"quang@gmail.com", "hau@gmail.com"}, recipients.Emails)
+}
+
+func TestAutoProvisionRefused(t *testing.T) {
+	db, sqlMock := repos.DbMock()
+	service := NewRelationService(repos.NewRelationRepo(db), WithAutoProvision(true))
+	email_query := `select e.email_id from email e where e.email = $1`
+
+	// the email is registered in the transaction of the write, a refused
+	// write rolls it back
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(regexp.QuoteMeta(email_query)).WithArgs("hau@gmail.com").WillReturnError(sql.ErrNoRows)
+	sqlMock.ExpectQuery("insert into email").WithArgs("hau@gmail.com").
+		WillReturnRows(sqlmock.NewRows([]string{"email_id"}).AddRow("3"))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(email_query)).WithArgs("quang@gmail.com").
+		WillReturnRows(sqlmock.NewRows([]string{"email_id"}).AddRow("2"))
+	sqlMock.ExpectExec("for update").WithArgs("3", "2").WillReturnError(context.DeadlineExceeded)
+	sqlMock.ExpectRollback()
+
+	_, err := service.SubcribeToEmail(context.Background(), model.SubcribeAndBlockRequest{Requestor: "hau@gmail.com", Target: "quang@gmail.com"})
+
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Nil(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestAutoProvisionRegisteredMeanwhile(t *testing.T) {
+	db, sqlMock := repos.DbMock()
+	service := NewRelationService(repos.NewRelationRepo(db), WithAutoProvision(true))
+	email_query := `select e.email_id from email e where e.email = $1`
+	exist_query := `select fr.relation_id 
+	from friend_relationship fr 
+	where fr.your_id = $1 and fr.friend_id = $2 and status = $3`
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(regexp.QuoteMeta(email_query)).WithArgs("hau@gmail.com").WillReturnError(sql.ErrNoRows)
+	// a concurrent request registered the email, which aborts the transaction
+	sqlMock.ExpectQuery("insert into email").WithArgs("hau@gmail.com").
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "email_email_key"})
+	sqlMock.ExpectRollback()
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(regexp.QuoteMeta(email_query)).WithArgs("hau@gmail.com").
+		WillReturnRows(sqlmock.NewRows([]string{"email_id"}).AddRow("3"))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(email_query)).WithArgs("quang@gmail.com").
+		WillReturnRows(sqlmock.NewRows([]string{"email_id"}).AddRow("2"))
+	sqlMock.ExpectExec("for update").WithArgs("3", "2").WillReturnResult(sqlmock.NewResult(0, 2))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(exist_query)).WithArgs("3", "2", model.StatusBlock).
+		WillReturnRows(sqlmock.NewRows([]string{"relation_id"}))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(exist_query)).WithArgs("2", "3", model.StatusBlock).
+		WillReturnRows(sqlmock.NewRows([]string{"relation_id"}))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(exist_query)).WithArgs("3", "2", model.StatusFriend).
+		WillReturnRows(sqlmock.NewRows([]string{"relation_id"}).AddRow("7"))
+	sqlMock.ExpectRollback()
+
+	_, err := service.Addfriend(context.Background(), model.AddAndGetCommonRequest{Friends: []string{"hau@gmail.com", "quang@gmail.com"}})
+
+	assert.Equal(t, errors.New("2 emails are already being friend"), err)
+	assert.Nil(t, sqlMock.ExpectationsWereMet())
+}
+
+// mockTransaction makes Transaction run its function with mockRepo itself.
+func mockTransaction(mockRepo *mocks.RelationRepo) {
+	mockRepo.On("Transaction", mock.Anything, mock.Anything).Return(
+		func(ctx context.Context, fn func(repos.RelationRepo) error) error {
+			return fn(mockRepo)
+		})
+	mockRepo.On("LockEmails", mock.Anything, mock.Anything).Return(nil)
+}
@@ -3,29 +3,20 @@ package utils
 import (
 	"database/sql"
 	"fmt"
+	"friend-management-v1/internal/config"
 
 	_ "github.com/lib/pq"
 )
 
-const (
-	hostname      = "localhost"
-	host_port     = 5432
-	username      = "postgres"
-	password      = "quanpro99"
-	database_name = "friend_management"
-)
-
-func DBConnection() (db *sql.DB) {
-	connStr := fmt.Sprintf("host=%s port=%d user=%s "+
-		"password=%s dbname=%s sslmode=disable",
-		hostname, host_port, username, password, database_name)
-	db, err := sql.Open("postgres", connStr)
+func DBConnection(cfg config.DB) (*sql.DB, error) {
+	db, err := sql.Open("postgres", cfg.DSN())
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	if err = db.Ping(); err != nil {
-		panic(err)
+		db.Close()
+		return nil, err
 	}
 	fmt.Println("Connected to database")
-	return db
+	return db, nil
 }
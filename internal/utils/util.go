@@ -2,20 +2,36 @@ package utils
 
 import (
 	"errors"
+	"fmt"
 	"friend-management-v1/model"
+	"net/url"
 	"regexp"
+	"strconv"
 )
 
+// DefaultLimit is the page size of a list request without a limit and
+// MaxLimit the largest limit accepted.
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+// RetainSlices returns the values of slice1 that are also in slice2, once each
+// and in the order of slice1. The SQL repo intersects friend lists in the
+// database, this is only used by the backends that cannot.
 func RetainSlices(slice1 []string, slice2 []string) []string {
 	results := make([]string, 0) // slice tostore the result
 
-	for i := 0; i < len(slice1); i++ {
-		for k := 0; k < len(slice2); k++ {
-			if slice1[i] != slice2[k] {
-				continue
-			}
-			results = append(results, slice1[i])
+	in2 := make(map[string]bool, len(slice2))
+	for _, s := range slice2 {
+		in2[s] = true
+	}
+	for _, s := range slice1 {
+		if !in2[s] {
+			continue
 		}
+		results = append(results, s)
+		delete(in2, s)
 	}
 	return results
 }
@@ -61,6 +77,23 @@ func ValidateAddComonRequest(rq model.AddAndGetCommonRequest) error {
 	return nil
 }
 
+// ValidateCommonRequest checks a common friends request, which takes two or
+// more different emails.
+func ValidateCommonRequest(rq model.AddAndGetCommonRequest) error {
+	if len(rq.Friends) < 2 {
+		return errors.New("must contain at least 2 emails")
+	}
+	for _, email := range rq.Friends {
+		if !IsEmailValid(email) {
+			return errors.New("invalid email format")
+		}
+	}
+	if len(Unique(rq.Friends)) < 2 {
+		return errors.New("must contain at least 2 different emails")
+	}
+	return nil
+}
+
 func ValidateSubcribeAndBlockRequest(rq model.SubcribeAndBlockRequest) error {
 	if rq.Requestor == "" || rq.Target == "" {
 		return errors.New("requestor and target must not be empty")
@@ -78,5 +111,100 @@ func ValidateRetrieveRequest(rq model.RetrieveRequest) error {
 	if !IsEmailValid(rq.Sender) {
 		return errors.New("invalid email format")
 	}
+	if rq.Post && rq.Cursor != "" {
+		// the update is posted with the first page, the next ones would post it again
+		return errors.New("post must not be used with cursor")
+	}
+	return nil
+}
+
+func ValidateUserRequest(rq model.UserRequest) error {
+	if rq.Email == "" {
+		return errors.New("email must not be empty")
+	}
+	if !IsEmailValid(rq.Email) {
+		return errors.New("invalid email format")
+	}
+	return nil
+}
+
+func ValidateSuggestionsRequest(rq model.SuggestionsRequest) error {
+	if err := ValidateUserRequest(model.UserRequest{Email: rq.Email}); err != nil {
+		return err
+	}
+	return ValidateLimit(rq.Limit)
+}
+
+func ValidatePathRequest(rq model.PathRequest) error {
+	if rq.From == "" || rq.To == "" {
+		return errors.New("from and to must not be empty")
+	}
+	if !IsEmailValid(rq.From) || !IsEmailValid(rq.To) {
+		return errors.New("invalid email format")
+	}
+	return nil
+}
+
+func ValidateInboxRequest(rq model.InboxRequest) error {
+	if err := ValidateUserRequest(model.UserRequest{Email: rq.Email}); err != nil {
+		return err
+	}
+	return ValidateLimit(rq.Limit)
+}
+
+func ValidateMarkReadRequest(rq model.MarkReadRequest) error {
+	if err := ValidateUserRequest(model.UserRequest{Email: rq.Email}); err != nil {
+		return err
+	}
+	if len(rq.Ids) == 0 {
+		return errors.New("ids must not be empty")
+	}
+	for _, id := range rq.Ids {
+		if i, err := strconv.ParseInt(id, 10, 64); err != nil || i < 1 {
+			return fmt.Errorf("invalid inbox item id: %s", id)
+		}
+	}
+	return nil
+}
+
+// ValidateWebhookRequest checks the email and the url, which is empty to
+// remove the webhook or an absolute http or https URL.
+func ValidateWebhookRequest(rq model.WebhookRequest) error {
+	if err := ValidateUserRequest(model.UserRequest{Email: rq.Email}); err != nil {
+		return err
+	}
+	if rq.Url == "" {
+		return nil
+	}
+	u, err := url.Parse(rq.Url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an http or https URL")
+	}
+	return nil
+}
+
+// ValidateDeliveriesRequest checks the status, which may be empty for the
+// dead-letter list, and the limit.
+func ValidateDeliveriesRequest(rq model.DeliveriesRequest) error {
+	if rq.Status != "" && !rq.Status.IsValid() {
+		return fmt.Errorf("invalid delivery status: %s", rq.Status)
+	}
+	return ValidateLimit(rq.Limit)
+}
+
+func ValidateAuditRequest(rq model.AuditRequest) error {
+	if err := ValidateUserRequest(model.UserRequest{Email: rq.Email}); err != nil {
+		return err
+	}
+	if !rq.From.IsZero() && !rq.To.IsZero() && !rq.From.Before(rq.To) {
+		return errors.New("from must be before to")
+	}
+	return ValidateLimit(rq.Limit)
+}
+
+func ValidateLimit(limit int) error {
+	if limit < 0 || limit > MaxLimit {
+		return fmt.Errorf("limit must be between 0 and %d", MaxLimit)
+	}
 	return nil
 }
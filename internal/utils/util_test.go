@@ -3,6 +3,7 @@ package utils
 import (
 	"friend-management-v1/model"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -163,3 +164,148 @@ func TestValidateRetrieveRequestInvalidEmail(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.Equal(t, "invalid email format", err.Error())
 }
+
+func TestValidateRetrieveRequestPostWithCursor(t *testing.T) {
+
+	rq := model.RetrieveRequest{
+		Sender: "qu@gmail.com",
+		Text:   "qwe",
+		Post:   true,
+		Cursor: "Mg",
+	}
+	err := ValidateRetrieveRequest(rq)
+
+	assert.NotNil(t, err)
+	assert.Equal(t, "post must not be used with cursor", err.Error())
+}
+
+func TestValidateUserRequestOk(t *testing.T) {
+
+	rq := model.UserRequest{
+		Email: "qu@gmail.com",
+	}
+	err := ValidateUserRequest(rq)
+
+	assert.Nil(t, err)
+}
+
+func TestValidateUserRequestEmpty(t *testing.T) {
+
+	rq := model.UserRequest{
+		Email: "",
+	}
+	err := ValidateUserRequest(rq)
+
+	assert.NotNil(t, err)
+	assert.Equal(t, "email must not be empty", err.Error())
+}
+
+func TestValidateUserRequestInvalidEmail(t *testing.T) {
+
+	rq := model.UserRequest{
+		Email: "weqw",
+	}
+	err := ValidateUserRequest(rq)
+
+	assert.NotNil(t, err)
+	assert.Equal(t, "invalid email format", err.Error())
+}
+
+func TestValidateLimit(t *testing.T) {
+	assert.Nil(t, ValidateLimit(0))
+	assert.Nil(t, ValidateLimit(MaxLimit))
+
+	err := ValidateLimit(MaxLimit + 1)
+	assert.NotNil(t, err)
+	assert.Equal(t, "limit must be between 0 and 1000", err.Error())
+	assert.NotNil(t, ValidateLimit(-1))
+}
+
+func TestRetainSlicesDuplicates(t *testing.T) {
+	actual := RetainSlices([]string{"3", "1", "2", "1"}, []string{"1", "2", "2", "4"})
+
+	assert.Equal(t, []string{"1", "2"}, actual)
+}
+
+func TestValidateCommonRequest(t *testing.T) {
+	rq := model.AddAndGetCommonRequest{
+		Friends: []string{"qu@gmail.com", "quan@gmail.com", "hau@gmail.com"},
+	}
+	assert.Nil(t, ValidateCommonRequest(rq))
+
+	rq.Friends = []string{"qu@gmail.com"}
+	err := ValidateCommonRequest(rq)
+	assert.NotNil(t, err)
+	assert.Equal(t, "must contain at least 2 emails", err.Error())
+
+	rq.Friends = []string{"qu@gmail.com", "quan@gmail.com", "hau"}
+	err = ValidateCommonRequest(rq)
+	assert.NotNil(t, err)
+	assert.Equal(t, "invalid email format", err.Error())
+
+	rq.Friends = []string{"a@x.com", "a@x.com"}
+	err = ValidateCommonRequest(rq)
+	assert.NotNil(t, err)
+	assert.Equal(t, "must contain at least 2 different emails", err.Error())
+
+	rq.Friends = []string{"a@x.com", "b@x.com", "a@x.com"}
+	assert.Nil(t, ValidateCommonRequest(rq))
+}
+
+func TestValidatePathRequest(t *testing.T) {
+	assert.Nil(t, ValidatePathRequest(model.PathRequest{From: "qu@gmail.com", To: "quan@gmail.com"}))
+
+	err := ValidatePathRequest(model.PathRequest{From: "qu@gmail.com"})
+	assert.NotNil(t, err)
+	assert.Equal(t, "from and to must not be empty", err.Error())
+
+	err = ValidatePathRequest(model.PathRequest{From: "qu@gmail.com", To: "quan"})
+	assert.NotNil(t, err)
+	assert.Equal(t, "invalid email format", err.Error())
+}
+
+func TestValidateMarkReadRequest(t *testing.T) {
+	assert.Nil(t, ValidateMarkReadRequest(model.MarkReadRequest{Email: "qu@gmail.com", Ids: []string{"1", "20"}}))
+
+	err := ValidateMarkReadRequest(model.MarkReadRequest{Email: "qu@gmail.com"})
+	assert.NotNil(t, err)
+	assert.Equal(t, "ids must not be empty", err.Error())
+
+	err = ValidateMarkReadRequest(model.MarkReadRequest{Email: "qu@gmail.com", Ids: []string{"1", "0"}})
+	assert.NotNil(t, err)
+	assert.Equal(t, "invalid inbox item id: 0", err.Error())
+
+	err = ValidateMarkReadRequest(model.MarkReadRequest{Email: "qu", Ids: []string{"1"}})
+	assert.NotNil(t, err)
+	assert.Equal(t, "invalid email format", err.Error())
+}
+
+func TestValidateWebhookRequest(t *testing.T) {
+	assert.Nil(t, ValidateWebhookRequest(model.WebhookRequest{Email: "qu@gmail.com", Url: "https://example.com/hook"}))
+	assert.Nil(t, ValidateWebhookRequest(model.WebhookRequest{Email: "qu@gmail.com"}))
+
+	for _, url := range []string{"example.com/hook", "ftp://example.com", "http://", "://bad"} {
+		err := ValidateWebhookRequest(model.WebhookRequest{Email: "qu@gmail.com", Url: url})
+		assert.NotNil(t, err, url)
+		assert.Equal(t, "url must be an http or https URL", err.Error())
+	}
+}
+
+func TestValidateAuditRequest(t *testing.T) {
+	from := time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)
+	assert.Nil(t, ValidateAuditRequest(model.AuditRequest{Email: "qu@gmail.com"}))
+	assert.Nil(t, ValidateAuditRequest(model.AuditRequest{Email: "qu@gmail.com", From: from, To: from.Add(time.Hour)}))
+
+	err := ValidateAuditRequest(model.AuditRequest{Email: "qu@gmail.com", From: from, To: from})
+	assert.NotNil(t, err)
+	assert.Equal(t, "from must be before to", err.Error())
+}
+
+func TestValidateDeliveriesRequest(t *testing.T) {
+	assert.Nil(t, ValidateDeliveriesRequest(model.DeliveriesRequest{}))
+	assert.Nil(t, ValidateDeliveriesRequest(model.DeliveriesRequest{Status: model.DeliveryPending, Limit: 10}))
+
+	err := ValidateDeliveriesRequest(model.DeliveriesRequest{Status: "LOST"})
+	assert.NotNil(t, err)
+	assert.Equal(t, "invalid delivery status: LOST", err.Error())
+}
@@ -0,0 +1,386 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"friend-management-v1/internal/event"
+	"friend-management-v1/internal/repos"
+	"friend-management-v1/model"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Defaults of a Dispatcher built without options.
+const (
+	DefaultWorkers     = 4
+	DefaultQueueSize   = 1000
+	DefaultMaxAttempts = 5
+	DefaultBackoff     = time.Second
+	DefaultMaxBackoff  = time.Minute
+	DefaultTimeout     = 10 * time.Second
+)
+
+// ErrClosed is returned by Notify once the Dispatcher is closed.
+var ErrClosed = errors.New("webhook dispatcher is closed")
+
+// ErrQueueFull is returned by Notify when a delivery could not be queued, the
+// delivery is left DEAD.
+var ErrQueueFull = errors.New("webhook queue is full")
+
+// Dispatcher POSTs posted updates to the webhooks of their recipients in the
+// background. Every delivery is tried up to maxAttempts times, queued again
+// after backoff, 2*backoff, 4*backoff and so on up to maxBackoff, so the
+// workers deliver the other webhooks meanwhile.
+// Each attempt is recorded, and a delivery whose attempts all failed is left
+// DEAD in the dead-letter list.
+type Dispatcher struct {
+	repo        repos.WebhookRepo
+	updates     repos.UpdateRepo
+	client      *http.Client
+	workers     int
+	queueSize   int
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+
+	jobs chan job
+	mu   sync.Mutex
+	// queued holds the ids of the deliveries queued or being attempted.
+	queued map[string]bool
+	// ctx is canceled by Close, which stops the attempts in flight.
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type job struct {
+	delivery model.Delivery
+	update   model.Update
+}
+
+// Option configures a Dispatcher.
+type Option func(d *Dispatcher)
+
+// WithWorkers sets how many deliveries are attempted at the same time.
+func WithWorkers(workers int) Option {
+	return func(d *Dispatcher) {
+		d.workers = workers
+	}
+}
+
+// WithQueueSize sets how many deliveries wait for a worker, Notify fails
+// with ErrQueueFull beyond.
+func WithQueueSize(size int) Option {
+	return func(d *Dispatcher) {
+		d.queueSize = size
+	}
+}
+
+// WithRetries sets the number of attempts of a delivery and the wait after the
+// first failed attempt, which doubles after each failure up to maxBackoff.
+func WithRetries(maxAttempts int, backoff time.Duration, maxBackoff time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.maxAttempts = maxAttempts
+		d.backoff = backoff
+		d.maxBackoff = maxBackoff
+	}
+}
+
+// WithClient sends the requests with client, the timeout of the client bounds
+// each attempt.
+func WithClient(client *http.Client) Option {
+	return func(d *Dispatcher) {
+		d.client = client
+	}
+}
+
+// WithResume queues again the deliveries left PENDING by a previous run, such
+// as the ones still queued when it was closed, reading their update in
+// updates. A delivery may then be sent twice when several servers share the
+// storage, the receivers can tell by the X-Delivery-Id header.
+func WithResume(updates repos.UpdateRepo) Option {
+	return func(d *Dispatcher) {
+		d.updates = updates
+	}
+}
+
+// resumePageSize is the number of PENDING deliveries read at once on resume.
+const resumePageSize = 100
+
+// NewDispatcher starts the workers of a Dispatcher storing its deliveries in
+// repo. Close stops them.
+func NewDispatcher(repo repos.WebhookRepo, opts ...Option) *Dispatcher {
+	d := &Dispatcher{
+		repo:        repo,
+		client:      &http.Client{Timeout: DefaultTimeout},
+		workers:     DefaultWorkers,
+		queueSize:   DefaultQueueSize,
+		maxAttempts: DefaultMaxAttempts,
+		backoff:     DefaultBackoff,
+		maxBackoff:  DefaultMaxBackoff,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	d.jobs = make(chan job, d.queueSize)
+	d.queued = make(map[string]bool)
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+	for i := 0; i < d.workers; i++ {
+		d.wg.Add(1)
+		go d.work()
+	}
+	if d.updates != nil {
+		// the deliveries are listed before Notify adds new PENDING ones
+		pending := d.pending()
+		d.wg.Add(1)
+		go d.resume(pending)
+	}
+	return d
+}
+
+// pending returns the PENDING deliveries stored in the repository.
+func (d *Dispatcher) pending() []model.Delivery {
+	var pending []model.Delivery
+	page := model.Page{Limit: resumePageSize}
+	for {
+		deliveries, err := d.repo.GetDeliveries(d.ctx, model.DeliveryPending, page)
+		if err != nil {
+			log.Printf("webhook: resume: %v", err)
+			return pending
+		}
+		pending = append(pending, deliveries.Deliveries...)
+		if deliveries.NextCursor == "" {
+			return pending
+		}
+		page.Cursor = deliveries.NextCursor
+	}
+}
+
+// resume queues the pending deliveries, waiting for room in the queue.
+func (d *Dispatcher) resume(pending []model.Delivery) {
+	defer d.wg.Done()
+	updates := make(map[string]model.Update)
+	for _, delivery := range pending {
+		update, ok := updates[delivery.UpdateId]
+		if !ok {
+			var err error
+			update, err = d.updates.GetUpdate(d.ctx, delivery.UpdateId)
+			if err == repos.ErrUpdateNotFound {
+				d.drop(d.ctx, delivery, err)
+				continue
+			}
+			if err != nil {
+				log.Printf("webhook: resume: delivery %s: %v", delivery.Id, err)
+				return
+			}
+			updates[delivery.UpdateId] = update
+		}
+		if err := d.enqueue(job{delivery: delivery, update: update}, true); err != nil {
+			return
+		}
+	}
+}
+
+// Notify stores a delivery of update to the webhook of each recipient that
+// has one and queues them. It returns once the deliveries are queued, not
+// delivered, and does not wait for room in the queue: the deliveries that do
+// not fit are left DEAD and Notify returns ErrQueueFull.
+func (d *Dispatcher) Notify(ctx context.Context, update model.Update, recipients []string) error {
+	if d.ctx.Err() != nil {
+		return ErrClosed
+	}
+	webhooks, err := d.repo.GetWebhooks(ctx, recipients)
+	if err != nil || len(webhooks) == 0 {
+		return err
+	}
+	deliveries, err := d.repo.CreateDeliveries(ctx, update.Id, webhooks)
+	if err != nil {
+		return err
+	}
+	var failed error
+	for _, delivery := range deliveries {
+		if err := d.enqueue(job{delivery: delivery, update: update}, false); err != nil {
+			d.drop(ctx, delivery, err)
+			failed = err
+		}
+	}
+	return failed
+}
+
+// enqueue queues j unless it is already queued. With wait it waits for room
+// in the queue until the Dispatcher is closed, otherwise it fails with
+// ErrQueueFull at once.
+func (d *Dispatcher) enqueue(j job, wait bool) error {
+	d.mu.Lock()
+	if d.queued[j.delivery.Id] {
+		d.mu.Unlock()
+		return nil
+	}
+	d.queued[j.delivery.Id] = true
+	d.mu.Unlock()
+
+	var err error
+	if wait {
+		select {
+		case d.jobs <- j:
+		case <-d.ctx.Done():
+			err = ErrClosed
+		}
+	} else {
+		select {
+		case d.jobs <- j:
+		default:
+			err = ErrQueueFull
+		}
+	}
+	if err != nil {
+		d.done(j.delivery.Id)
+	}
+	return err
+}
+
+// done forgets the delivery id once it is no longer queued.
+func (d *Dispatcher) done(id string) {
+	d.mu.Lock()
+	delete(d.queued, id)
+	d.mu.Unlock()
+}
+
+// drop records why delivery was not sent and leaves it DEAD.
+func (d *Dispatcher) drop(ctx context.Context, delivery model.Delivery, reason error) {
+	attempt := model.DeliveryAttempt{
+		Attempt:     len(delivery.Attempts) + 1,
+		Error:       "not sent: " + reason.Error(),
+		AttemptedAt: time.Now(),
+	}
+	if err := d.repo.AddAttempt(ctx, delivery.Id, attempt, model.DeliveryDead); err != nil {
+		log.Printf("webhook: delivery %s: %v", delivery.Id, err)
+	}
+}
+
+// Handle is the event.Handler of the Dispatcher, it notifies the recipients of
+// UpdatePosted events and ignores the other events.
+func (d *Dispatcher) Handle(ctx context.Context, e event.Event) error {
+	if posted, ok := e.(event.UpdatePosted); ok {
+		return d.Notify(ctx, posted.Update, posted.Recipients)
+	}
+	return nil
+}
+
+// Close stops the workers and the retries and waits for them. Deliveries
+// still queued or waiting for a retry are left PENDING, for the next
+// Dispatcher built WithResume.
+func (d *Dispatcher) Close() error {
+	d.cancel()
+	d.wg.Wait()
+	return nil
+}
+
+func (d *Dispatcher) work() {
+	defer d.wg.Done()
+	for {
+		select {
+		case j := <-d.jobs:
+			if d.deliver(&j) {
+				d.wg.Add(1)
+				go d.retry(j)
+			} else {
+				d.done(j.delivery.Id)
+			}
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
+
+// deliver makes the next attempt of j, records it and adds it to j.delivery,
+// the attempts of a resumed delivery go on from the ones already recorded. It
+// tells whether j failed with attempts left.
+func (d *Dispatcher) deliver(j *job) bool {
+	body, err := json.Marshal(model.DeliveryPayload{
+		DeliveryId: j.delivery.Id,
+		Recipient:  j.delivery.Recipient,
+		Update:     j.update,
+	})
+	if err != nil {
+		log.Printf("webhook: delivery %s: %v", j.delivery.Id, err)
+		return false
+	}
+	attempt := len(j.delivery.Attempts) + 1
+	result := d.post(j.delivery, body)
+	result.Attempt = attempt
+	status := model.DeliveryPending
+	switch {
+	case result.Error == "":
+		status = model.DeliveryDelivered
+	case attempt >= d.maxAttempts:
+		status = model.DeliveryDead
+	}
+	if err := d.repo.AddAttempt(d.ctx, j.delivery.Id, result, status); err != nil {
+		log.Printf("webhook: delivery %s: %v", j.delivery.Id, err)
+	}
+	// the attempts may be shared with the caller of Notify, they are copied
+	j.delivery.Attempts = append(j.delivery.Attempts[:attempt-1:attempt-1], result)
+	return status == model.DeliveryPending
+}
+
+// retry queues j again once the backoff of its last attempt is over, the
+// workers deliver the other jobs meanwhile. The delivery stays PENDING when
+// the Dispatcher is closed first.
+func (d *Dispatcher) retry(j job) {
+	defer d.wg.Done()
+	timer := time.NewTimer(d.backoffOf(len(j.delivery.Attempts)))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-d.ctx.Done():
+		d.done(j.delivery.Id)
+		return
+	}
+	select {
+	case d.jobs <- j:
+	case <-d.ctx.Done():
+		d.done(j.delivery.Id)
+	}
+}
+
+// post sends one attempt of delivery, the returned attempt holds the status
+// code of the response or the error that prevented one.
+func (d *Dispatcher) post(delivery model.Delivery, body []byte) model.DeliveryAttempt {
+	attempt := model.DeliveryAttempt{AttemptedAt: time.Now()}
+	rq, err := http.NewRequestWithContext(d.ctx, http.MethodPost, delivery.Url, bytes.NewReader(body))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	rq.Header.Set("Content-Type", "application/json")
+	rq.Header.Set("X-Delivery-Id", delivery.Id)
+	resp, err := d.client.Do(rq)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	resp.Body.Close()
+	attempt.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		attempt.Error = fmt.Sprintf("unexpected status %s", resp.Status)
+	}
+	return attempt
+}
+
+// backoffOf returns the wait after the failure of attempt.
+func (d *Dispatcher) backoffOf(attempt int) time.Duration {
+	wait := d.backoff
+	for i := 1; i < attempt && wait < d.maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > d.maxBackoff {
+		wait = d.maxBackoff
+	}
+	return wait
+}
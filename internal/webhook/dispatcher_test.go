@@ -0,0 +1,233 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"friend-management-v1/internal/event"
+	"friend-management-v1/internal/repos"
+	"friend-management-v1/model"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// receiver records the payloads POSTed to it and answers the first failures
+// of them with 503.
+type receiver struct {
+	mu       sync.Mutex
+	failures int
+	payloads []model.DeliveryPayload
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var payload model.DeliveryPayload
+	json.NewDecoder(r.Body).Decode(&payload)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.payloads = append(rc.payloads, payload)
+	if len(rc.payloads) <= rc.failures {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (rc *receiver) received() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return len(rc.payloads)
+}
+
+func newDispatcher(t *testing.T) (*Dispatcher, *repos.WebhookRepoMemory, *repos.RelationRepoMemory) {
+	emails := repos.NewRelationRepoMemory()
+	repo := repos.NewWebhookRepoMemory(emails)
+	d := NewDispatcher(repo, WithWorkers(2), WithRetries(3, time.Millisecond, 4*time.Millisecond))
+	t.Cleanup(func() { d.Close() })
+	return d, repo, emails
+}
+
+func deliveries(t *testing.T, repo repos.WebhookRepo, status model.DeliveryStatus) []model.Delivery {
+	page, err := repo.GetDeliveries(context.Background(), status, model.Page{Limit: 10})
+	assert.Nil(t, err)
+	return page.Deliveries
+}
+
+func TestDispatcherRetries(t *testing.T) {
+	ctx := context.Background()
+	d, repo, emails := newDispatcher(t)
+	flaky := &receiver{failures: 2}
+	server := httptest.NewServer(flaky)
+	defer server.Close()
+	assert.Nil(t, repo.SetWebhook(ctx, emails.AddEmail("quang@gmail.com"), server.URL))
+	emails.AddEmail("hau@gmail.com")
+
+	update := model.Update{Id: "1", Sender: "quan12yt@gmail.com", Text: "hello"}
+	assert.Nil(t, d.Notify(ctx, update, []string{"quang@gmail.com", "hau@gmail.com"}))
+
+	assert.Eventually(t, func() bool {
+		return len(deliveries(t, repo, model.DeliveryDelivered)) == 1
+	}, time.Second, time.Millisecond)
+	delivery := deliveries(t, repo, model.DeliveryDelivered)[0]
+	assert.Equal(t, "quang@gmail.com", delivery.Recipient)
+	assert.Equal(t, []int{503, 503, 204}, statusCodesOf(delivery.Attempts))
+	assert.Equal(t, 3, flaky.received())
+	assert.Equal(t, model.DeliveryPayload{DeliveryId: delivery.Id, Recipient: "quang@gmail.com", Update: update}, flaky.payloads[2])
+}
+
+func TestDispatcherDeadLetter(t *testing.T) {
+	ctx := context.Background()
+	d, repo, emails := newDispatcher(t)
+	down := &receiver{failures: 10}
+	server := httptest.NewServer(down)
+	defer server.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	assert.Nil(t, repo.SetWebhook(ctx, emails.AddEmail("quang@gmail.com"), server.URL))
+	assert.Nil(t, repo.SetWebhook(ctx, emails.AddEmail("hau@gmail.com"), closed.URL))
+
+	assert.Nil(t, d.Notify(ctx, model.Update{Id: "1"}, []string{"quang@gmail.com", "hau@gmail.com"}))
+
+	assert.Eventually(t, func() bool {
+		return len(deliveries(t, repo, model.DeliveryDead)) == 2
+	}, time.Second, time.Millisecond)
+	dead := deliveries(t, repo, model.DeliveryDead)
+	assert.Equal(t, []int{503, 503, 503}, statusCodesOf(dead[0].Attempts))
+	assert.Equal(t, []int{0, 0, 0}, statusCodesOf(dead[1].Attempts))
+	assert.NotEmpty(t, dead[1].Attempts[0].Error)
+	assert.Equal(t, 3, down.received())
+}
+
+func TestDispatcherRetryDoesNotHoldUpWorkers(t *testing.T) {
+	ctx := context.Background()
+	emails := repos.NewRelationRepoMemory()
+	repo := repos.NewWebhookRepoMemory(emails)
+	d := NewDispatcher(repo, WithWorkers(1), WithRetries(5, time.Minute, time.Minute))
+	defer d.Close()
+	down := &receiver{failures: 10}
+	healthy := &receiver{}
+	downServer := httptest.NewServer(down)
+	defer downServer.Close()
+	healthyServer := httptest.NewServer(healthy)
+	defer healthyServer.Close()
+	assert.Nil(t, repo.SetWebhook(ctx, emails.AddEmail("quang@gmail.com"), downServer.URL))
+	assert.Nil(t, repo.SetWebhook(ctx, emails.AddEmail("hau@gmail.com"), healthyServer.URL))
+
+	assert.Nil(t, d.Notify(ctx, model.Update{Id: "1"}, []string{"quang@gmail.com"}))
+	assert.Eventually(t, func() bool { return down.received() == 1 }, time.Second, time.Millisecond)
+	assert.Nil(t, d.Notify(ctx, model.Update{Id: "2"}, []string{"hau@gmail.com"}))
+
+	// the only worker delivers the second update while the first waits a
+	// minute for its retry
+	assert.Eventually(t, func() bool {
+		return len(deliveries(t, repo, model.DeliveryDelivered)) == 1
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, 1, healthy.received())
+	assert.Equal(t, 1, down.received())
+	pending := deliveries(t, repo, model.DeliveryPending)
+	assert.Len(t, pending, 1)
+	assert.Equal(t, []int{503}, statusCodesOf(pending[0].Attempts))
+}
+
+func TestDispatcherHandle(t *testing.T) {
+	ctx := context.Background()
+	d, repo, emails := newDispatcher(t)
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+	assert.Nil(t, repo.SetWebhook(ctx, emails.AddEmail("quang@gmail.com"), server.URL))
+
+	assert.Nil(t, d.Handle(ctx, event.FriendAdded{Actor: "quan12yt@gmail.com", Target: "quang@gmail.com"}))
+	assert.Nil(t, d.Handle(ctx, event.UpdatePosted{Update: model.Update{Id: "1"}, Recipients: []string{"quang@gmail.com"}}))
+
+	assert.Eventually(t, func() bool {
+		return len(deliveries(t, repo, model.DeliveryDelivered)) == 1
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, 1, rc.received())
+}
+
+func TestDispatcherClosed(t *testing.T) {
+	d, _, _ := newDispatcher(t)
+	d.Close()
+
+	assert.Equal(t, ErrClosed, d.Notify(context.Background(), model.Update{Id: "1"}, []string{"quang@gmail.com"}))
+}
+
+func TestDispatcherQueueFull(t *testing.T) {
+	ctx := context.Background()
+	emails := repos.NewRelationRepoMemory()
+	repo := repos.NewWebhookRepoMemory(emails)
+	d := NewDispatcher(repo, WithWorkers(0), WithQueueSize(1))
+	defer d.Close()
+	assert.Nil(t, repo.SetWebhook(ctx, emails.AddEmail("quang@gmail.com"), "http://localhost/quang"))
+	assert.Nil(t, repo.SetWebhook(ctx, emails.AddEmail("hau@gmail.com"), "http://localhost/hau"))
+
+	err := d.Notify(ctx, model.Update{Id: "1"}, []string{"quang@gmail.com", "hau@gmail.com"})
+
+	assert.Equal(t, ErrQueueFull, err)
+	assert.Len(t, deliveries(t, repo, model.DeliveryPending), 1)
+	dead := deliveries(t, repo, model.DeliveryDead)
+	assert.Len(t, dead, 1)
+	assert.Equal(t, "hau@gmail.com", dead[0].Recipient)
+	assert.Equal(t, "not sent: webhook queue is full", dead[0].Attempts[0].Error)
+}
+
+func TestDispatcherResume(t *testing.T) {
+	ctx := context.Background()
+	emails := repos.NewRelationRepoMemory()
+	repo := repos.NewWebhookRepoMemory(emails)
+	updates := repos.NewUpdateRepoMemory(emails)
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+	sender := emails.AddEmail("quan12yt@gmail.com")
+	assert.Nil(t, repo.SetWebhook(ctx, emails.AddEmail("quang@gmail.com"), server.URL))
+	update, err := updates.CreateUpdate(ctx, model.User{Id: sender, Email: "quan12yt@gmail.com"}, "hello", []string{"quang@gmail.com"})
+	assert.Nil(t, err)
+	webhooks, err := repo.GetWebhooks(ctx, []string{"quang@gmail.com"})
+	assert.Nil(t, err)
+	// a delivery attempted once, then left by a closed dispatcher, and one of an unknown update
+	left, err := repo.CreateDeliveries(ctx, update.Id, webhooks)
+	assert.Nil(t, err)
+	assert.Nil(t, repo.AddAttempt(ctx, left[0].Id, model.DeliveryAttempt{Attempt: 1, StatusCode: 503, Error: "unexpected status 503"}, model.DeliveryPending))
+	_, err = repo.CreateDeliveries(ctx, "99", webhooks)
+	assert.Nil(t, err)
+
+	d := NewDispatcher(repo, WithWorkers(1), WithResume(updates))
+	defer d.Close()
+
+	assert.Eventually(t, func() bool {
+		return len(deliveries(t, repo, model.DeliveryDelivered)) == 1
+	}, time.Second, time.Millisecond)
+	delivered := deliveries(t, repo, model.DeliveryDelivered)[0]
+	assert.Equal(t, left[0].Id, delivered.Id)
+	assert.Equal(t, []int{503, 204}, statusCodesOf(delivered.Attempts))
+	assert.Equal(t, 2, delivered.Attempts[1].Attempt)
+	assert.Equal(t, update.Id, rc.payloads[0].Update.Id)
+	assert.Equal(t, "hello", rc.payloads[0].Update.Text)
+	dead := deliveries(t, repo, model.DeliveryDead)
+	assert.Len(t, dead, 1)
+	assert.Equal(t, "not sent: update is not found", dead[0].Attempts[0].Error)
+	assert.Empty(t, deliveries(t, repo, model.DeliveryPending))
+}
+
+func TestBackoff(t *testing.T) {
+	d := &Dispatcher{backoff: time.Second, maxBackoff: 5 * time.Second}
+
+	var waits []time.Duration
+	for attempt := 1; attempt <= 5; attempt++ {
+		waits = append(waits, d.backoffOf(attempt))
+	}
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}, waits)
+}
+
+func statusCodesOf(attempts []model.DeliveryAttempt) []int {
+	codes := []int{}
+	for _, attempt := range attempts {
+		codes = append(codes, attempt.StatusCode)
+	}
+	return codes
+}
@@ -0,0 +1,121 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress is returned for a webhook pointing to an address that is
+// not public, such as a loopback, link-local or private address.
+var ErrPrivateAddress = errors.New("url must not point to a private address")
+
+// privateNetworks are the networks that are not reachable from the internet,
+// beside the loopback, link-local, multicast and unspecified addresses.
+var privateNetworks = mustParseCIDRs(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"fc00::/7",
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks[i] = network
+	}
+	return networks
+}
+
+// Guard keeps the webhooks away from the internal services: it only lets
+// them reach public addresses, and the addresses of the allowed networks.
+type Guard struct {
+	allowed []*net.IPNet
+}
+
+// NewGuard returns a Guard letting the webhooks reach allowed beside the
+// public addresses.
+func NewGuard(allowed []*net.IPNet) *Guard {
+	return &Guard{allowed: allowed}
+}
+
+// Allowed tells whether the webhooks may reach ip.
+func (g *Guard) Allowed(ip net.IP) bool {
+	for _, network := range g.allowed {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, network := range privateNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckURL rejects a webhook URL whose host is an address the webhooks may
+// not reach, or localhost. The addresses a host name resolves to are checked
+// when the deliveries are sent.
+func (g *Guard) CheckURL(rawurl string) error {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return err
+	}
+	host := strings.ToLower(u.Hostname())
+	if ip := net.ParseIP(host); ip != nil {
+		if !g.Allowed(ip) {
+			return ErrPrivateAddress
+		}
+		return nil
+	}
+	if (host == "localhost" || strings.HasSuffix(host, ".localhost")) && !g.Allowed(net.IPv4(127, 0, 0, 1)) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// Client returns an HTTP client whose attempts last at most timeout and that
+// refuses to connect to the addresses the webhooks may not reach, whatever
+// the host name resolved to, redirects included. It does not go through the
+// proxy of the environment, which would connect in its place.
+func (g *Guard) Client(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network string, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !g.Allowed(ip) {
+				return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConnsPerHost: 2,
+		},
+	}
+}
@@ -0,0 +1,66 @@
+package webhook
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGuardAllowed(t *testing.T) {
+	g := NewGuard(mustParseCIDRs("10.1.0.0/16"))
+	testCases := []struct {
+		ip      string
+		allowed bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"10.1.2.3", true},
+		{"10.2.0.1", false},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"0.0.0.0", false},
+		{"169.254.169.254", false},
+		{"172.16.5.4", false},
+		{"192.168.1.1", false},
+		{"100.64.0.1", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.allowed, g.Allowed(net.ParseIP(tc.ip)), tc.ip)
+	}
+}
+
+func TestGuardCheckURL(t *testing.T) {
+	g := NewGuard(nil)
+
+	assert.Nil(t, g.CheckURL("https://example.com/hooks/quang"))
+	assert.Nil(t, g.CheckURL("http://93.184.216.34:8080/hook"))
+	assert.Equal(t, ErrPrivateAddress, g.CheckURL("http://127.0.0.1:9000/hook"))
+	assert.Equal(t, ErrPrivateAddress, g.CheckURL("http://[::1]/hook"))
+	assert.Equal(t, ErrPrivateAddress, g.CheckURL("http://169.254.169.254/latest/meta-data"))
+	assert.Equal(t, ErrPrivateAddress, g.CheckURL("http://LOCALHOST:8080/hook"))
+	assert.Equal(t, ErrPrivateAddress, g.CheckURL("http://api.localhost/hook"))
+	assert.Nil(t, NewGuard(mustParseCIDRs("127.0.0.1/32")).CheckURL("http://localhost:8080/hook"))
+}
+
+func TestGuardClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	_, err := NewGuard(nil).Client(time.Second).Post(server.URL, "application/json", nil)
+	assert.True(t, errors.Is(err, ErrPrivateAddress))
+
+	resp, err := NewGuard(mustParseCIDRs("127.0.0.1/32")).Client(time.Second).Post(server.URL, "application/json", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp.Body.Close()
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func startServer(t *testing.T, handler http.HandlerFunc, shutdownTimeout time.Duration) (string, context.CancelFunc, chan error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- serve(ctx, &http.Server{Handler: handler}, ln, shutdownTimeout)
+	}()
+	return "http://" + ln.Addr().String(), cancel, done
+}
+
+func TestServeDrainsInFlightRequests(t *testing.T) {
+	started := make(chan struct{})
+	url, cancel, done := startServer(t, func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("done"))
+	}, time.Second)
+
+	result := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			result <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		result <- string(body)
+	}()
+	<-started
+	cancel()
+
+	assert.Equal(t, "done", <-result)
+	assert.Nil(t, <-done)
+}
+
+func TestServeShutdownDeadline(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	url, cancel, done := startServer(t, func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}, 50*time.Millisecond)
+
+	go http.Get(url)
+	<-started
+	cancel()
+
+	err := <-done
+	assert.NotNil(t, err)
+	assert.Equal(t, "shutdown: context deadline exceeded", err.Error())
+}
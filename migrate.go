@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"friend-management-v1/db/migration"
+	"friend-management-v1/internal/config"
+	"friend-management-v1/internal/migrate"
+	"friend-management-v1/internal/utils"
+	"io/fs"
+	"os"
+	"strconv"
+)
+
+const migrateUsage = `usage: friend-management-v1 migrate [-dir path] [config flags] <command>
+
+commands:
+  up            apply every pending migration
+  down [n]      revert the last n applied migrations (default 1)
+  status        list the migrations and whether they are applied
+  goto version  apply or revert migrations until version is the last applied`
+
+func runMigrate(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dir := flags.String("dir", "", "read migrations from this directory instead of the embedded db/migration")
+	loader := config.NewLoader(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), migrateUsage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := loader.Load(os.LookupEnv)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("missing migrate command")
+	}
+
+	var files fs.FS = migration.Files
+	if *dir != "" {
+		files = os.DirFS(*dir)
+	}
+	db, err := utils.DBConnection(cfg.DB)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	m, err := migrate.New(db, files)
+	if err != nil {
+		return err
+	}
+
+	cmd, rest := flags.Arg(0), flags.Args()[1:]
+	switch {
+	case cmd == "up" && len(rest) == 0:
+		return m.Up()
+	case cmd == "down" && len(rest) <= 1:
+		n := 1
+		if len(rest) == 1 {
+			if n, err = strconv.Atoi(rest[0]); err != nil || n < 1 {
+				return errors.New("down: n must be a positive number")
+			}
+		}
+		return m.Down(n)
+	case cmd == "goto" && len(rest) == 1:
+		version, err := strconv.ParseInt(rest[0], 10, 64)
+		if err != nil {
+			return errors.New("goto: version must be a number")
+		}
+		return m.Goto(version)
+	case cmd == "status" && len(rest) == 0:
+		status, err := m.Status()
+		if err != nil {
+			return err
+		}
+		for _, s := range status {
+			applied := "pending"
+			if s.Applied {
+				applied = "applied"
+			}
+			fmt.Printf("%06d %-40s %s\n", s.Version, s.Name, applied)
+		}
+		return nil
+	}
+	flags.Usage()
+	return fmt.Errorf("invalid migrate command: %s", cmd)
+}
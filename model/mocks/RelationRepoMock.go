@@ -1,26 +1,33 @@
 package mocks
 
-import mock "github.com/stretchr/testify/mock"
+import (
+	"context"
+	repos "friend-management-v1/internal/repos"
+	model "friend-management-v1/model"
+	"time"
+
+	mock "github.com/stretchr/testify/mock"
+)
 
 // RelationRepo is an autogenerated mock type for the RelationRepo type
 type RelationRepo struct {
 	mock.Mock
 }
 
-// AddRelation provides a mock function with given fields: ids, status
-func (_m *RelationRepo) AddRelation(ids []string, status string) (bool, error) {
-	ret := _m.Called(ids, status)
+// AddDirectedRelation provides a mock function with given fields: ctx, ids, status
+func (_m *RelationRepo) AddDirectedRelation(ctx context.Context, ids []string, status model.RelationStatus) (bool, error) {
+	ret := _m.Called(ctx, ids, status)
 
 	var r0 bool
-	if rf, ok := ret.Get(0).(func([]string, string) bool); ok {
-		r0 = rf(ids, status)
+	if rf, ok := ret.Get(0).(func(context.Context, []string, model.RelationStatus) bool); ok {
+		r0 = rf(ctx, ids, status)
 	} else {
 		r0 = ret.Get(0).(bool)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func([]string, string) error); ok {
-		r1 = rf(ids, status)
+	if rf, ok := ret.Get(1).(func(context.Context, []string, model.RelationStatus) error); ok {
+		r1 = rf(ctx, ids, status)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -28,36 +35,171 @@ func (_m *RelationRepo) AddRelation(ids []string, status string) (bool, error) {
 	return r0, r1
 }
 
-// CheckIfExist provides a mock function with given fields: id1, id2, status
-func (_m *RelationRepo) CheckIfExist(id1 string, id2 string, status string) bool {
-	ret := _m.Called(id1, id2, status)
+// AddRelation provides a mock function with given fields: ctx, ids, status
+func (_m *RelationRepo) AddRelation(ctx context.Context, ids []string, status model.RelationStatus) (bool, error) {
+	ret := _m.Called(ctx, ids, status)
 
 	var r0 bool
-	if rf, ok := ret.Get(0).(func(string, string, string) bool); ok {
-		r0 = rf(id1, id2, status)
+	if rf, ok := ret.Get(0).(func(context.Context, []string, model.RelationStatus) bool); ok {
+		r0 = rf(ctx, ids, status)
 	} else {
 		r0 = ret.Get(0).(bool)
 	}
 
-	return r0
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []string, model.RelationStatus) error); ok {
+		r1 = rf(ctx, ids, status)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
 }
 
-// GetEmailByStatus provides a mock function with given fields: id, status
-func (_m *RelationRepo) GetEmailByStatus(id string, status string) ([]string, error) {
-	ret := _m.Called(id, status)
+// CheckIfExist provides a mock function with given fields: ctx, id1, id2, status
+func (_m *RelationRepo) CheckIfExist(ctx context.Context, id1 string, id2 string, status model.RelationStatus) (bool, error) {
+	ret := _m.Called(ctx, id1, id2, status)
 
-	var r0 []string
-	if rf, ok := ret.Get(0).(func(string, string) []string); ok {
-		r0 = rf(id, status)
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, model.RelationStatus) bool); ok {
+		r0 = rf(ctx, id1, id2, status)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, model.RelationStatus) error); ok {
+		r1 = rf(ctx, id1, id2, status)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateEmail provides a mock function with given fields: ctx, email
+func (_m *RelationRepo) CreateEmail(ctx context.Context, email string) (string, error) {
+	ret := _m.Called(ctx, email)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(context.Context, string) string); ok {
+		r0 = rf(ctx, email)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, email)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAllEmails provides a mock function with given fields: ctx
+func (_m *RelationRepo) GetAllEmails(ctx context.Context) ([]model.User, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []model.User
+	if rf, ok := ret.Get(0).(func(context.Context) []model.User); ok {
+		r0 = rf(ctx)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]string)
+			r0 = ret.Get(0).([]model.User)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAuditRecords provides a mock function with given fields: ctx, id, from, to, page
+func (_m *RelationRepo) GetAuditRecords(ctx context.Context, id string, from time.Time, to time.Time, page model.Page) (model.AuditPage, error) {
+	ret := _m.Called(ctx, id, from, to, page)
+
+	var r0 model.AuditPage
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time, time.Time, model.Page) model.AuditPage); ok {
+		r0 = rf(ctx, id, from, to, page)
+	} else {
+		r0 = ret.Get(0).(model.AuditPage)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, time.Time, time.Time, model.Page) error); ok {
+		r1 = rf(ctx, id, from, to, page)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetCommonEmails provides a mock function with given fields: ctx, ids, page
+func (_m *RelationRepo) GetCommonEmails(ctx context.Context, ids []string, page model.Page) (model.EmailPage, error) {
+	ret := _m.Called(ctx, ids, page)
+
+	var r0 model.EmailPage
+	if rf, ok := ret.Get(0).(func(context.Context, []string, model.Page) model.EmailPage); ok {
+		r0 = rf(ctx, ids, page)
+	} else {
+		r0 = ret.Get(0).(model.EmailPage)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []string, model.Page) error); ok {
+		r1 = rf(ctx, ids, page)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetEmailByStatus provides a mock function with given fields: ctx, id, status, page
+func (_m *RelationRepo) GetEmailByStatus(ctx context.Context, id string, status model.RelationStatus, page model.Page) (model.EmailPage, error) {
+	ret := _m.Called(ctx, id, status, page)
+
+	var r0 model.EmailPage
+	if rf, ok := ret.Get(0).(func(context.Context, string, model.RelationStatus, model.Page) model.EmailPage); ok {
+		r0 = rf(ctx, id, status, page)
+	} else {
+		r0 = ret.Get(0).(model.EmailPage)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, model.RelationStatus, model.Page) error); ok {
+		r1 = rf(ctx, id, status, page)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetFriendsOf provides a mock function with given fields: ctx, ids
+func (_m *RelationRepo) GetFriendsOf(ctx context.Context, ids []string) (map[string][]model.User, error) {
+	ret := _m.Called(ctx, ids)
+
+	var r0 map[string][]model.User
+	if rf, ok := ret.Get(0).(func(context.Context, []string) map[string][]model.User); ok {
+		r0 = rf(ctx, ids)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string][]model.User)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string, string) error); ok {
-		r1 = rf(id, status)
+	if rf, ok := ret.Get(1).(func(context.Context, []string) error); ok {
+		r1 = rf(ctx, ids)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -65,20 +207,20 @@ func (_m *RelationRepo) GetEmailByStatus(id string, status string) ([]string, er
 	return r0, r1
 }
 
-// GetIdFromEmail provides a mock function with given fields: email
-func (_m *RelationRepo) GetIdFromEmail(email string) (string, error) {
-	ret := _m.Called(email)
+// GetIdFromEmail provides a mock function with given fields: ctx, email
+func (_m *RelationRepo) GetIdFromEmail(ctx context.Context, email string) (string, error) {
+	ret := _m.Called(ctx, email)
 
 	var r0 string
-	if rf, ok := ret.Get(0).(func(string) string); ok {
-		r0 = rf(email)
+	if rf, ok := ret.Get(0).(func(context.Context, string) string); ok {
+		r0 = rf(ctx, email)
 	} else {
 		r0 = ret.Get(0).(string)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(email)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, email)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -86,13 +228,57 @@ func (_m *RelationRepo) GetIdFromEmail(email string) (string, error) {
 	return r0, r1
 }
 
-// GetRetrivableEmails provides a mock function with given fields: id
-func (_m *RelationRepo) GetRetrivableEmails(id string) ([]string, error) {
-	ret := _m.Called(id)
+// GetRetrivableEmails provides a mock function with given fields: ctx, id, mentions, page
+func (_m *RelationRepo) GetRetrivableEmails(ctx context.Context, id string, mentions []string, page model.Page) (model.EmailPage, error) {
+	ret := _m.Called(ctx, id, mentions, page)
+
+	var r0 model.EmailPage
+	if rf, ok := ret.Get(0).(func(context.Context, string, []string, model.Page) model.EmailPage); ok {
+		r0 = rf(ctx, id, mentions, page)
+	} else {
+		r0 = ret.Get(0).(model.EmailPage)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, []string, model.Page) error); ok {
+		r1 = rf(ctx, id, mentions, page)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetSuggestions provides a mock function with given fields: ctx, id, limit
+func (_m *RelationRepo) GetSuggestions(ctx context.Context, id string, limit int) ([]model.Suggestion, error) {
+	ret := _m.Called(ctx, id, limit)
+
+	var r0 []model.Suggestion
+	if rf, ok := ret.Get(0).(func(context.Context, string, int) []model.Suggestion); ok {
+		r0 = rf(ctx, id, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Suggestion)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, int) error); ok {
+		r1 = rf(ctx, id, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUnregisteredEmails provides a mock function with given fields: ctx, emails
+func (_m *RelationRepo) GetUnregisteredEmails(ctx context.Context, emails []string) ([]string, error) {
+	ret := _m.Called(ctx, emails)
 
 	var r0 []string
-	if rf, ok := ret.Get(0).(func(string) []string); ok {
-		r0 = rf(id)
+	if rf, ok := ret.Get(0).(func(context.Context, []string) []string); ok {
+		r0 = rf(ctx, emails)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]string)
@@ -100,11 +286,81 @@ func (_m *RelationRepo) GetRetrivableEmails(id string) ([]string, error) {
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(id)
+	if rf, ok := ret.Get(1).(func(context.Context, []string) error); ok {
+		r1 = rf(ctx, emails)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// LockEmails provides a mock function with given fields: ctx, ids
+func (_m *RelationRepo) LockEmails(ctx context.Context, ids []string) error {
+	ret := _m.Called(ctx, ids)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, []string) error); ok {
+		r0 = rf(ctx, ids)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RemoveDirectedRelation provides a mock function with given fields: ctx, ids, status
+func (_m *RelationRepo) RemoveDirectedRelation(ctx context.Context, ids []string, status model.RelationStatus) (bool, error) {
+	ret := _m.Called(ctx, ids, status)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, []string, model.RelationStatus) bool); ok {
+		r0 = rf(ctx, ids, status)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []string, model.RelationStatus) error); ok {
+		r1 = rf(ctx, ids, status)
 	} else {
 		r1 = ret.Error(1)
 	}
 
 	return r0, r1
 }
+
+// RemoveRelation provides a mock function with given fields: ctx, ids, status
+func (_m *RelationRepo) RemoveRelation(ctx context.Context, ids []string, status model.RelationStatus) (bool, error) {
+	ret := _m.Called(ctx, ids, status)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, []string, model.RelationStatus) bool); ok {
+		r0 = rf(ctx, ids, status)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []string, model.RelationStatus) error); ok {
+		r1 = rf(ctx, ids, status)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Transaction provides a mock function with given fields: ctx, fn
+func (_m *RelationRepo) Transaction(ctx context.Context, fn func(repos.RelationRepo) error) error {
+	ret := _m.Called(ctx, fn)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, func(repos.RelationRepo) error) error); ok {
+		r0 = rf(ctx, fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
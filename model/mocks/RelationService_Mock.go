@@ -1,6 +1,7 @@
 package mocks
 
 import (
+	"context"
 	model "friend-management-v1/model"
 
 	mock "github.com/stretchr/testify/mock"
@@ -11,20 +12,20 @@ type RelationService struct {
 	mock.Mock
 }
 
-// Addfriend provides a mock function with given fields: rq
-func (_m *RelationService) Addfriend(rq model.AddAndGetCommonRequest) (bool, error) {
-	ret := _m.Called(rq)
+// Addfriend provides a mock function with given fields: ctx, rq
+func (_m *RelationService) Addfriend(ctx context.Context, rq model.AddAndGetCommonRequest) (bool, error) {
+	ret := _m.Called(ctx, rq)
 
 	var r0 bool
-	if rf, ok := ret.Get(0).(func(model.AddAndGetCommonRequest) bool); ok {
-		r0 = rf(rq)
+	if rf, ok := ret.Get(0).(func(context.Context, model.AddAndGetCommonRequest) bool); ok {
+		r0 = rf(ctx, rq)
 	} else {
 		r0 = ret.Get(0).(bool)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(model.AddAndGetCommonRequest) error); ok {
-		r1 = rf(rq)
+	if rf, ok := ret.Get(1).(func(context.Context, model.AddAndGetCommonRequest) error); ok {
+		r1 = rf(ctx, rq)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -32,20 +33,20 @@ func (_m *RelationService) Addfriend(rq model.AddAndGetCommonRequest) (bool, err
 	return r0, r1
 }
 
-// BlockEmail provides a mock function with given fields: rq
-func (_m *RelationService) BlockEmail(rq model.SubcribeAndBlockRequest) (bool, error) {
-	ret := _m.Called(rq)
+// BlockEmail provides a mock function with given fields: ctx, rq
+func (_m *RelationService) BlockEmail(ctx context.Context, rq model.SubcribeAndBlockRequest) (bool, error) {
+	ret := _m.Called(ctx, rq)
 
 	var r0 bool
-	if rf, ok := ret.Get(0).(func(model.SubcribeAndBlockRequest) bool); ok {
-		r0 = rf(rq)
+	if rf, ok := ret.Get(0).(func(context.Context, model.SubcribeAndBlockRequest) bool); ok {
+		r0 = rf(ctx, rq)
 	} else {
 		r0 = ret.Get(0).(bool)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(model.SubcribeAndBlockRequest) error); ok {
-		r1 = rf(rq)
+	if rf, ok := ret.Get(1).(func(context.Context, model.SubcribeAndBlockRequest) error); ok {
+		r1 = rf(ctx, rq)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -53,13 +54,118 @@ func (_m *RelationService) BlockEmail(rq model.SubcribeAndBlockRequest) (bool, e
 	return r0, r1
 }
 
-// GetCommonFriends provides a mock function with given fields: rq
-func (_m *RelationService) GetCommonFriends(rq model.AddAndGetCommonRequest) ([]string, error) {
-	ret := _m.Called(rq)
+// GetAuditRecords provides a mock function with given fields: ctx, rq
+func (_m *RelationService) GetAuditRecords(ctx context.Context, rq model.AuditRequest) (model.AuditPage, error) {
+	ret := _m.Called(ctx, rq)
+
+	var r0 model.AuditPage
+	if rf, ok := ret.Get(0).(func(context.Context, model.AuditRequest) model.AuditPage); ok {
+		r0 = rf(ctx, rq)
+	} else {
+		r0 = ret.Get(0).(model.AuditPage)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.AuditRequest) error); ok {
+		r1 = rf(ctx, rq)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetCommonFriends provides a mock function with given fields: ctx, rq
+func (_m *RelationService) GetCommonFriends(ctx context.Context, rq model.AddAndGetCommonRequest) (model.EmailPage, error) {
+	ret := _m.Called(ctx, rq)
+
+	var r0 model.EmailPage
+	if rf, ok := ret.Get(0).(func(context.Context, model.AddAndGetCommonRequest) model.EmailPage); ok {
+		r0 = rf(ctx, rq)
+	} else {
+		r0 = ret.Get(0).(model.EmailPage)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.AddAndGetCommonRequest) error); ok {
+		r1 = rf(ctx, rq)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDeliveries provides a mock function with given fields: ctx, rq
+func (_m *RelationService) GetDeliveries(ctx context.Context, rq model.DeliveriesRequest) (model.DeliveryPage, error) {
+	ret := _m.Called(ctx, rq)
+
+	var r0 model.DeliveryPage
+	if rf, ok := ret.Get(0).(func(context.Context, model.DeliveriesRequest) model.DeliveryPage); ok {
+		r0 = rf(ctx, rq)
+	} else {
+		r0 = ret.Get(0).(model.DeliveryPage)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.DeliveriesRequest) error); ok {
+		r1 = rf(ctx, rq)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetFriendsEmail provides a mock function with given fields: ctx, rq
+func (_m *RelationService) GetFriendsEmail(ctx context.Context, rq model.GetFriendsRequest) (model.EmailPage, error) {
+	ret := _m.Called(ctx, rq)
+
+	var r0 model.EmailPage
+	if rf, ok := ret.Get(0).(func(context.Context, model.GetFriendsRequest) model.EmailPage); ok {
+		r0 = rf(ctx, rq)
+	} else {
+		r0 = ret.Get(0).(model.EmailPage)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.GetFriendsRequest) error); ok {
+		r1 = rf(ctx, rq)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetInbox provides a mock function with given fields: ctx, rq
+func (_m *RelationService) GetInbox(ctx context.Context, rq model.InboxRequest) (model.InboxPage, error) {
+	ret := _m.Called(ctx, rq)
+
+	var r0 model.InboxPage
+	if rf, ok := ret.Get(0).(func(context.Context, model.InboxRequest) model.InboxPage); ok {
+		r0 = rf(ctx, rq)
+	} else {
+		r0 = ret.Get(0).(model.InboxPage)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.InboxRequest) error); ok {
+		r1 = rf(ctx, rq)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetPath provides a mock function with given fields: ctx, rq
+func (_m *RelationService) GetPath(ctx context.Context, rq model.PathRequest) ([]string, error) {
+	ret := _m.Called(ctx, rq)
 
 	var r0 []string
-	if rf, ok := ret.Get(0).(func(model.AddAndGetCommonRequest) []string); ok {
-		r0 = rf(rq)
+	if rf, ok := ret.Get(0).(func(context.Context, model.PathRequest) []string); ok {
+		r0 = rf(ctx, rq)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]string)
@@ -67,8 +173,8 @@ func (_m *RelationService) GetCommonFriends(rq model.AddAndGetCommonRequest) ([]
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(model.AddAndGetCommonRequest) error); ok {
-		r1 = rf(rq)
+	if rf, ok := ret.Get(1).(func(context.Context, model.PathRequest) error); ok {
+		r1 = rf(ctx, rq)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -76,22 +182,22 @@ func (_m *RelationService) GetCommonFriends(rq model.AddAndGetCommonRequest) ([]
 	return r0, r1
 }
 
-// GetFriendsEmail provides a mock function with given fields: rq
-func (_m *RelationService) GetFriendsEmail(rq model.GetFriendsRequest) ([]string, error) {
-	ret := _m.Called(rq)
+// GetSuggestions provides a mock function with given fields: ctx, rq
+func (_m *RelationService) GetSuggestions(ctx context.Context, rq model.SuggestionsRequest) ([]model.Suggestion, error) {
+	ret := _m.Called(ctx, rq)
 
-	var r0 []string
-	if rf, ok := ret.Get(0).(func(model.GetFriendsRequest) []string); ok {
-		r0 = rf(rq)
+	var r0 []model.Suggestion
+	if rf, ok := ret.Get(0).(func(context.Context, model.SuggestionsRequest) []model.Suggestion); ok {
+		r0 = rf(ctx, rq)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]string)
+			r0 = ret.Get(0).([]model.Suggestion)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(model.GetFriendsRequest) error); ok {
-		r1 = rf(rq)
+	if rf, ok := ret.Get(1).(func(context.Context, model.SuggestionsRequest) error); ok {
+		r1 = rf(ctx, rq)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -99,22 +205,185 @@ func (_m *RelationService) GetFriendsEmail(rq model.GetFriendsRequest) ([]string
 	return r0, r1
 }
 
-// RetrieveContactEmail provides a mock function with given fields: rq
-func (_m *RelationService) RetrieveContactEmail(rq model.RetrieveRequest) ([]string, error) {
-	ret := _m.Called(rq)
+// GetUser provides a mock function with given fields: ctx, rq
+func (_m *RelationService) GetUser(ctx context.Context, rq model.UserRequest) (model.User, error) {
+	ret := _m.Called(ctx, rq)
 
-	var r0 []string
-	if rf, ok := ret.Get(0).(func(model.RetrieveRequest) []string); ok {
-		r0 = rf(rq)
+	var r0 model.User
+	if rf, ok := ret.Get(0).(func(context.Context, model.UserRequest) model.User); ok {
+		r0 = rf(ctx, rq)
+	} else {
+		r0 = ret.Get(0).(model.User)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.UserRequest) error); ok {
+		r1 = rf(ctx, rq)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUsers provides a mock function with given fields: ctx
+func (_m *RelationService) GetUsers(ctx context.Context) ([]model.User, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []model.User
+	if rf, ok := ret.Get(0).(func(context.Context) []model.User); ok {
+		r0 = rf(ctx)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]string)
+			r0 = ret.Get(0).([]model.User)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MarkRead provides a mock function with given fields: ctx, rq
+func (_m *RelationService) MarkRead(ctx context.Context, rq model.MarkReadRequest) (int, error) {
+	ret := _m.Called(ctx, rq)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, model.MarkReadRequest) int); ok {
+		r0 = rf(ctx, rq)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.MarkReadRequest) error); ok {
+		r1 = rf(ctx, rq)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RegisterEmail provides a mock function with given fields: ctx, rq
+func (_m *RelationService) RegisterEmail(ctx context.Context, rq model.UserRequest) (model.User, error) {
+	ret := _m.Called(ctx, rq)
+
+	var r0 model.User
+	if rf, ok := ret.Get(0).(func(context.Context, model.UserRequest) model.User); ok {
+		r0 = rf(ctx, rq)
+	} else {
+		r0 = ret.Get(0).(model.User)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.UserRequest) error); ok {
+		r1 = rf(ctx, rq)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RegisterWebhook provides a mock function with given fields: ctx, rq
+func (_m *RelationService) RegisterWebhook(ctx context.Context, rq model.WebhookRequest) error {
+	ret := _m.Called(ctx, rq)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.WebhookRequest) error); ok {
+		r0 = rf(ctx, rq)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RetrieveContactEmail provides a mock function with given fields: ctx, rq
+func (_m *RelationService) RetrieveContactEmail(ctx context.Context, rq model.RetrieveRequest) (model.RecipientsPage, error) {
+	ret := _m.Called(ctx, rq)
+
+	var r0 model.RecipientsPage
+	if rf, ok := ret.Get(0).(func(context.Context, model.RetrieveRequest) model.RecipientsPage); ok {
+		r0 = rf(ctx, rq)
+	} else {
+		r0 = ret.Get(0).(model.RecipientsPage)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.RetrieveRequest) error); ok {
+		r1 = rf(ctx, rq)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SubcribeToEmail provides a mock function with given fields: ctx, rq
+func (_m *RelationService) SubcribeToEmail(ctx context.Context, rq model.SubcribeAndBlockRequest) (bool, error) {
+	ret := _m.Called(ctx, rq)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, model.SubcribeAndBlockRequest) bool); ok {
+		r0 = rf(ctx, rq)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.SubcribeAndBlockRequest) error); ok {
+		r1 = rf(ctx, rq)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UnblockEmail provides a mock function with given fields: ctx, rq
+func (_m *RelationService) UnblockEmail(ctx context.Context, rq model.SubcribeAndBlockRequest) ([]model.RelationStatus, error) {
+	ret := _m.Called(ctx, rq)
+
+	var r0 []model.RelationStatus
+	if rf, ok := ret.Get(0).(func(context.Context, model.SubcribeAndBlockRequest) []model.RelationStatus); ok {
+		r0 = rf(ctx, rq)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.RelationStatus)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(model.RetrieveRequest) error); ok {
-		r1 = rf(rq)
+	if rf, ok := ret.Get(1).(func(context.Context, model.SubcribeAndBlockRequest) error); ok {
+		r1 = rf(ctx, rq)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Unfriend provides a mock function with given fields: ctx, rq
+func (_m *RelationService) Unfriend(ctx context.Context, rq model.AddAndGetCommonRequest) (bool, error) {
+	ret := _m.Called(ctx, rq)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, model.AddAndGetCommonRequest) bool); ok {
+		r0 = rf(ctx, rq)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.AddAndGetCommonRequest) error); ok {
+		r1 = rf(ctx, rq)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -122,20 +391,20 @@ func (_m *RelationService) RetrieveContactEmail(rq model.RetrieveRequest) ([]str
 	return r0, r1
 }
 
-// SubcribeToEmail provides a mock function with given fields: rq
-func (_m *RelationService) SubcribeToEmail(rq model.SubcribeAndBlockRequest) (bool, error) {
-	ret := _m.Called(rq)
+// UnsubcribeFromEmail provides a mock function with given fields: ctx, rq
+func (_m *RelationService) UnsubcribeFromEmail(ctx context.Context, rq model.SubcribeAndBlockRequest) (bool, error) {
+	ret := _m.Called(ctx, rq)
 
 	var r0 bool
-	if rf, ok := ret.Get(0).(func(model.SubcribeAndBlockRequest) bool); ok {
-		r0 = rf(rq)
+	if rf, ok := ret.Get(0).(func(context.Context, model.SubcribeAndBlockRequest) bool); ok {
+		r0 = rf(ctx, rq)
 	} else {
 		r0 = ret.Get(0).(bool)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(model.SubcribeAndBlockRequest) error); ok {
-		r1 = rf(rq)
+	if rf, ok := ret.Get(1).(func(context.Context, model.SubcribeAndBlockRequest) error); ok {
+		r1 = rf(ctx, rq)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -0,0 +1,97 @@
+package mocks
+
+import (
+	"context"
+	model "friend-management-v1/model"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// UpdateRepo is an autogenerated mock type for the UpdateRepo type
+type UpdateRepo struct {
+	mock.Mock
+}
+
+// CreateUpdate provides a mock function with given fields: ctx, sender, text, recipients
+func (_m *UpdateRepo) CreateUpdate(ctx context.Context, sender model.User, text string, recipients []string) (model.Update, error) {
+	ret := _m.Called(ctx, sender, text, recipients)
+
+	var r0 model.Update
+	if rf, ok := ret.Get(0).(func(context.Context, model.User, string, []string) model.Update); ok {
+		r0 = rf(ctx, sender, text, recipients)
+	} else {
+		r0 = ret.Get(0).(model.Update)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.User, string, []string) error); ok {
+		r1 = rf(ctx, sender, text, recipients)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetInbox provides a mock function with given fields: ctx, recipientId, page, unreadOnly
+func (_m *UpdateRepo) GetInbox(ctx context.Context, recipientId string, page model.Page, unreadOnly bool) (model.InboxPage, error) {
+	ret := _m.Called(ctx, recipientId, page, unreadOnly)
+
+	var r0 model.InboxPage
+	if rf, ok := ret.Get(0).(func(context.Context, string, model.Page, bool) model.InboxPage); ok {
+		r0 = rf(ctx, recipientId, page, unreadOnly)
+	} else {
+		r0 = ret.Get(0).(model.InboxPage)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, model.Page, bool) error); ok {
+		r1 = rf(ctx, recipientId, page, unreadOnly)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUpdate provides a mock function with given fields: ctx, id
+func (_m *UpdateRepo) GetUpdate(ctx context.Context, id string) (model.Update, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 model.Update
+	if rf, ok := ret.Get(0).(func(context.Context, string) model.Update); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(model.Update)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MarkRead provides a mock function with given fields: ctx, recipientId, ids
+func (_m *UpdateRepo) MarkRead(ctx context.Context, recipientId string, ids []string) (int, error) {
+	ret := _m.Called(ctx, recipientId, ids)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, string, []string) int); ok {
+		r0 = rf(ctx, recipientId, ids)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, []string) error); ok {
+		r1 = rf(ctx, recipientId, ids)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -0,0 +1,108 @@
+package mocks
+
+import (
+	"context"
+	model "friend-management-v1/model"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+// WebhookRepo is an autogenerated mock type for the WebhookRepo type
+type WebhookRepo struct {
+	mock.Mock
+}
+
+// AddAttempt provides a mock function with given fields: ctx, deliveryId, attempt, status
+func (_m *WebhookRepo) AddAttempt(ctx context.Context, deliveryId string, attempt model.DeliveryAttempt, status model.DeliveryStatus) error {
+	ret := _m.Called(ctx, deliveryId, attempt, status)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, model.DeliveryAttempt, model.DeliveryStatus) error); ok {
+		r0 = rf(ctx, deliveryId, attempt, status)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CreateDeliveries provides a mock function with given fields: ctx, updateId, webhooks
+func (_m *WebhookRepo) CreateDeliveries(ctx context.Context, updateId string, webhooks []model.Webhook) ([]model.Delivery, error) {
+	ret := _m.Called(ctx, updateId, webhooks)
+
+	var r0 []model.Delivery
+	if rf, ok := ret.Get(0).(func(context.Context, string, []model.Webhook) []model.Delivery); ok {
+		r0 = rf(ctx, updateId, webhooks)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Delivery)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, []model.Webhook) error); ok {
+		r1 = rf(ctx, updateId, webhooks)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDeliveries provides a mock function with given fields: ctx, status, page
+func (_m *WebhookRepo) GetDeliveries(ctx context.Context, status model.DeliveryStatus, page model.Page) (model.DeliveryPage, error) {
+	ret := _m.Called(ctx, status, page)
+
+	var r0 model.DeliveryPage
+	if rf, ok := ret.Get(0).(func(context.Context, model.DeliveryStatus, model.Page) model.DeliveryPage); ok {
+		r0 = rf(ctx, status, page)
+	} else {
+		r0 = ret.Get(0).(model.DeliveryPage)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.DeliveryStatus, model.Page) error); ok {
+		r1 = rf(ctx, status, page)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetWebhooks provides a mock function with given fields: ctx, emails
+func (_m *WebhookRepo) GetWebhooks(ctx context.Context, emails []string) ([]model.Webhook, error) {
+	ret := _m.Called(ctx, emails)
+
+	var r0 []model.Webhook
+	if rf, ok := ret.Get(0).(func(context.Context, []string) []model.Webhook); ok {
+		r0 = rf(ctx, emails)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Webhook)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []string) error); ok {
+		r1 = rf(ctx, emails)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SetWebhook provides a mock function with given fields: ctx, emailId, url
+func (_m *WebhookRepo) SetWebhook(ctx context.Context, emailId string, url string) error {
+	ret := _m.Called(ctx, emailId, url)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, emailId, url)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
@@ -16,6 +16,8 @@ type Friend_Relation struct {
 
 type AddAndGetCommonRequest struct {
 	Friends []string `json:"friends" binding:"required"`
+	Limit   int      `json:"limit"`
+	Cursor  string   `json:"cursor"`
 }
 
 type SuccessRespone struct {
@@ -23,13 +25,17 @@ type SuccessRespone struct {
 }
 
 type GetFriendsRequest struct {
-	Email string `json:"email" binding:"required"`
+	Email  string `json:"email" binding:"required"`
+	Limit  int    `json:"limit"`
+	Cursor string `json:"cursor"`
 }
 
 type AddAndGetResponse struct {
-	Success bool     `json:"success" binding:"required"`
-	Friends []string `json:"friends" binding:"required"`
-	Count   int      `json:"count" binding:"required"`
+	Success    bool     `json:"success" binding:"required"`
+	Friends    []string `json:"friends" binding:"required"`
+	Count      int      `json:"count" binding:"required"`
+	Total      int      `json:"total" binding:"required"`
+	NextCursor string   `json:"next_cursor,omitempty"`
 }
 
 type SubcribeAndBlockRequest struct {
@@ -40,11 +46,206 @@ type SubcribeAndBlockRequest struct {
 type RetrieveRequest struct {
 	Sender string `json:"sender" binding:"required"`
 	Text   string `json:"text" binding:"required"`
+	Limit  int    `json:"limit"`
+	Cursor string `json:"cursor"`
+	// Post stores the update and delivers it to the inbox of every
+	// recipient, not only the ones of the returned page.
+	Post bool `json:"post"`
 }
 
 type RetrieveResponse struct {
-	Success    bool     `json:"success" binding:"required"`
-	Recipients []string `json:"recipients" binding:"required"`
+	Success            bool     `json:"success" binding:"required"`
+	Recipients         []string `json:"recipients" binding:"required"`
+	UnresolvedMentions []string `json:"unresolved_mentions" binding:"required"`
+	Total              int      `json:"total" binding:"required"`
+	NextCursor         string   `json:"next_cursor,omitempty"`
+	UpdateId           string   `json:"update_id,omitempty"`
+}
+
+type UnblockResponse struct {
+	Success  bool             `json:"success" binding:"required"`
+	Restored []RelationStatus `json:"restored" binding:"required"`
+}
+
+type User struct {
+	Id    string `json:"id" binding:"required"`
+	Email string `json:"email" binding:"required"`
+}
+
+type UserRequest struct {
+	Email string `json:"email" binding:"required"`
+}
+
+type UserResponse struct {
+	Success bool `json:"success" binding:"required"`
+	User    User `json:"user" binding:"required"`
+}
+
+type UsersResponse struct {
+	Success bool   `json:"success" binding:"required"`
+	Users   []User `json:"users" binding:"required"`
+	Count   int    `json:"count" binding:"required"`
+}
+
+type SuggestionsRequest struct {
+	Email string `json:"email" binding:"required"`
+	Limit int    `json:"limit"`
+}
+
+// Suggestion is an email that is a friend of friends of the user, with the
+// friends they have in common.
+type Suggestion struct {
+	Email         string   `json:"email" binding:"required"`
+	MutualCount   int      `json:"mutual_count" binding:"required"`
+	MutualFriends []string `json:"mutual_friends" binding:"required"`
+}
+
+type SuggestionsResponse struct {
+	Success     bool         `json:"success" binding:"required"`
+	Suggestions []Suggestion `json:"suggestions" binding:"required"`
+	Count       int          `json:"count" binding:"required"`
+}
+
+type PathRequest struct {
+	From string `json:"from" binding:"required"`
+	To   string `json:"to" binding:"required"`
+}
+
+// PathResponse lists the emails of the shortest chain of friends from one
+// email to the other, both included. Found is false and Path empty when there
+// is no chain within the configured depth.
+type PathResponse struct {
+	Success bool     `json:"success" binding:"required"`
+	Found   bool     `json:"found" binding:"required"`
+	Path    []string `json:"path" binding:"required"`
+	Degrees int      `json:"degrees" binding:"required"`
+}
+
+// Update is a text posted by Sender through /api/retrieve.
+type Update struct {
+	Id        string    `json:"id" binding:"required"`
+	Sender    string    `json:"sender" binding:"required"`
+	Text      string    `json:"text" binding:"required"`
+	CreatedAt time.Time `json:"created_at" binding:"required"`
+}
+
+// InboxItem is the delivery of an update to one recipient.
+type InboxItem struct {
+	Id     string `json:"id" binding:"required"`
+	Update Update `json:"update" binding:"required"`
+	Read   bool   `json:"read" binding:"required"`
+}
+
+type InboxRequest struct {
+	Email      string `json:"email" binding:"required"`
+	Limit      int    `json:"limit"`
+	Cursor     string `json:"cursor"`
+	UnreadOnly bool   `json:"unread_only"`
+}
+
+type InboxResponse struct {
+	Success    bool        `json:"success" binding:"required"`
+	Items      []InboxItem `json:"items" binding:"required"`
+	Count      int         `json:"count" binding:"required"`
+	Total      int         `json:"total" binding:"required"`
+	Unread     int         `json:"unread" binding:"required"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+type MarkReadRequest struct {
+	Email string   `json:"email" binding:"required"`
+	Ids   []string `json:"ids" binding:"required"`
+}
+
+type MarkReadResponse struct {
+	Success bool `json:"success" binding:"required"`
+	Marked  int  `json:"marked" binding:"required"`
+}
+
+// AuditRecord tells that Actor added or removed its relation toward Target.
+// OldStatus and NewStatus are the status of the relation before and after,
+// empty when there was none. RequestId is the id of the API request that made
+// the change.
+type AuditRecord struct {
+	Id        string         `json:"id" binding:"required"`
+	Actor     string         `json:"actor" binding:"required"`
+	Target    string         `json:"target" binding:"required"`
+	Action    AuditAction    `json:"action" binding:"required"`
+	OldStatus RelationStatus `json:"old_status"`
+	NewStatus RelationStatus `json:"new_status"`
+	RequestId string         `json:"request_id"`
+	CreatedAt time.Time      `json:"created_at" binding:"required"`
+}
+
+type AuditRequest struct {
+	Email string `json:"email" binding:"required"`
+	// From and To bound the time of the records, From included and To
+	// excluded. A zero time leaves that side open.
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+	Limit  int       `json:"limit"`
+	Cursor string    `json:"cursor"`
+}
+
+type AuditResponse struct {
+	Success    bool          `json:"success" binding:"required"`
+	Records    []AuditRecord `json:"records" binding:"required"`
+	Count      int           `json:"count" binding:"required"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+// Webhook is the delivery URL registered by an email.
+type Webhook struct {
+	EmailId string
+	Email   string
+	Url     string
+}
+
+type WebhookRequest struct {
+	Email string `json:"email" binding:"required"`
+	// Url is the http or https URL updates are POSTed to, an empty Url
+	// removes the webhook.
+	Url string `json:"url"`
+}
+
+// Delivery is the POST of one update to the webhook of one recipient.
+type Delivery struct {
+	Id        string            `json:"id" binding:"required"`
+	UpdateId  string            `json:"update_id" binding:"required"`
+	Recipient string            `json:"recipient" binding:"required"`
+	Url       string            `json:"url" binding:"required"`
+	Status    DeliveryStatus    `json:"status" binding:"required"`
+	Attempts  []DeliveryAttempt `json:"attempts" binding:"required"`
+}
+
+// DeliveryAttempt records one try of a Delivery. StatusCode is 0 and Error is
+// set when no response was received.
+type DeliveryAttempt struct {
+	Attempt     int       `json:"attempt" binding:"required"`
+	StatusCode  int       `json:"status_code" binding:"required"`
+	Error       string    `json:"error,omitempty"`
+	AttemptedAt time.Time `json:"attempted_at" binding:"required"`
+}
+
+// DeliveryPayload is the body POSTed to a webhook.
+type DeliveryPayload struct {
+	DeliveryId string `json:"delivery_id" binding:"required"`
+	Recipient  string `json:"recipient" binding:"required"`
+	Update     Update `json:"update" binding:"required"`
+}
+
+type DeliveriesRequest struct {
+	// Status defaults to DEAD, the dead-letter list.
+	Status DeliveryStatus `json:"status"`
+	Limit  int            `json:"limit"`
+	Cursor string         `json:"cursor"`
+}
+
+type DeliveriesResponse struct {
+	Success    bool       `json:"success" binding:"required"`
+	Deliveries []Delivery `json:"deliveries" binding:"required"`
+	Count      int        `json:"count" binding:"required"`
+	NextCursor string     `json:"next_cursor,omitempty"`
 }
 
 type ErrorResponse struct {
@@ -0,0 +1,46 @@
+package model
+
+// Page selects at most Limit emails following Cursor, the NextCursor of the
+// previous page. An empty Cursor starts from the first email.
+type Page struct {
+	Limit  int
+	Cursor string
+}
+
+// EmailPage is one page of a list of emails ordered by email id. NextCursor is
+// empty on the last page and Total counts the emails of every page.
+type EmailPage struct {
+	Emails     []string
+	NextCursor string
+	Total      int
+}
+
+// RecipientsPage is one page of the recipients of an update. Every page lists
+// the mentioned emails that are not registered in UnresolvedMentions.
+type RecipientsPage struct {
+	EmailPage
+	UnresolvedMentions []string
+	// UpdateId is the id of the stored update when it was posted.
+	UpdateId string
+}
+
+// AuditPage is one page of audit records ordered by id.
+type AuditPage struct {
+	Records    []AuditRecord
+	NextCursor string
+}
+
+// DeliveryPage is one page of webhook deliveries ordered by id.
+type DeliveryPage struct {
+	Deliveries []Delivery
+	NextCursor string
+}
+
+// InboxPage is one page of an inbox, newest item first. Total counts the
+// items of every page and Unread the unread items of the whole inbox.
+type InboxPage struct {
+	Items      []InboxItem
+	NextCursor string
+	Total      int
+	Unread     int
+}
@@ -0,0 +1,45 @@
+package model
+
+// RelationStatus is the status of a row in friend_relationship. The values
+// are the only ones allowed by the friend_relationship_status_check constraint.
+type RelationStatus string
+
+const (
+	StatusFriend   RelationStatus = "FRIEND"
+	StatusSubcribe RelationStatus = "SUBCRIBE"
+	StatusBlock    RelationStatus = "BLOCK"
+)
+
+func (s RelationStatus) IsValid() bool {
+	switch s {
+	case StatusFriend, StatusSubcribe, StatusBlock:
+		return true
+	}
+	return false
+}
+
+// AuditAction is what an audit record says happened to a relation.
+type AuditAction string
+
+const (
+	AuditAdd    AuditAction = "ADD"
+	AuditRemove AuditAction = "REMOVE"
+)
+
+// DeliveryStatus is the status of a webhook delivery. A delivery is PENDING
+// while it is being attempted, and DEAD once every attempt failed.
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "PENDING"
+	DeliveryDelivered DeliveryStatus = "DELIVERED"
+	DeliveryDead      DeliveryStatus = "DEAD"
+)
+
+func (s DeliveryStatus) IsValid() bool {
+	switch s {
+	case DeliveryPending, DeliveryDelivered, DeliveryDead:
+		return true
+	}
+	return false
+}